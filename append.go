@@ -0,0 +1,103 @@
+package goarfs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+var (
+	// thinSignature starts GNU thin archives, whose members refer to files
+	// outside the archive
+	thinSignature = []byte("!<thin>\n")
+	// bigSignature starts AIX big format archives
+	bigSignature = []byte("<bigaf>\n")
+)
+
+// OpenAppend opens the archive at path for appending, like 'ar q', returning a
+// Writer which adds members to the end of it. Close must be called to finish
+// the archive, and also closes the file. See AppendTo for the details.
+func OpenAppend(path string, opts ...WriterOption) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	w, err := AppendTo(f, opts...)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	w.closer = f
+	return w, nil
+}
+
+// AppendTo checks that rw holds a complete archive, and returns a Writer which
+// adds members to the end of it without rewriting the existing ones. The new
+// members use the format of the existing archive (with Darwin archives
+// appended to in the BSD format), falling back to WithFormat for an archive
+// with no members.
+//
+// Nothing earlier in the archive is changed, so any symbol index isn't updated
+// to include the new members, and in a GNU format archive names which would
+// need the '//' table fail with ErrAppendUnsupported. Thin & AIX big format
+// archives also fail with ErrAppendUnsupported, while anything else which
+// isn't an archive fails with ErrBadSignature.
+func AppendTo(rw io.ReadWriteSeeker, opts ...WriterOption) (*Writer, error) {
+	if _, err := rw.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	sig := make([]byte, len(goodSignature))
+	if _, err := io.ReadFull(rw, sig); err != nil {
+		return nil, ErrBadSignature
+	}
+	switch {
+	case bytes.Equal(sig, thinSignature):
+		return nil, fmt.Errorf("%w: thin archive", ErrAppendUnsupported)
+	case bytes.Equal(sig, bigSignature):
+		return nil, fmt.Errorf("%w: AIX big archive", ErrAppendUnsupported)
+	}
+
+	existing, err := FromInterface(rw, WithoutOwnership())
+	if err != nil {
+		return nil, err
+	}
+	defer existing.Close()
+	idx := existing.snapshot()
+	for _, fh := range idx.members {
+		if fh.offset+fh.Size() > idx.size {
+			return nil, fmt.Errorf("%w: %q is truncated", io.ErrUnexpectedEOF, fh.name)
+		}
+	}
+
+	end, err := rw.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	// The last member may be missing its padding
+	if end%2 != 0 {
+		if _, err := rw.Write([]byte{'\n'}); err != nil {
+			return nil, err
+		}
+	}
+
+	w := NewWriter(rw, opts...)
+	switch idx.format {
+	case FormatGNU:
+		w.format = FormatGNU
+	case FormatBSD, FormatDarwin:
+		w.format = FormatBSD
+	}
+	w.started = true
+	w.appending = true
+	return w, nil
+}
+
+// gnuAppendHeader encodes the header of a member appended to a GNU format
+// archive, which has to be streamed as the '//' table can't be changed
+func (w *Writer) gnuAppendHeader(h Header) ([]byte, error) {
+	if w.needsLongName(h.Name) {
+		return nil, fmt.Errorf("%w: %q needs the GNU long filename table", ErrAppendUnsupported, h.Name)
+	}
+	return w.formatHeader(h.Name+"/", unixTime(h.ModTime), h.UID, h.GID, h.Mode, h.Size)
+}
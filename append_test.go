@@ -0,0 +1,146 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// appendFile adds a member holding its own name to an archive opened by
+// OpenAppend
+func appendFile(t *testing.T, w *Writer, name string) {
+	t.Helper()
+	if err := w.WriteHeader(&Header{Name: name, Size: int64(len(name)), Mode: 0o100644}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenAppend(t *testing.T) {
+	for _, format := range []Format{FormatBSD, FormatGNU} {
+		filename := filepath.Join(t.TempDir(), "lib.a")
+		f, err := os.Create(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := NewWriter(f, WithFormat(format))
+		appendFile(t, w, "old.o")
+		appendFile(t, w, "an_old_long_member_name.o")
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		w, err = OpenAppend(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		appendFile(t, w, "new.o")
+		if format == FormatBSD {
+			appendFile(t, w, "a_new_long_member_name.o")
+		} else {
+			err := w.WriteHeader(&Header{Name: "a_new_long_member_name.o", Size: 1})
+			if !errors.Is(err, ErrAppendUnsupported) {
+				t.Errorf("%s: a new long name should fail with ErrAppendUnsupported: %v", format, err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		ar, err := FromFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, h := range ar.List() {
+			names = append(names, h.Name)
+			if data, err := ar.ReadFile(h.Name); err != nil || string(data) != h.Name {
+				t.Errorf("%s: %s has the wrong contents %q: %v", format, h.Name, data, err)
+			}
+		}
+		ar.Close()
+		expected := "old.o an_old_long_member_name.o new.o a_new_long_member_name.o"
+		if format == FormatGNU {
+			expected = "old.o an_old_long_member_name.o new.o"
+		}
+		if strings.Join(names, " ") != expected {
+			t.Errorf("%s: archive should hold the old & new members, not %v", format, names)
+		}
+
+		if arTool, err := exec.LookPath("ar"); err == nil && format == FormatGNU {
+			out, err := exec.Command(arTool, "t", filename).Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if listed := strings.Join(strings.Fields(string(out)), " "); listed != expected {
+				t.Errorf("ar t lists %v, expected %v", listed, expected)
+			}
+		}
+	}
+}
+
+func TestAppendPadding(t *testing.T) {
+	// The last member is odd sized, and missing its padding
+	raw := []byte("!<arch>\nodd.txt         0           0     0     644     3         `\nabc")
+	filename := filepath.Join(t.TempDir(), "odd.a")
+	if err := os.WriteFile(filename, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	w, err := OpenAppend(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, w, "next.txt")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ar, err := FromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	if data, err := ar.ReadFile("next.txt"); err != nil || string(data) != "next.txt" {
+		t.Fatalf("appended member should follow the padding: %q %v", data, err)
+	}
+	if data, err := ar.ReadFile("odd.txt"); err != nil || string(data) != "abc" {
+		t.Fatalf("existing member should be unchanged: %q %v", data, err)
+	}
+}
+
+func TestAppendUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		err  error
+	}{
+		{"thin", "!<thin>\n", ErrAppendUnsupported},
+		{"big", "<bigaf>\n", ErrAppendUnsupported},
+		{"text", "not an archive", ErrBadSignature},
+		{"empty", "", ErrBadSignature},
+		{"truncated", "!<arch>\nfoo.txt         0           0     0     644     30        `\nabc", io.ErrUnexpectedEOF},
+	}
+	for _, test := range tests {
+		filename := filepath.Join(t.TempDir(), test.name)
+		if err := os.WriteFile(filename, []byte(test.raw), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := OpenAppend(filename); !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+		after, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(after, []byte(test.raw)) {
+			t.Errorf("%s: file should be unchanged: %q", test.name, after)
+		}
+	}
+}
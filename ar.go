@@ -7,6 +7,7 @@ package goarfs
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -14,8 +15,12 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -27,19 +32,72 @@ var (
 	goodSignature    = []byte("!<arch>\n") // todo: make it a const
 	headerTerminator = []byte{0x60, 0xa}
 
-	ErrTooShort      = errors.New("AR file too short")
-	ErrBadSignature  = errors.New("invalid AR signature")
-	ErrBadFileHeader = errors.New("bad AR file header")
+	ErrTooShort       = errors.New("AR file too short")
+	ErrBadSignature   = errors.New("invalid AR signature")
+	ErrBadFileHeader  = errors.New("bad AR file header")
+	ErrMissingPadding = errors.New("AR member missing alignment padding")
+	ErrBadStringTable = errors.New("bad AR long filename table")
+	ErrOutOfRange     = errors.New("AR member index out of range")
+	ErrDuplicate      = errors.New("duplicate AR member name")
+	ErrNotDir         = errors.New("AR member is not a directory")
+	ErrTooLarge       = errors.New("AR member larger than the read limit")
 )
 
 type ARFS struct {
-	rawFile arfsReader
+	// filename is set when loaded with FromFile, so that Refresh can re-open it
+	filename string
+	opts     options
 
+	idx       atomic.Pointer[index]
+	refreshMu sync.Mutex
+	closed    bool
+}
+
+// index is the parsed form of an archive. It is not modified once parsed, so
+// that Refresh can swap in a new one while the old one is still in use.
+type index struct {
+	rawFile *arfsReader
+	opts    options
+
+	// size is the total length of the archive in bytes
+	size int64
+	// base is the offset of the signature, if the archive has a preamble
+	base int64
+	// format is detected from the names of the members
+	format Format
+	// info is the state of the file when it was parsed, if loaded with FromFile
+	info fs.FileInfo
+
+	// fileHeaders is keyed by the (possibly lower cased) member name
 	fileHeaders map[string]*fileHeader
+	// members holds every entry in the order it appears in the archive
+	members []*fileHeader
+
+	// symbols is loaded from the symbol index on first use
+	symbolsOnce sync.Once
+	symbols     *symbolIndex
+
+	// contentTypes caches the result of ContentType for each *fileHeader
+	contentTypes sync.Map
+	// nested caches a *nestedArchive for each *fileHeader when using
+	// WithNestedArchives
+	nested sync.Map
+
+	// dirs holds the contents of each directory when using WithVirtualDirs,
+	// built on first use
+	dirsOnce sync.Once
+	dirs     map[string][]fs.DirEntry
 }
 
 type arfsReader struct {
 	io.ReadSeeker
+
+	// mu serialises the Seek & Read pairs when faking ReadAt
+	mu sync.Mutex
+	// clones is the number of extra ARFS sharing the reader via Clone
+	clones atomic.Int32
+	// borrowed is set if the reader belongs to the caller, so isn't closed
+	borrowed bool
 }
 
 // Make sure we implement all the various fs.FS interfaces
@@ -48,21 +106,37 @@ var _ fs.ReadDirFS = (*ARFS)(nil)
 var _ fs.ReadFileFS = (*ARFS)(nil)
 var _ fs.StatFS = (*ARFS)(nil)
 var _ fs.GlobFS = (*ARFS)(nil)
+var _ ARFileInfo = (*fileHeader)(nil)
 
 type fileHeader struct {
-	name         string
+	name string
+	// rawName is the name as stored in the archive, before any normalisation
+	rawName      string
 	modification time.Time
 	owner        uint32
 	group        uint32
 	mode         uint32
 	size         uint32
-	offset       int64
+	// headerOffset is where the member header starts
+	headerOffset int64
+	// offset is where the member contents start, after any extended name
+	offset int64
+	// span is the on-disk size of the contents, including any padding
+	span int64
+	// special is set for symbol indexes & long filename tables
+	special bool
+	// zeroModTime is set if ModTime reports the epoch as the zero time, for
+	// WithZeroModTime
+	zeroModTime bool
 
 	sectionReader *io.SectionReader
 }
 
 // arfsReader
 func (a *arfsReader) Close() error {
+	if a.borrowed {
+		return nil
+	}
 	// If our input is closable, then do that
 	if closer, ok := a.ReadSeeker.(io.Closer); ok {
 		return closer.Close()
@@ -70,205 +144,840 @@ func (a *arfsReader) Close() error {
 	return nil
 }
 
+// release drops a reference to the reader, closing it once there are none left
+func (a *arfsReader) release() error {
+	if a.clones.Add(-1) >= 0 {
+		return nil
+	}
+	return a.Close()
+}
+
 func (a *arfsReader) ReadAt(p []byte, off int64) (int, error) {
 	// If we're already a ReadSeeker, just use that
 	if readat, ok := a.ReadSeeker.(io.ReaderAt); ok {
 		return readat.ReadAt(p, off)
 	}
 	// Otherwise fake it using Seek & Read
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	if _, err := a.Seek(off, io.SeekStart); err != nil {
 		return 0, err
 	}
-	return a.Read(p)
+	return io.ReadFull(a.ReadSeeker, p)
+}
+
+// size returns the length of the archive. The Seek is serialised with ReadAt,
+// so that it can't move the reader between the Seek & Read of a faked ReadAt.
+func (a *arfsReader) size() (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.Seek(0, io.SeekEnd)
 }
 
 // FromFile loads an AR file from the operating system filesystem and returns
 // the fs.FS compatible interface from it. It will return an error if the AR file
 // is corrupt/invalid.
-func FromFile(filename string) (*ARFS, error) {
+func FromFile(filename string, opts ...Option) (*ARFS, error) {
+	o := newOptions(opts)
+	return FromFileContext(o.context(), filename, opts...)
+}
+
+// FromFileContext is the same as FromFile, but parsing is abandoned with
+// ctx.Err() if ctx is cancelled.
+func FromFileContext(ctx context.Context, filename string, opts ...Option) (*ARFS, error) {
 	f, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
-	a := &ARFS{rawFile: arfsReader{f}}
-	if err := a.parse(); err != nil {
+	o := newOptions(opts)
+	idx, err := parseFile(ctx, f, o)
+	if err != nil {
 		f.Close()
 		return nil, err
 	}
-	return a, nil
+	a := &ARFS{filename: filename, opts: o}
+	a.idx.Store(idx)
+	return a.watchLeaks(), nil
 }
 
-func FromInterface(raw io.ReadSeeker) (*ARFS, error) {
-	a := &ARFS{rawFile: arfsReader{raw}}
-	if err := a.parse(); err != nil {
+func FromInterface(raw io.ReadSeeker, opts ...Option) (*ARFS, error) {
+	o := newOptions(opts)
+	return FromReaderContext(o.context(), raw, opts...)
+}
+
+// FromReader loads an AR file from r. If r is an io.ReadSeeker the archive is
+// accessed directly from it (as with FromInterface), otherwise the whole
+// archive is first read into memory, or into a temporary file if it is larger
+// than allowed by WithSpillThreshold.
+func FromReader(r io.Reader, opts ...Option) (*ARFS, error) {
+	o := newOptions(opts)
+	return FromReaderContext(o.context(), r, opts...)
+}
+
+// FromReaderContext is the same as FromReader, but parsing is abandoned with
+// ctx.Err() if ctx is cancelled.
+func FromReaderContext(ctx context.Context, r io.Reader, opts ...Option) (*ARFS, error) {
+	o := newOptions(opts)
+	raw, ok := r.(io.ReadSeeker)
+	if !ok {
+		buffered, err := o.buffer(r)
+		if err != nil {
+			return nil, err
+		}
+		raw = buffered
+	}
+	// A buffer is ours, so must always be cleaned up
+	idx := &index{rawFile: &arfsReader{ReadSeeker: raw, borrowed: ok && o.borrowed}, opts: o}
+	if err := idx.parse(ctx); err != nil {
+		if !ok {
+			idx.rawFile.Close()
+		}
 		return nil, err
 	}
-	return a, nil
+	a := &ARFS{opts: o}
+	a.idx.Store(idx)
+	return a.watchLeaks(), nil
 }
 
-func (a *ARFS) parse() error {
-	a.fileHeaders = map[string]*fileHeader{}
-	if _, err := a.rawFile.Seek(0, io.SeekStart); err != nil {
-		return err
+// parseFile parses an archive from an open file, recording the file state
+func parseFile(ctx context.Context, f *os.File, opts options) (*index, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	idx := &index{rawFile: &arfsReader{ReadSeeker: f}, info: info, opts: opts}
+	if err := idx.parse(ctx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// snapshot returns the current parsed state of the archive
+func (a *ARFS) snapshot() *index {
+	return a.idx.Load()
+}
+
+// rawHeader holds the decoded fields of the fixed size header which precedes
+// each member
+type rawHeader struct {
+	name         string
+	field        string // the untrimmed name field
+	modification int64
+	owner        int64
+	group        int64
+	mode         int64
+	size         int64
+}
+
+func decodeHeader(header []byte) (rawHeader, error) {
+	var h rawHeader
+	if !bytes.Equal(header[58:60], headerTerminator) {
+		return h, ErrBadFileHeader
 	}
 
-	var signature [8]byte
-	n, err := a.rawFile.Read(signature[:])
+	h.field = string(header[0:16])
+	// Some older ARM toolchains pad the name with NULs rather than spaces
+	h.name = strings.TrimSpace(strings.TrimRight(h.field, " \x00"))
+
+	var err error
+	if h.size, err = h.parseField("size", header[48:58], 10); err != nil {
+		return h, err
+	}
+	// GNU leaves everything other than the size blank for the long name table
+	if h.name == "//" {
+		return h, nil
+	}
+	if h.modification, err = h.parseField("mtime", header[16:28], 10); err != nil {
+		return h, err
+	}
+	if h.owner, err = h.parseField("uid", header[28:34], 10); err != nil {
+		return h, err
+	}
+	if h.group, err = h.parseField("gid", header[34:40], 10); err != nil {
+		return h, err
+	}
+	if h.mode, err = h.parseField("mode", header[40:48], 8); err != nil {
+		return h, err
+	}
+	return h, nil
+}
+
+// parseField decodes a numeric header field. NUL bytes are reported by name,
+// as they survive the trimming and would otherwise give a confusing error from
+// strconv.
+func (h *rawHeader) parseField(field string, raw []byte, base int) (int64, error) {
+	if bytes.IndexByte(raw, 0) >= 0 {
+		return 0, fmt.Errorf("%w: %s field of %q contains a NUL byte", ErrBadFileHeader, field, h.name)
+	}
+	str := strings.TrimSpace(string(raw))
+	// Some producers leave the metadata blank, which means zero
+	if str == "" && field != "size" {
+		return 0, nil
+	}
+	value, err := strconv.ParseInt(str, base, 32)
 	if err != nil {
-		return err
+		return 0, errors.Join(ErrBadFileHeader, err)
 	}
-	if n != len(signature[:]) {
-		return ErrTooShort
+	return value, nil
+}
+
+// isSpecial reports whether the name is that of a symbol index or long name
+// table, rather than a regular file
+func isSpecial(name string) bool {
+	switch name {
+	case "/", "//", "/SYM64/", "__.SYMDEF", "__.SYMDEF SORTED", "__.SYMDEF_64", "__.SYMDEF_64 SORTED":
+		return true
 	}
+	return false
+}
 
-	if !bytes.Equal(signature[:], goodSignature) {
-		return ErrBadSignature
+// readFull reads exactly len(p) bytes from the archive at off
+func (idx *index) readFull(p []byte, off int64) error {
+	n, err := idx.readerFor(indexMember).ReadAt(p, off)
+	if n == len(p) {
+		return nil
+	}
+	if err == nil || errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
 	}
+	return err
+}
 
-	for {
-		var header [headerSize]byte
+// memberName decodes the name of a member from its header. Both the BSD
+// ('#1/n') and GNU ('/n') long filename formats are supported, along with the
+// trailing '/' used by GNU for short names. It returns the number of bytes of
+// the member data which were consumed by the name.
+func (idx *index) memberName(h rawHeader, dataOffset int64, stringTable []byte) (string, int64, error) {
+	switch {
+	case isSpecial(h.name):
+		return h.name, 0, nil
 
-		n, err := a.rawFile.Read(header[:])
+	// extended BSD entries have a name of the format '#1/n' where n is the
+	// number of bytes in the filename that we will pull out of the data itself.
+	case strings.HasPrefix(h.name, "#1/"):
+		length, err := strconv.ParseInt(strings.TrimPrefix(h.name, "#1/"), 10, 32)
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				return nil
-			}
-			return err
-		}
-		if n != headerSize {
-			return ErrTooShort
+			return "", 0, errors.Join(ErrBadFileHeader, err)
 		}
-
-		filename := strings.TrimSpace(string(header[0:16]))
-		modStr := strings.TrimSpace(string(header[16:28]))
-		ownerStr := strings.TrimSpace(string(header[28:34]))
-		groupStr := strings.TrimSpace(string(header[34:40]))
-		modeStr := strings.TrimSpace(string(header[40:48]))
-		sizeStr := strings.TrimSpace(string(header[48:58]))
-		terminator := header[58:60]
-
-		if !bytes.Equal(terminator, headerTerminator) {
-			return ErrBadFileHeader
+		if length < 0 || length > h.size {
+			return "", 0, fmt.Errorf("%w: extended filename longer than member: %d vs %d", ErrBadFileHeader, length, h.size)
 		}
-
-		modification, err := strconv.ParseInt(modStr, 10, 32)
-		if err != nil {
-			return errors.Join(ErrBadFileHeader, err)
+		filenameData := make([]byte, length)
+		if err := idx.readFull(filenameData, dataOffset); err != nil {
+			return "", 0, fmt.Errorf("insufficient data for extended filename: %w", err)
 		}
-		owner, err := strconv.ParseInt(ownerStr, 10, 32)
-		if err != nil {
-			return errors.Join(ErrBadFileHeader, err)
+		if idx.opts.rawNames {
+			// Only the NUL padding follows the name
+			if end := bytes.IndexByte(filenameData, 0); end >= 0 {
+				filenameData = filenameData[:end]
+			}
+			return string(filenameData), length, nil
 		}
-		group, err := strconv.ParseInt(groupStr, 10, 32)
+		return strings.TrimRight(string(filenameData), "\x00"), length, nil
+
+	// GNU long names have the format '/n', where n is the offset of the name
+	// in the '//' member
+	case strings.HasPrefix(h.name, "/"):
+		offset, err := strconv.ParseInt(strings.TrimPrefix(h.name, "/"), 10, 32)
 		if err != nil {
-			return errors.Join(ErrBadFileHeader, err)
+			return "", 0, errors.Join(ErrBadFileHeader, err)
 		}
-		mode, err := strconv.ParseInt(modeStr, 8, 32)
-		if err != nil {
-			return errors.Join(ErrBadFileHeader, err)
+		if offset < 0 || offset >= int64(len(stringTable)) {
+			return "", 0, fmt.Errorf("%w: no entry for %q", ErrBadStringTable, h.name)
 		}
-		size, err := strconv.ParseInt(sizeStr, 10, 32)
-		if err != nil {
-			return errors.Join(ErrBadFileHeader, err)
+		entry := stringTable[offset:]
+		if end := bytes.IndexAny(entry, "\n\x00"); end >= 0 {
+			entry = entry[:end]
 		}
-		// file entries are aligned to two-byte offsets
-		nextPos := size + size&1
+		return strings.TrimSuffix(string(entry), "/"), 0, nil
 
-		offset, err := a.rawFile.Seek(0, io.SeekCurrent)
-		if err != nil {
-			return err
+	default:
+		if idx.opts.rawNames {
+			// Only the padding follows the name (and any GNU terminator)
+			return strings.TrimSuffix(strings.TrimRight(h.field, " \x00"), "/"), 0, nil
 		}
+		return strings.TrimSuffix(h.name, "/"), 0, nil
+	}
+}
+
+// findSignature returns the offset of the archive signature, which must be at
+// the very start unless WithSignatureScan is used
+func (idx *index) findSignature() (int64, error) {
+	length := min(int64(len(goodSignature))+max(idx.opts.signatureScan, 0), idx.size)
+	if length < int64(len(goodSignature)) {
+		return 0, ErrTooShort
+	}
+	start := make([]byte, length)
+	if err := idx.readFull(start, 0); err != nil {
+		return 0, err
+	}
+	base := bytes.Index(start, goodSignature)
+	if base < 0 {
+		return 0, ErrBadSignature
+	}
+	return int64(base), nil
+}
 
-		sectionReader := io.NewSectionReader(&a.rawFile, offset, size)
+// cancelCheckInterval is how many members are parsed between checks for the
+// context being cancelled
+const cancelCheckInterval = 64
 
-		// If it's an 'extended' entry, then adjust things slightly
-		// extended entries have a name of the format '#n/m' where n is
-		// incrementing from 1, and m is the number of bytes in the filename
-		// that we will pull out of the data itself.
-		if strings.HasPrefix(filename, "#1/") {
-			length, err := strconv.ParseInt(strings.TrimPrefix(filename, "#1/"), 10, 32)
-			if err != nil {
+func (idx *index) parse(ctx context.Context) error {
+	idx.fileHeaders = map[string]*fileHeader{}
+	idx.members = nil
+	// Find the overall archive length, so that we can tell the difference
+	// between a final member which is missing its padding and a misaligned one
+	archiveSize, err := idx.rawFile.size()
+	if err != nil {
+		return err
+	}
+	idx.size = archiveSize
+
+	if idx.base, err = idx.findSignature(); err != nil {
+		return err
+	}
+
+	var stringTable []byte
+	// name of the previous member, if it was followed by a padding byte
+	padded := ""
+	// Hand built archives sometimes omit the padding after the final member,
+	// so landing exactly one byte past the end of the file also finishes
+	for pos, count := idx.base+int64(len(goodSignature)), 0; pos < archiveSize; count++ {
+		if count%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
 				return err
 			}
-			filenameData := make([]byte, length)
-			n, err := sectionReader.Read(filenameData)
-			if err != nil {
-				return err
+		}
+		var header [headerSize]byte
+
+		if err := idx.readFull(header[:], pos); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return ErrTooShort
 			}
-			if n != int(length) {
-				return fmt.Errorf("insufficient data for extended filename: %d vs %d", n, length)
+			return err
+		}
+
+		h, err := decodeHeader(header[:])
+		if err != nil {
+			// If this header would have been valid one byte earlier, then
+			// the previous member is missing its padding byte
+			if padded != "" && bytes.Equal(header[57:59], headerTerminator) {
+				return fmt.Errorf("%w after %q", ErrMissingPadding, padded)
 			}
+			return err
+		}
+
+		offset := pos + headerSize
+		// file entries are aligned to two-byte offsets
+		dataEnd := offset + h.size
+		nextPos := dataEnd + h.size&1
 
-			size -= length
-			sectionReader = io.NewSectionReader(&a.rawFile, offset+length, size)
-			filename = strings.TrimRight(string(filenameData), "\x00")
+		if h.name == "//" {
+			stringTable = make([]byte, h.size)
+			if err := idx.readFull(stringTable, offset); err != nil {
+				return fmt.Errorf("%w: %w", ErrBadStringTable, err)
+			}
 		}
 
-		a.fileHeaders[filename] = &fileHeader{
-			name:          filename,
-			modification:  time.Unix(modification, 0),
-			owner:         uint32(owner),
-			group:         uint32(group),
-			mode:          uint32(mode),
-			size:          uint32(size),
-			offset:        offset,
-			sectionReader: sectionReader,
+		filename, nameLength, err := idx.memberName(h, offset, stringTable)
+		if err != nil {
+			return err
 		}
+		offset += nameLength
+		size := h.size - nameLength
+		idx.noteFormat(h.name, filename)
 
-		if _, err := a.rawFile.Seek(nextPos, io.SeekCurrent); err != nil {
+		fh := &fileHeader{
+			name:         filename,
+			rawName:      filename,
+			modification: time.Unix(h.modification, 0),
+			owner:        uint32(h.owner),
+			group:        uint32(h.group),
+			mode:         uint32(h.mode),
+			size:         uint32(size),
+			headerOffset: pos,
+			offset:       offset,
+			span:         nextPos - offset,
+			special:      isSpecial(filename),
+		}
+		if err := idx.addMember(fh); err != nil {
 			return err
 		}
+		fh.sectionReader = io.NewSectionReader(idx.readerFor(fh.name), offset, size)
+
+		padded = ""
+		if nextPos != dataEnd {
+			padded = filename
+		}
+		pos = nextPos
+		if idx.opts.progress != nil {
+			idx.opts.progress(count+1, min(pos, archiveSize))
+		}
 	}
+	return nil
+}
+
+// noteFormat updates the detected format of the archive from the stored and
+// decoded names of a member. GNU always uses a '/' at the start (for special
+// members & long names) or end (for short names) of the stored name, while BSD
+// never does. The BSD symbol index marks an archive from the Darwin tools.
+func (idx *index) noteFormat(stored, name string) {
+	switch {
+	case strings.HasPrefix(name, "__.SYMDEF"):
+		idx.format = FormatDarwin
+	case idx.format != FormatUnknown:
+	case strings.HasPrefix(stored, "/") || strings.HasSuffix(stored, "/"):
+		idx.format = FormatGNU
+	default:
+		idx.format = FormatBSD
+	}
+}
+
+// Format reports the flavour of the archive, as detected from the names of its
+// members. It is FormatUnknown if the archive is empty.
+func (a *ARFS) Format() Format {
+	return a.snapshot().format
 }
 
+// addMember records a parsed member, applying the name normaliser and the
+// duplicate name policy
+func (idx *index) addMember(fh *fileHeader) error {
+	fh.zeroModTime = idx.opts.zeroModTime && fh.modification.Unix() == 0
+	if idx.opts.normalizer != nil && !fh.special {
+		name, ok := idx.opts.normalizer(fh.name)
+		if !ok {
+			return nil
+		}
+		fh.name = name
+	}
+	idx.members = append(idx.members, fh)
+	key := idx.opts.key(fh.name)
+	prev, ok := idx.fileHeaders[key]
+	if !ok || fh.special {
+		idx.fileHeaders[key] = fh
+		return nil
+	}
+	// Distinct names which have been mapped to the same one
+	if prev.rawName != fh.rawName {
+		return fmt.Errorf("%w: %q and %q both map to %q", ErrDuplicate, prev.rawName, fh.rawName, key)
+	}
+
+	switch idx.opts.duplicates {
+	case DuplicateError:
+		return fmt.Errorf("%w: %q", ErrDuplicate, fh.name)
+	case DuplicateFirst:
+		return nil
+	case DuplicateIndexed:
+		for n := 1; ; n++ {
+			name := fmt.Sprintf("%s~%d", fh.name, n)
+			if _, ok := idx.fileHeaders[idx.opts.key(name)]; !ok {
+				fh.name = name
+				idx.fileHeaders[idx.opts.key(name)] = fh
+				return nil
+			}
+		}
+	}
+	idx.fileHeaders[key] = fh
+	return nil
+}
+
+// Close releases the archive. The underlying file is closed once the archive
+// and all of its clones have been closed. Closing an archive more than once
+// has no effect.
 func (a *ARFS) Close() error {
-	return a.rawFile.Close()
+	a.refreshMu.Lock()
+	defer a.refreshMu.Unlock()
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	runtime.SetFinalizer(a, nil)
+	return a.snapshot().rawFile.release()
+}
+
+// ReaderAt returns the reader the archive is read from, for access to parts of
+// the archive not otherwise exposed, such as padding. It must not be used to
+// modify the archive, and after a Refresh it may no longer be current.
+func (a *ARFS) ReaderAt() io.ReaderAt {
+	return a.snapshot().rawFile
+}
+
+// Size returns the total length of the archive in bytes, as of when it was
+// last parsed
+func (a *ARFS) Size() int64 {
+	return a.snapshot().size
 }
 
 func (a *ARFS) getHeader(name string) (*fileHeader, bool) {
-	// normalize the name
+	return a.snapshot().lookup(name)
+}
+
+// lookup finds the member with the given name
+func (idx *index) lookup(name string) (*fileHeader, bool) {
+	header, ok := idx.fileHeaders[idx.opts.key(cleanName(name))]
+	return header, ok
+}
+
+// cleanName normalises a name for lookups
+func cleanName(name string) string {
 	name = path.Clean(name)
 	name = strings.TrimPrefix(name, "/")
-	name = strings.TrimPrefix(name, "./")
+	return strings.TrimPrefix(name, "./")
+}
 
-	header, ok := a.fileHeaders[name]
-	return header, ok
+// rawSection returns a reader over the complete on-disk form of a member: the
+// header, any extended filename, the data and the padding byte
+func (idx *index) rawSection(fh *fileHeader) *io.SectionReader {
+	end := fh.offset + fh.span
+	// The padding may be missing from the final member
+	if end > idx.size {
+		end = idx.size
+	}
+	return io.NewSectionReader(idx.readerFor(fh.name), fh.headerOffset, end-fh.headerOffset)
 }
 
-func (a *ARFS) Open(name string) (fs.File, error) {
+// RawMember returns a reader over the untouched on-disk representation of the
+// named member, covering the 60 byte header, any BSD extended filename, the
+// data and any alignment padding. GNU long filenames are stored in a separate
+// table, so are not included.
+func (a *ARFS) RawMember(name string) (*io.SectionReader, error) {
+	idx := a.snapshot()
+	fh, ok := idx.lookup(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "rawmember", Path: name, Err: fs.ErrNotExist}
+	}
+	return idx.rawSection(fh), nil
+}
+
+// RawMemberIndex is the same as RawMember, but for the i'th member as counted
+// by OpenIndex. This allows access to members with duplicated names.
+func (a *ARFS) RawMemberIndex(i int) (*io.SectionReader, error) {
+	idx := a.snapshot()
+	fh, err := idx.memberIndex(i)
+	if err != nil {
+		return nil, err
+	}
+	return idx.rawSection(fh), nil
+}
+
+// Contains reports whether the archive has a member with the given name, or a
+// directory implied by the names when using WithVirtualDirs. The name is
+// normalised in the same way as for Open, but no allocations are made, so it
+// is cheaper than Stat for existence checks. With WithNestedArchives it is
+// the same as checking Stat for an error.
+func (a *ARFS) Contains(name string) bool {
+	idx := a.snapshot()
+	if idx.opts.nestedDepth > 0 {
+		_, err := idx.statNested(name)
+		return err == nil
+	}
+	if _, ok := idx.lookup(name); ok {
+		return true
+	}
+	dir, _ := trimDirSlash(name)
+	if !idx.opts.virtualDirs || !fs.ValidPath(dir) {
+		return false
+	}
+	_, ok := idx.virtualDirs()[idx.opts.key(cleanName(dir))]
+	return ok
+}
+
+// Locate reports where the contents of the named member live within the
+// archive. offset is the position of the first byte of data (after any extended
+// filename), size is the length of the data, and paddedSize is the on-disk span
+// of the data including the alignment padding before the next header.
+func (a *ARFS) Locate(name string) (offset, size, paddedSize int64, err error) {
 	header, ok := a.getHeader(name)
 	if !ok {
-		return nil, fs.ErrNotExist
+		return 0, 0, 0, &fs.PathError{Op: "locate", Path: name, Err: fs.ErrNotExist}
+	}
+	return header.offset, header.Size(), header.span, nil
+}
+
+// Open opens the named member, or the root directory ".". Symbol indexes and
+// long filename tables aren't listed by ReadDir, but can be opened by their
+// exact names, such as "/", "//" and "__.SYMDEF". For an archive without a '/'
+// member, "/" is also the root.
+func (a *ARFS) Open(name string) (fs.File, error) {
+	idx := a.snapshot()
+	if fh, ok := idx.specialMember(name); ok {
+		return idx.track(fh.open()), nil
+	}
+	if idx.opts.nestedDepth > 0 {
+		return idx.openNested(name)
+	}
+	clean, mustBeDir := trimDirSlash(name)
+	if !idx.validPath(clean) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if header, ok := idx.lookup(clean); ok && !isRoot(clean) {
+		if mustBeDir {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: ErrNotDir}
+		}
+		f := header.open()
+		f.info = idx.memberInfo(header)
+		return idx.track(f), nil
+	}
+	if entries, ok := idx.dirEntries(clean); ok {
+		return &dirFile{info: idx.dirInfo(path.Base(cleanName(clean))), entries: entries}, nil
 	}
+	return nil, idx.notFound("open", name)
+}
 
-	return header, nil
+// specialMember finds a symbol index or long filename table by its exact name.
+// These names aren't valid paths, so aren't found by lookup, and "/" would
+// otherwise be the root.
+func (idx *index) specialMember(name string) (*fileHeader, bool) {
+	if !isSpecial(name) {
+		return nil, false
+	}
+	fh, ok := idx.fileHeaders[idx.opts.key(name)]
+	return fh, ok && fh.special
+}
+
+// track holds a reference to the archive reader until f is closed, if
+// WithAutoClose is in use
+func (idx *index) track(f *memberFile) *memberFile {
+	if idx.opts.autoClose {
+		idx.rawFile.clones.Add(1)
+		f.rawFile = idx.rawFile
+	}
+	return f
 }
 
+// visible returns the members which are files in their own right (ie: not
+// symbol indexes or long filename tables), in archive order
+func (idx *index) visible() []*fileHeader {
+	var members []*fileHeader
+	for _, fh := range idx.members {
+		if !fh.special {
+			members = append(members, fh)
+		}
+	}
+	return members
+}
+
+// memberIndex returns the i'th visible member in archive order
+func (idx *index) memberIndex(i int) (*fileHeader, error) {
+	members := idx.visible()
+	if i < 0 || i >= len(members) {
+		return nil, fmt.Errorf("%w: %d of %d", ErrOutOfRange, i, len(members))
+	}
+	return members[i], nil
+}
+
+// OpenIndex opens the i'th member of the archive, counting from zero in the
+// order the members appear in the archive. Symbol indexes and long filename
+// tables are not counted, matching ReadDir. This allows access to members whose
+// names are duplicated.
+func (a *ARFS) OpenIndex(i int) (fs.File, error) {
+	idx := a.snapshot()
+	fh, err := idx.memberIndex(i)
+	if err != nil {
+		return nil, err
+	}
+	return idx.track(fh.open()), nil
+}
+
+// HeaderIndex returns the header of the i'th member of the archive, counted in
+// the same way as OpenIndex.
+func (a *ARFS) HeaderIndex(i int) (Header, error) {
+	fh, err := a.snapshot().memberIndex(i)
+	if err != nil {
+		return Header{}, err
+	}
+	return fh.header(), nil
+}
+
+// List returns the headers of every member in archive order, including any
+// with duplicated names. Symbol indexes and long filename tables are not
+// included, so the i'th header describes the member opened by OpenIndex(i).
+func (a *ARFS) List() []Header {
+	members := a.snapshot().visible()
+	headers := make([]Header, len(members))
+	for i, fh := range members {
+		headers[i] = fh.header()
+	}
+	return headers
+}
+
+// WalkFiles calls fn for each member in archive order, with a reader over its
+// contents which is independent of any other. Walking stops at the first error
+// returned by fn, which is returned from WalkFiles, except for fs.SkipAll which
+// stops the walk without an error.
+func (a *ARFS) WalkFiles(fn func(info fs.FileInfo, r io.Reader) error) error {
+	for _, fh := range a.snapshot().visible() {
+		if err := fn(fh, fh.open()); err != nil {
+			if errors.Is(err, fs.SkipAll) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Range calls fn with the name & header of each member in archive order,
+// stopping early if fn returns false. The members are those of the archive
+// when Range was called, so a concurrent Refresh doesn't affect the iteration,
+// and no lock is held while fn runs, so it may use the archive freely.
+func (a *ARFS) Range(fn func(name string, h Header) bool) {
+	for _, fh := range a.snapshot().visible() {
+		if !fn(fh.name, fh.header()) {
+			return
+		}
+	}
+}
+
+// RangeErr is the same as Range, but stops at the first error returned by fn,
+// which is returned from RangeErr, except for fs.SkipAll which stops without
+// an error.
+func (a *ARFS) RangeErr(fn func(name string, h Header) error) error {
+	for _, fh := range a.snapshot().visible() {
+		if err := fn(fh.name, fh.header()); err != nil {
+			if errors.Is(err, fs.SkipAll) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadDir returns the files in the archive, sorted by name
 func (a *ARFS) ReadDir(name string) ([]fs.DirEntry, error) {
-	// ar archives don't have subfolders
-	if name != "/" && name != "." {
+	if idx := a.snapshot(); idx.opts.nestedDepth > 0 {
+		return idx.readDirNested(name)
+	}
+	// ar archives don't have subfolders, unless they are implied by the names
+	idx := a.snapshot()
+	clean, _ := trimDirSlash(name)
+	if !idx.validPath(clean) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, ok := idx.dirEntries(clean)
+	if !ok {
 		return nil, fs.ErrNotExist
 	}
+	return entries, nil
+}
+
+// rootEntries returns the files in the archive, sorted by name
+func (idx *index) rootEntries() []fs.DirEntry {
 	var ret []fs.DirEntry
-	for _, f := range a.fileHeaders {
+	for _, f := range idx.fileHeaders {
+		// symbol indexes & name tables aren't files in their own right
+		if f.special {
+			continue
+		}
 		ret = append(ret, f)
 	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name() < ret[j].Name()
+	})
+	return ret
+}
 
-	return ret, nil
+// glob returns the files in the archive matching pattern, sorted by name. The
+// pattern is checked up front, so a bad one is reported even if there are no
+// files.
+func (idx *index) glob(pattern string) ([]*fileHeader, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	var matches []*fileHeader
+	for _, f := range idx.fileHeaders {
+		if f.special {
+			continue
+		}
+		if match, _ := filepath.Match(pattern, f.name); match {
+			matches = append(matches, f)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].name < matches[j].name
+	})
+	return matches, nil
 }
 
+// Glob returns the sorted names of all files in the archive matching pattern
 func (a *ARFS) Glob(pattern string) ([]string, error) {
+	if opts := a.snapshot().opts; opts.nestedDepth > 0 || opts.virtualDirs {
+		return fs.Glob(nestedGlob{a}, pattern)
+	}
+	matches, err := a.snapshot().glob(pattern)
+	if err != nil {
+		return nil, err
+	}
 	var fileList []string
-	for name := range a.fileHeaders {
-		match, err := filepath.Match(pattern, name)
+	for _, f := range matches {
+		fileList = append(fileList, f.name)
+	}
+	return fileList, nil
+}
+
+// GlobEntries is the same as Glob, but returns the matching files as
+// fs.DirEntry, saving a Stat of each one
+func (a *ARFS) GlobEntries(pattern string) ([]fs.DirEntry, error) {
+	if opts := a.snapshot().opts; opts.nestedDepth > 0 || opts.virtualDirs {
+		names, err := fs.Glob(nestedGlob{a}, pattern)
 		if err != nil {
 			return nil, err
 		}
-		if match {
-			fileList = append(fileList, name)
+		var entries []fs.DirEntry
+		for _, name := range names {
+			info, err := a.Stat(name)
+			if err != nil {
+				return nil, err
+			}
+			entry, ok := info.(fs.DirEntry)
+			if !ok {
+				entry = fs.FileInfoToDirEntry(info)
+			}
+			entries = append(entries, entry)
 		}
+		return entries, nil
 	}
-	return fileList, nil
+	matches, err := a.snapshot().glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var entries []fs.DirEntry
+	for _, f := range matches {
+		entries = append(entries, f)
+	}
+	return entries, nil
+}
+
+// Names returns the sorted names of the files in the archive matching pattern,
+// or of all the files if pattern is empty
+func (a *ARFS) Names(pattern string) ([]string, error) {
+	if pattern != "" {
+		return a.Glob(pattern)
+	}
+	var names []string
+	for _, f := range a.snapshot().fileHeaders {
+		if !f.special {
+			names = append(names, f.name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Find returns the FileInfo of every file in the archive for which pred returns
+// true, sorted by name. The member contents are not read.
+func (a *ARFS) Find(pred func(fs.FileInfo) bool) []fs.FileInfo {
+	var found []fs.FileInfo
+	for _, f := range a.snapshot().fileHeaders {
+		if !f.special && pred(f) {
+			found = append(found, f)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Name() < found[j].Name()
+	})
+	return found
 }
 
 func (a *ARFS) ReadFile(name string) ([]byte, error) {
@@ -276,35 +985,223 @@ func (a *ARFS) ReadFile(name string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return io.ReadAll(f)
+	defer f.Close()
+	opts := a.snapshot().opts
+	// Members have a known size, so can be read without growing the buffer
+	if mf, ok := f.(*memberFile); ok {
+		if err := opts.checkReadSize(name, mf.Size()); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, mf.Size())
+		n, err := io.ReadFull(mf, buf)
+		return buf[:n], err
+	}
+	if opts.maxReadFileSize <= 0 {
+		return io.ReadAll(f)
+	}
+	// Read one byte more than the limit, to tell if it was exceeded
+	data, err := io.ReadAll(io.LimitReader(f, opts.maxReadFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.checkReadSize(name, int64(len(data))); err != nil {
+		return nil, err
+	}
+	return data, nil
 }
 
-func (a *ARFS) Stat(name string) (fs.FileInfo, error) {
+// ReadFileInto reads the contents of the named member into buf, returning the
+// number of bytes read. No allocations are made, so combined with reusing buf
+// this is cheaper than ReadFile for many small members. If buf is too small,
+// nothing is read and io.ErrShortBuffer is returned along with the size of the
+// member, so that the caller can grow buf and try again.
+func (a *ARFS) ReadFileInto(name string, buf []byte) (int, error) {
 	fh, ok := a.getHeader(name)
 	if !ok {
-		return nil, fs.ErrNotExist
+		return 0, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
 	}
-	return fh, nil
+	size := fh.Size()
+	if int64(len(buf)) < size {
+		return int(size), io.ErrShortBuffer
+	}
+	if err := fh.readInto(buf[:size]); err != nil {
+		return 0, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return int(size), nil
 }
 
-func (fh *fileHeader) Stat() (fs.FileInfo, error) {
-	return fh, nil
+// CopyTo streams the contents of the named member to w, returning the number of
+// bytes copied. If the archive is truncated and the member is shorter than its
+// declared size, io.ErrUnexpectedEOF is returned. It is safe to call CopyTo
+// concurrently.
+func (a *ARFS) CopyTo(name string, w io.Writer) (int64, error) {
+	fh, ok := a.getHeader(name)
+	if !ok {
+		return 0, &fs.PathError{Op: "copy", Path: name, Err: fs.ErrNotExist}
+	}
+	n, err := fh.copyTo(context.Background(), w)
+	if err != nil {
+		return n, &fs.PathError{Op: "copy", Path: name, Err: err}
+	}
+	return n, nil
+}
+
+func (a *ARFS) Stat(name string) (fs.FileInfo, error) {
+	if fh, ok := a.snapshot().specialMember(name); ok {
+		return fh, nil
+	}
+	if idx := a.snapshot(); idx.opts.nestedDepth > 0 {
+		return idx.statNested(name)
+	}
+	idx := a.snapshot()
+	clean, mustBeDir := trimDirSlash(name)
+	if !idx.validPath(clean) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if fh, ok := idx.lookup(clean); ok && !isRoot(clean) {
+		if mustBeDir {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: ErrNotDir}
+		}
+		return idx.memberInfo(fh), nil
+	}
+	if _, ok := idx.dirEntries(clean); ok {
+		return idx.dirInfo(path.Base(cleanName(clean))), nil
+	}
+	return nil, idx.notFound("stat", name)
 }
 
-func (fh *fileHeader) Read(data []byte) (int, error) {
-	return fh.sectionReader.Read(data)
+// reader returns a new reader over the member contents, which is independent
+// of the position of any other reader on the same member.
+func (fh *fileHeader) reader() *io.SectionReader {
+	return io.NewSectionReader(fh.sectionReader, 0, fh.sectionReader.Size())
 }
 
-func (fh *fileHeader) Close() error {
-	return nil
+// readInto reads the start of the member contents into buf, returning
+// io.ErrUnexpectedEOF if the archive is truncated
+func (fh *fileHeader) readInto(buf []byte) error {
+	n, err := fh.sectionReader.ReadAt(buf, 0)
+	if n == len(buf) {
+		return nil
+	}
+	if err == nil || errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
 }
 
-func (fh *fileHeader) ReadAt(p []byte, off int64) (n int, err error) {
-	return fh.sectionReader.ReadAt(p, off)
+// readFile reads the complete contents of a member for ReadFile, checking its
+// size against WithMaxReadFileSize first. As with ARFS.ReadFile, a truncated
+// member gives the data which is available along with io.ErrUnexpectedEOF.
+func (idx *index) readFile(name string, fh *fileHeader) ([]byte, error) {
+	if err := idx.opts.checkReadSize(name, fh.Size()); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, fh.Size())
+	n, err := io.ReadFull(fh.open(), buf)
+	return buf[:n], err
 }
 
-func (fh *fileHeader) Seek(offset int64, whence int) (int64, error) {
-	return fh.sectionReader.Seek(offset, whence)
+// contextReader fails reads once its context has been cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// cancelled reports that an operation on many members was abandoned because
+// ctx was cancelled, and how far it got
+func cancelled(ctx context.Context, done, total int) error {
+	return fmt.Errorf("%w after %d of %d members", ctx.Err(), done, total)
+}
+
+// copyTo writes the member contents to w, using an independent reader. The
+// copy is abandoned if ctx is cancelled.
+func (fh *fileHeader) copyTo(ctx context.Context, w io.Writer) (int64, error) {
+	var r io.Reader = fh.reader()
+	if ctx.Done() != nil {
+		r = &contextReader{ctx: ctx, r: r}
+	}
+	n, err := io.CopyN(w, r, fh.Size())
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// open returns a new fs.File for the member, with its own read position
+func (fh *fileHeader) open() *memberFile {
+	return &memberFile{SectionReader: fh.reader(), info: fh}
+}
+
+// header returns the exported description of the member
+func (fh *fileHeader) header() Header {
+	return Header{
+		Name:         fh.name,
+		RawName:      fh.rawName,
+		Size:         fh.Size(),
+		Mode:         fh.mode,
+		ModTime:      fh.modification,
+		UID:          int(fh.owner),
+		GID:          int(fh.group),
+		Offset:       fh.offset,
+		HeaderOffset: fh.headerOffset,
+	}
+}
+
+// memberFile is an open member of the archive. It also implements io.ReaderAt
+// and io.Seeker.
+type memberFile struct {
+	*io.SectionReader
+	// info is returned by Stat, which is the member itself unless it is in a
+	// virtual directory
+	info fs.FileInfo
+
+	// rawFile is released on Close when using WithAutoClose
+	rawFile *arfsReader
+	closed  atomic.Bool
+}
+
+// truncated converts an early io.EOF into io.ErrUnexpectedEOF, so that members
+// which are cut short by the end of the archive aren't mistaken for complete
+func (f *memberFile) truncated(end int64, err error) error {
+	if errors.Is(err, io.EOF) && end < f.Size() {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (f *memberFile) Read(p []byte) (int, error) {
+	n, err := f.SectionReader.Read(p)
+	if err != nil {
+		pos, _ := f.SectionReader.Seek(0, io.SeekCurrent)
+		err = f.truncated(pos, err)
+	}
+	return n, err
+}
+
+func (f *memberFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.SectionReader.ReadAt(p, off)
+	if err != nil {
+		err = f.truncated(off+int64(n), err)
+	}
+	return n, err
+}
+
+func (f *memberFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *memberFile) Close() error {
+	if f.rawFile != nil && f.closed.CompareAndSwap(false, true) {
+		return f.rawFile.release()
+	}
+	return nil
 }
 
 func (fh *fileHeader) Name() string {
@@ -320,6 +1217,9 @@ func (fh *fileHeader) Mode() fs.FileMode {
 }
 
 func (fh *fileHeader) ModTime() time.Time {
+	if fh.zeroModTime {
+		return time.Time{}
+	}
 	return fh.modification
 }
 
@@ -331,8 +1231,18 @@ func (fh *fileHeader) Sys() any {
 	return nil
 }
 
+// UID returns the owner of the member, as part of ARFileInfo
+func (fh *fileHeader) UID() int {
+	return int(fh.owner)
+}
+
+// GID returns the group of the member, as part of ARFileInfo
+func (fh *fileHeader) GID() int {
+	return int(fh.group)
+}
+
 func (fh *fileHeader) Type() fs.FileMode {
-	return fs.FileMode(fh.mode)
+	return fh.Mode().Type()
 }
 
 func (fh *fileHeader) Info() (fs.FileInfo, error) {
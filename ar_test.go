@@ -1,8 +1,18 @@
 package goarfs
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestARFile(t *testing.T) {
@@ -78,3 +88,1125 @@ func TestExtended(t *testing.T) {
 		t.Fatalf("%q has wrong size: %d", stat.Name(), stat.Size())
 	}
 }
+
+type testMember struct {
+	name    string
+	data    string
+	mode    uint32
+	modtime int64
+}
+
+// buildArchive constructs a BSD style AR archive in memory from the given
+// members, using the '#1/' extended name format for names which cannot be
+// stored directly in the header
+func buildArchive(t testing.TB, members ...testMember) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString("!<arch>\n")
+	for _, m := range members {
+		name := m.name
+		data := m.data
+		if len(name) > 16 || strings.HasPrefix(name, "/") || strings.Contains(name, " ") {
+			data = name + data
+			name = fmt.Sprintf("#1/%d", len(m.name))
+		}
+		mode := m.mode
+		if mode == 0 {
+			mode = 0o100644
+		}
+		fmt.Fprintf(&buf, "%-16s%-12d%-6d%-6d%-8o%-10d`\n", name, m.modtime, 0, 0, mode, len(data))
+		buf.WriteString(data)
+		if len(data)%2 != 0 {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestMissingPadding(t *testing.T) {
+	ar, err := FromFile("testdata/nopad_final.ar")
+	if err != nil {
+		t.Fatalf("missing final padding should be tolerated: %s", err)
+	}
+	defer ar.Close()
+	data, err := ar.ReadFile("test2.dat")
+	if err != nil {
+		t.Fatalf("cannot read test2.dat: %s", err)
+	}
+	if string(data) != "123" {
+		t.Fatalf("test2.dat has wrong contents: %q", data)
+	}
+
+	_, err = FromFile("testdata/nopad_internal.ar")
+	if !errors.Is(err, ErrMissingPadding) {
+		t.Fatalf("missing internal padding should fail with ErrMissingPadding: %v", err)
+	}
+	if !strings.Contains(err.Error(), "odd.dat") {
+		t.Fatalf("error should name the offending member: %s", err)
+	}
+}
+
+func TestNULInHeaderField(t *testing.T) {
+	raw := buildArchive(t, testMember{name: "nul.dat", data: "0123456789"})
+	// Lace the size field (bytes 48-58 of the header) with a NUL
+	copy(raw[8+48:], "1\x000")
+	_, err := FromInterface(bytes.NewReader(raw))
+	if !errors.Is(err, ErrBadFileHeader) {
+		t.Fatalf("NUL in size field should fail with ErrBadFileHeader: %v", err)
+	}
+	if !strings.Contains(err.Error(), "size field") || !strings.Contains(err.Error(), "nul.dat") {
+		t.Fatalf("error should name the field and member: %s", err)
+	}
+}
+
+func TestNULPaddedNames(t *testing.T) {
+	raw := buildArchive(t, testMember{name: "arm.o", data: "arm"}, testMember{name: "eabi.o", data: "eabi"})
+	// Pad the names with NULs, as some older ARM toolchains do
+	copy(raw[8:], "arm.o\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+	copy(raw[8+60+4:], "eabi.o\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+	for _, opts := range [][]Option{nil, {WithRawNames()}} {
+		ar, err := FromInterface(bytes.NewReader(raw), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names, _ := ar.Names(""); strings.Join(names, " ") != "arm.o eabi.o" {
+			t.Fatalf("NUL padding should be trimmed from names: %q", names)
+		}
+		if data, err := ar.ReadFile("eabi.o"); err != nil || string(data) != "eabi" {
+			t.Fatalf("bad contents: %q %v", data, err)
+		}
+	}
+}
+
+func TestBlankHeaderFields(t *testing.T) {
+	ar, err := FromFile("testdata/blank_fields.a")
+	if err != nil {
+		t.Fatalf("blank metadata fields should be accepted: %s", err)
+	}
+	defer ar.Close()
+	data, err := ar.ReadFile("blank.txt")
+	if err != nil || string(data) != "blank fields\n" {
+		t.Fatalf("bad contents: %q %v", data, err)
+	}
+	for _, h := range ar.List() {
+		if !h.ModTime.Equal(time.Unix(0, 0)) || h.UID != 0 || h.GID != 0 || h.Mode != 0 {
+			t.Errorf("%s should have zeroed metadata: %+v", h.Name, h)
+		}
+	}
+}
+
+func TestLocate(t *testing.T) {
+	ar, err := FromFile("testdata/extended.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	// Walk the headers by hand: signature, then each header followed by the
+	// extended name (if any), the data and a pad byte to an even offset
+	pos := int64(len(goodSignature))
+	for _, m := range []struct {
+		name    string
+		nameLen int64
+		size    int64
+	}{
+		{"__.SYMDEF SORTED", 20, 8},
+		{"zeros", 0, 1024},
+		{"this_is_a_file_with_a_massive_filename", 40, 127},
+	} {
+		total := m.nameLen + m.size
+		padded := total + total%2 - m.nameLen
+		offset, size, paddedSize, err := ar.Locate(m.name)
+		if err != nil {
+			t.Fatalf("cannot locate %q: %s", m.name, err)
+		}
+		if offset != pos+headerSize+m.nameLen || size != m.size || paddedSize != padded {
+			t.Fatalf("%q: got offset=%d size=%d padded=%d, expected %d/%d/%d", m.name,
+				offset, size, paddedSize, pos+headerSize+m.nameLen, m.size, padded)
+		}
+		pos = offset + paddedSize
+	}
+
+	if _, _, _, err := ar.Locate("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("locating a missing member should fail with ErrNotExist: %v", err)
+	}
+}
+
+func TestGNU(t *testing.T) {
+	ar, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	files, err := ar.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("gnu.a should list 3 files (no symbol or name tables), has %d", len(files))
+	}
+	data, err := ar.ReadFile("notes_with_a_long_name.txt")
+	if err != nil {
+		t.Fatalf("cannot read long GNU name: %s", err)
+	}
+	if string(data) != "hello\n" {
+		t.Fatalf("long GNU name has wrong contents: %q", data)
+	}
+	if _, err := ar.Stat("short.o"); err != nil {
+		t.Fatalf("cannot stat short GNU name: %s", err)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	ar, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	manifest := ar.Manifest()
+	if len(manifest) != 2 {
+		t.Fatalf("manifest should have 2 entries, has %d", len(manifest))
+	}
+	if manifest[0].Name != "test1.dat" || manifest[0].Size != 26 || manifest[0].UID != 501 || manifest[0].GID != 20 {
+		t.Fatalf("bad first manifest entry: %#v", manifest[0])
+	}
+	if manifest[1].Name != "test2.dat" || manifest[1].Mode != 0o100644 || !manifest[1].ModTime.Equal(time.Unix(1694666847, 0)) {
+		t.Fatalf("bad second manifest entry: %#v", manifest[1])
+	}
+}
+
+func TestContains(t *testing.T) {
+	ar, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	for name, expected := range map[string]bool{
+		"test1.dat":   true,
+		"/test2.dat":  true,
+		"./test1.dat": true,
+		"test3.dat":   false,
+		"":            false,
+	} {
+		if ar.Contains(name) != expected {
+			t.Errorf("Contains(%q) should be %v", name, expected)
+		}
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		ar.Contains("test1.dat")
+		ar.Contains("missing.dat")
+	})
+	if allocs != 0 {
+		t.Fatalf("Contains should not allocate, got %v allocations", allocs)
+	}
+
+	virtual, err := FromInterface(bytes.NewReader(buildArchive(t,
+		testMember{name: "sub/a.txt", data: "a"},
+	)), WithVirtualDirs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]bool{
+		"sub/a.txt": true,
+		"sub":       true,
+		".":         true,
+		"a.txt":     false,
+		"":          false,
+		"sub/":      true,
+	} {
+		if virtual.Contains(name) != expected {
+			t.Errorf("Contains(%q) should be %v with virtual directories", name, expected)
+		}
+	}
+	allocs = testing.AllocsPerRun(100, func() {
+		virtual.Contains("sub")
+		virtual.Contains("missing")
+	})
+	if allocs != 0 {
+		t.Fatalf("Contains should not allocate with virtual directories, got %v allocations", allocs)
+	}
+}
+
+func TestOpenIndex(t *testing.T) {
+	raw := buildArchive(t,
+		testMember{name: "foo.o", data: "first"},
+		testMember{name: "bar.o", data: "bar"},
+		testMember{name: "foo.o", data: "second"},
+	)
+	ar, err := FromInterface(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []string{"first", "bar", "second"} {
+		f, err := ar.OpenIndex(i)
+		if err != nil {
+			t.Fatalf("cannot open member %d: %s", i, err)
+		}
+		data, err := io.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != expected {
+			t.Errorf("member %d has wrong contents: %q", i, data)
+		}
+		hdr, err := ar.HeaderIndex(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size != int64(len(expected)) {
+			t.Errorf("member %d has wrong size: %d", i, hdr.Size)
+		}
+	}
+	for _, i := range []int{-1, 3} {
+		if _, err := ar.OpenIndex(i); !errors.Is(err, ErrOutOfRange) {
+			t.Errorf("OpenIndex(%d) should be out of range: %v", i, err)
+		}
+		if _, err := ar.HeaderIndex(i); !errors.Is(err, ErrOutOfRange) {
+			t.Errorf("HeaderIndex(%d) should be out of range: %v", i, err)
+		}
+	}
+
+	// Symbol & name tables aren't counted
+	ar, err = FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	hdr, err := ar.HeaderIndex(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Name != "short.o" {
+		t.Fatalf("first member of gnu.a should be short.o, not %q", hdr.Name)
+	}
+}
+
+func TestRawMember(t *testing.T) {
+	for _, test := range []struct {
+		filename string
+		first    string
+	}{
+		{"testdata/gnu_roundtrip.a", "readme.txt/"},
+		{"testdata/extended.ar", "zeros "},
+		{"testdata/test1.ar", "test1.dat "},
+	} {
+		original, err := os.ReadFile(test.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ar, err := FromInterface(bytes.NewReader(original))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The raw members should tile the archive exactly from the first
+		// regular member through to the end
+		var joined []byte
+		for i := 0; ; i++ {
+			r, err := ar.RawMemberIndex(i)
+			if errors.Is(err, ErrOutOfRange) {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data)%2 != 0 {
+				t.Errorf("%s: member %d has odd raw length %d", test.filename, i, len(data))
+			}
+			joined = append(joined, data...)
+		}
+		start := bytes.Index(original, []byte(test.first))
+		if !bytes.Equal(joined, original[start:]) {
+			t.Errorf("%s: raw members don't match the archive contents", test.filename)
+		}
+	}
+
+	ar, err := FromFile("testdata/extended.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	r, err := ar.RawMember("this_is_a_file_with_a_massive_filename")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// header + 40 byte name + 127 bytes of data + padding
+	if r.Size() != headerSize+40+127+1 {
+		t.Fatalf("raw member has wrong size: %d", r.Size())
+	}
+	if _, err := ar.RawMember("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("raw member of missing file should fail with ErrNotExist: %v", err)
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	data := buildArchive(t, testMember{name: "Foo.O", data: "foo"}, testMember{name: "bar.o", data: "bar"})
+	ar, err := FromInterface(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ar.Contains("foo.o") {
+		t.Fatalf("lookups should be case sensitive by default")
+	}
+
+	ar, err = FromInterface(bytes.NewReader(data), WithCaseInsensitive())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"foo.o", "Foo.O", "FOO.o", "./foo.o", "BAR.O"} {
+		if !ar.Contains(name) {
+			t.Errorf("case insensitive lookup of %q failed", name)
+		}
+	}
+	stat, err := ar.Stat("foo.o")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Name() != "Foo.O" {
+		t.Fatalf("stat should report the stored name, got %q", stat.Name())
+	}
+	names, err := ar.Glob("*.O")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, " ") != "Foo.O" {
+		t.Fatalf("glob should match stored names: %v", names)
+	}
+}
+
+func TestCaseInsensitiveDuplicate(t *testing.T) {
+	data := buildArchive(t, testMember{name: "readme", data: "lower"}, testMember{name: "README", data: "upper"})
+	if _, err := FromInterface(bytes.NewReader(data)); err != nil {
+		t.Fatalf("names differing by case are distinct by default: %s", err)
+	}
+	if _, err := FromInterface(bytes.NewReader(data), WithCaseInsensitive()); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("names differing by case should fail with ErrDuplicate: %v", err)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	for _, test := range []struct {
+		policy   DuplicatePolicy
+		names    string
+		contents string
+	}{
+		{DuplicateLast, "dup.txt other.txt", "third\n"},
+		{DuplicateFirst, "dup.txt other.txt", "first copy\n"},
+		{DuplicateIndexed, "dup.txt dup.txt~1 dup.txt~2 other.txt", "first copy\n"},
+	} {
+		ar, err := FromFile("testdata/duplicates.a", WithDuplicates(test.policy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ar.Close()
+		entries, err := ar.ReadDir(".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		if strings.Join(names, " ") != test.names {
+			t.Errorf("policy %d: expected %q, got %q", test.policy, test.names, names)
+		}
+		data, err := ar.ReadFile("dup.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != test.contents {
+			t.Errorf("policy %d: dup.txt should contain %q, got %q", test.policy, test.contents, data)
+		}
+
+		// Archive order is always preserved
+		for i, expected := range []string{"first copy\n", "other\n", "second copy\n", "third\n"} {
+			f, err := ar.OpenIndex(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != expected {
+				t.Errorf("policy %d: member %d should contain %q, got %q", test.policy, i, expected, data)
+			}
+		}
+	}
+
+	ar, err := FromFile("testdata/duplicates.a", WithDuplicates(DuplicateIndexed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	data, err := ar.ReadFile("dup.txt~1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "second copy\n" {
+		t.Fatalf("dup.txt~1 should be the second copy, got %q", data)
+	}
+
+	if _, err := FromFile("testdata/duplicates.a", WithDuplicates(DuplicateError)); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("duplicates should fail with ErrDuplicate: %v", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	ar, err := FromFile("testdata/duplicates.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	headers := ar.List()
+	var names []string
+	for i, hdr := range headers {
+		names = append(names, hdr.Name)
+		indexed, err := ar.HeaderIndex(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if indexed != hdr {
+			t.Errorf("member %d: List and HeaderIndex disagree: %#v vs %#v", i, hdr, indexed)
+		}
+	}
+	if strings.Join(names, " ") != "dup.txt other.txt dup.txt dup.txt" {
+		t.Fatalf("List should include duplicates in archive order: %v", names)
+	}
+	if headers[2].Size != int64(len("second copy\n")) {
+		t.Fatalf("second dup.txt has wrong size: %d", headers[2].Size)
+	}
+	if _, err := ar.OpenIndex(len(headers)); !errors.Is(err, ErrOutOfRange) {
+		t.Fatalf("OpenIndex past the end of List should be out of range: %v", err)
+	}
+}
+
+func TestWalkFiles(t *testing.T) {
+	ar, err := FromFile("testdata/duplicates.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	var all []byte
+	var names []string
+	err = ar.WalkFiles(func(info fs.FileInfo, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if int64(len(data)) != info.Size() {
+			t.Errorf("%s: read %d bytes, expected %d", info.Name(), len(data), info.Size())
+		}
+		names = append(names, info.Name())
+		all = append(all, data...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(all) != "first copy\nother\nsecond copy\nthird\n" {
+		t.Fatalf("walk gave wrong contents: %q", all)
+	}
+	if strings.Join(names, " ") != "dup.txt other.txt dup.txt dup.txt" {
+		t.Fatalf("walk gave wrong names: %v", names)
+	}
+
+	count := 0
+	err = ar.WalkFiles(func(info fs.FileInfo, r io.Reader) error {
+		count++
+		return fs.SkipAll
+	})
+	if err != nil || count != 1 {
+		t.Fatalf("SkipAll should stop the walk without an error: %d %v", count, err)
+	}
+	stop := errors.New("stop")
+	if err := ar.WalkFiles(func(info fs.FileInfo, r io.Reader) error { return stop }); !errors.Is(err, stop) {
+		t.Fatalf("walk should return the callback error: %v", err)
+	}
+}
+
+func TestRange(t *testing.T) {
+	ar, err := FromFile("testdata/duplicates.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	var names []string
+	ar.Range(func(name string, h Header) bool {
+		names = append(names, name)
+		// The archive can be used, and even refreshed, during the iteration
+		if _, err := ar.ReadFile(name); err != nil {
+			t.Errorf("cannot read %q during Range: %s", name, err)
+		}
+		if err := ar.Refresh(); err != nil {
+			t.Errorf("cannot refresh during Range: %s", err)
+		}
+		return true
+	})
+	if strings.Join(names, " ") != "dup.txt other.txt dup.txt dup.txt" {
+		t.Fatalf("range gave wrong names: %v", names)
+	}
+
+	count := 0
+	ar.Range(func(name string, h Header) bool {
+		count++
+		return count < 2
+	})
+	if count != 2 {
+		t.Fatalf("range should stop when fn returns false, called %d times", count)
+	}
+
+	stop := errors.New("stop")
+	count = 0
+	err = ar.RangeErr(func(name string, h Header) error {
+		count++
+		return stop
+	})
+	if !errors.Is(err, stop) || count != 1 {
+		t.Fatalf("RangeErr should stop and return the callback error: %d %v", count, err)
+	}
+	if err := ar.RangeErr(func(name string, h Header) error { return fs.SkipAll }); err != nil {
+		t.Fatalf("SkipAll should stop RangeErr without an error: %v", err)
+	}
+}
+
+func TestNameNormalizer(t *testing.T) {
+	normalize := func(raw string) (string, bool) {
+		if !strings.HasPrefix(raw, "payload_") {
+			return "", false
+		}
+		return strings.ReplaceAll(strings.TrimPrefix(raw, "payload_"), "\\", "_"), true
+	}
+	raw := buildArchive(t,
+		testMember{name: "payload_a.txt", data: "a"},
+		testMember{name: "junk.txt", data: "junk"},
+		testMember{name: "payload_dir\\b.txt", data: "b"},
+	)
+	ar, err := FromInterface(bytes.NewReader(raw), WithNameNormalizer(normalize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, hdr := range ar.List() {
+		names = append(names, hdr.Name+"="+hdr.RawName)
+	}
+	if strings.Join(names, " ") != "a.txt=payload_a.txt dir_b.txt=payload_dir\\b.txt" {
+		t.Fatalf("bad normalised names: %v", names)
+	}
+	data, err := ar.ReadFile("dir_b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "b" {
+		t.Fatalf("dir_b.txt has wrong contents: %q", data)
+	}
+	if ar.Contains("junk.txt") || ar.Contains("payload_a.txt") {
+		t.Fatalf("dropped & raw names should not be found")
+	}
+
+	raw = buildArchive(t,
+		testMember{name: "payload_a.txt", data: "a"},
+		testMember{name: "payload_dir\\b.txt", data: "b"},
+		testMember{name: "payload_dir_b.txt", data: "c"},
+	)
+	if _, err := FromInterface(bytes.NewReader(raw), WithNameNormalizer(normalize)); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("names which collide after normalisation should fail with ErrDuplicate: %v", err)
+	}
+}
+
+func TestReadFileInto(t *testing.T) {
+	ar, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	buf := make([]byte, 64)
+	n, err := ar.ReadFileInto("test2.dat", buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "123" {
+		t.Fatalf("bad contents: %q", buf[:n])
+	}
+
+	n, err = ar.ReadFileInto("test1.dat", buf[:10])
+	if !errors.Is(err, io.ErrShortBuffer) || n != 26 {
+		t.Fatalf("small buffer should give ErrShortBuffer and the required size: %d %v", n, err)
+	}
+	if _, err := ar.ReadFileInto("missing", buf); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("missing member should fail with ErrNotExist: %v", err)
+	}
+	if allocs := testing.AllocsPerRun(100, func() {
+		if _, err := ar.ReadFileInto("test1.dat", buf); err != nil {
+			t.Fatal(err)
+		}
+	}); allocs != 0 {
+		t.Fatalf("ReadFileInto should not allocate, got %v", allocs)
+	}
+}
+
+func TestMaxReadFileSize(t *testing.T) {
+	// huge.bin claims to be nearly 2GB, but the archive ends straight after
+	// its header
+	raw := "!<arch>\n" +
+		"small.txt/      0           0     0     644     5         `\nhello\n" +
+		"huge.bin/       0           0     0     644     2000000000`\n"
+	ar, err := FromInterface(strings.NewReader(raw), WithMaxReadFileSize(1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ar.ReadFile("huge.bin")
+	var pathErr *fs.PathError
+	if !errors.Is(err, ErrTooLarge) || !errors.As(err, &pathErr) || pathErr.Path != "huge.bin" {
+		t.Fatalf("huge member should fail with ErrTooLarge naming it: %v", err)
+	}
+	if err := ar.ReadFileFunc("huge.bin", func([]byte) error { return nil }); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("ReadFileFunc should also be limited: %v", err)
+	}
+	if data, err := ar.ReadFile("small.txt"); err != nil || string(data) != "hello" {
+		t.Fatalf("small member should still be read: %q %v", data, err)
+	}
+
+	// Streaming isn't limited
+	f, err := ar.Open("huge.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.Size() != 2000000000 {
+		t.Fatalf("huge member should still open: %v", err)
+	}
+}
+
+func BenchmarkReadFile(b *testing.B) {
+	ar, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer ar.Close()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ar.ReadFile("test1.dat"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadFileInto(b *testing.B) {
+	ar, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer ar.Close()
+	buf := make([]byte, 64)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ar.ReadFileInto("test1.dat", buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestReadFileFunc(t *testing.T) {
+	pool := &sync.Pool{}
+	ar, err := FromFile("testdata/test1.ar", WithBufferPool(pool))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	var got string
+	if err := ar.ReadFileFunc("test2.dat", func(data []byte) error {
+		got = string(data)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got != "123" {
+		t.Fatalf("bad contents: %q", got)
+	}
+	if bp, ok := pool.Get().(*[]byte); !ok || cap(*bp) < 3 {
+		t.Fatalf("buffer should be returned to the pool")
+	}
+
+	errStop := errors.New("stop")
+	if err := ar.ReadFileFunc("test1.dat", func([]byte) error { return errStop }); !errors.Is(err, errStop) {
+		t.Fatalf("error from fn should be returned: %v", err)
+	}
+	if err := ar.ReadFileFunc("missing", func([]byte) error { return nil }); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("missing member should fail with ErrNotExist: %v", err)
+	}
+}
+
+func BenchmarkReadFileFunc(b *testing.B) {
+	ar, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer ar.Close()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := ar.ReadFileFunc("test1.dat", func([]byte) error { return nil }); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func TestCopyTo(t *testing.T) {
+	raw, err := os.ReadFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar, err := FromInterface(seekOnly{bytes.NewReader(raw)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var out bytes.Buffer
+			n, err := ar.CopyTo("test1.dat", &out)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if n != 26 || out.String() != "abcdefghijklmnopqrstuvwxyz" {
+				t.Errorf("bad copy: %d %q", n, out.String())
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Chop off the last two bytes of data from test2.dat
+	ar, err = FromInterface(bytes.NewReader(raw[:len(raw)-3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := ar.CopyTo("test2.dat", io.Discard); !errors.Is(err, io.ErrUnexpectedEOF) || n != 1 {
+		t.Fatalf("truncated member should fail with ErrUnexpectedEOF: %d %v", n, err)
+	}
+	if _, err := ar.CopyTo("missing", io.Discard); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("missing member should fail with ErrNotExist: %v", err)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	for filename, members := range map[string]int{
+		"testdata/test1.ar":       2,
+		"testdata/duplicates.a":   4,
+		"testdata/gnu.a":          5,
+		"testdata/nopad_final.ar": 2,
+	} {
+		info, err := os.Stat(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		calls := 0
+		var lastCount int
+		var lastBytes int64
+		ar, err := FromFile(filename, WithProgress(func(count int, bytes int64) {
+			calls++
+			if count != calls || bytes <= lastBytes {
+				t.Errorf("%s: progress went backwards: %d/%d after %d/%d", filename, count, bytes, lastCount, lastBytes)
+			}
+			lastCount, lastBytes = count, bytes
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ar.Close()
+		if lastCount != members || lastBytes != info.Size() {
+			t.Errorf("%s: final progress should be %d/%d, got %d/%d", filename, members, info.Size(), lastCount, lastBytes)
+		}
+	}
+}
+
+func TestSignatureScan(t *testing.T) {
+	if _, err := FromFile("testdata/preamble.a"); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("preamble should not be accepted by default: %v", err)
+	}
+	if _, err := FromFile("testdata/preamble.a", WithSignatureScan(32)); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("preamble longer than the scan should not be accepted: %v", err)
+	}
+
+	ar, err := FromFile("testdata/preamble.a", WithSignatureScan(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	expected, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer expected.Close()
+	for _, name := range []string{"short.o", "a_very_long_object_file_name.o", "notes_with_a_long_name.txt"} {
+		data, err := ar.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedData, err := expected.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, expectedData) {
+			t.Errorf("%s has the wrong contents", name)
+		}
+	}
+	if member, ok := ar.LookupSymbol("long_func"); !ok || member != "a_very_long_object_file_name.o" {
+		t.Errorf("symbol offsets should be relative to the signature: %q %v", member, ok)
+	}
+	if err := ar.Verify(); err != nil {
+		t.Errorf("archive with a preamble should verify: %s", err)
+	}
+}
+
+func TestReaderAt(t *testing.T) {
+	ar, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	info, err := os.Stat("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ar.Size() != info.Size() {
+		t.Fatalf("archive size should be %d, got %d", info.Size(), ar.Size())
+	}
+	signature := make([]byte, 8)
+	if _, err := ar.ReaderAt().ReadAt(signature, 0); err != nil {
+		t.Fatal(err)
+	}
+	if string(signature) != "!<arch>\n" {
+		t.Fatalf("bad signature: %q", signature)
+	}
+}
+
+func TestNames(t *testing.T) {
+	ar, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	for pattern, expected := range map[string]string{
+		"*.o": "a_very_long_object_file_name.o short.o",
+		"":    "a_very_long_object_file_name.o notes_with_a_long_name.txt short.o",
+		"*.x": "",
+	} {
+		names, err := ar.Names(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(names, " ") != expected {
+			t.Errorf("%q: expected %q, got %q", pattern, expected, names)
+		}
+	}
+	if _, err := ar.Names("[bad"); err == nil {
+		t.Errorf("bad pattern should fail")
+	}
+}
+
+func TestARFileInfo(t *testing.T) {
+	ar, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	info, err := ar.Stat("test1.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	owned, ok := info.(ARFileInfo)
+	if !ok {
+		t.Fatalf("member info should implement ARFileInfo")
+	}
+	if owned.UID() != 501 || owned.GID() != 20 {
+		t.Fatalf("wrong ownership: %d/%d", owned.UID(), owned.GID())
+	}
+}
+
+func TestFind(t *testing.T) {
+	ar, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	for threshold, expected := range map[int64]string{
+		0:    "a_very_long_object_file_name.o notes_with_a_long_name.txt short.o",
+		100:  "a_very_long_object_file_name.o short.o",
+		900:  "a_very_long_object_file_name.o",
+		1000: "",
+	} {
+		var names []string
+		for _, info := range ar.Find(func(info fs.FileInfo) bool { return info.Size() > threshold }) {
+			names = append(names, info.Name())
+		}
+		if strings.Join(names, " ") != expected {
+			t.Errorf("size > %d: expected %q, got %q", threshold, expected, names)
+		}
+	}
+}
+
+func TestGlobEntries(t *testing.T) {
+	ar, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	entries, err := ar.GlobEntries("*.o")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := ar.Glob("*.o")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(names) {
+		t.Fatalf("GlobEntries found %d entries, Glob found %v", len(entries), names)
+	}
+	for i, entry := range entries {
+		if entry.Name() != names[i] {
+			t.Errorf("entry %d: expected %q, got %q", i, names[i], entry.Name())
+		}
+		info, err := entry.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := ar.Stat(names[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != expected.Size() || !info.ModTime().Equal(expected.ModTime()) {
+			t.Errorf("%q: info differs from Stat", names[i])
+		}
+	}
+
+	empty, err := FromInterface(bytes.NewReader(goodSignature))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := empty.GlobEntries("[bad"); !errors.Is(err, filepath.ErrBadPattern) {
+		t.Errorf("bad pattern should fail even without any members: %v", err)
+	}
+
+	// Directories implied by the names match in the same way as for Glob
+	virtual, err := FromInterface(bytes.NewReader(buildArchive(t,
+		testMember{name: "b.txt", data: "b"},
+		testMember{name: "sub/a.txt", data: "a"},
+	)), WithVirtualDirs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pattern := range []string{"*", "sub/*"} {
+		names, err := virtual.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, err := virtual.GlobEntries(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(names) {
+			t.Fatalf("%s: GlobEntries found %d entries, Glob found %v", pattern, len(entries), names)
+		}
+		for i, entry := range entries {
+			if entry.Name() != path.Base(names[i]) || entry.IsDir() != (names[i] == "sub") {
+				t.Errorf("%s: entry %d should be %q, not %q", pattern, i, names[i], entry.Name())
+			}
+		}
+	}
+}
+
+func TestRawNames(t *testing.T) {
+	bsd := buildArchive(t,
+		testMember{name: "name with  internal and trailing spaces   ", data: "bsd"},
+		testMember{name: "short", data: "x"},
+	)
+	for _, test := range []struct {
+		filename string
+		data     []byte
+		opts     []Option
+		expected string
+	}{
+		{"testdata/spaces.a", nil, nil, "report for  q3.txt  |lead"},
+		{"testdata/spaces.a", nil, []Option{WithRawNames()}, "report for  q3.txt  | lead"},
+		{"", bsd, nil, "name with  internal and trailing spaces   |short"},
+		{"", bsd, []Option{WithRawNames()}, "name with  internal and trailing spaces   |short"},
+	} {
+		var ar *ARFS
+		var err error
+		if test.data != nil {
+			ar, err = FromInterface(bytes.NewReader(test.data), test.opts...)
+		} else {
+			ar, err = FromFile(test.filename, test.opts...)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, hdr := range ar.List() {
+			names = append(names, hdr.Name)
+		}
+		if strings.Join(names, "|") != test.expected {
+			t.Errorf("%s %d: expected %q, got %q", test.filename, len(test.opts), test.expected, strings.Join(names, "|"))
+		}
+		for _, name := range names {
+			if !ar.Contains(name) {
+				t.Errorf("%q should be found by name", name)
+			}
+		}
+		ar.Close()
+	}
+}
+
+func TestTruncatedMember(t *testing.T) {
+	ar, err := FromFile("testdata/truncated.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	info, err := ar.Stat("cutoff.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 300 {
+		t.Fatalf("stat should report the declared size: %d", info.Size())
+	}
+	data, err := ar.ReadFile("cutoff.txt")
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("reading a truncated member should fail with ErrUnexpectedEOF: %v", err)
+	}
+	if len(data) != 100 || !strings.HasPrefix(string(data), "line 00 of") {
+		t.Fatalf("the available data should still be returned: %d %q", len(data), data)
+	}
+
+	f, err := ar.Open("cutoff.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	buf := make([]byte, 50)
+	if n, err := f.(io.ReaderAt).ReadAt(buf, 80); n != 20 || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("ReadAt past the truncation should fail with ErrUnexpectedEOF: %d %v", n, err)
+	}
+
+	if data, err := ar.ReadFile("complete.txt"); err != nil || string(data) != "whole\n" {
+		t.Fatalf("complete member should read normally: %q %v", data, err)
+	}
+	if err := ar.Verify(); !errors.Is(err, ErrTooShort) {
+		t.Fatalf("verify should flag the truncated member: %v", err)
+	}
+}
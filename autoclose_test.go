@@ -0,0 +1,139 @@
+package goarfs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// closeCounter counts how many times the archive reader is closed
+type closeCounter struct {
+	io.ReadSeeker
+	closes atomic.Int32
+}
+
+func (c *closeCounter) Close() error {
+	c.closes.Add(1)
+	return nil
+}
+
+func TestAutoClose(t *testing.T) {
+	var members []testMember
+	for i := 0; i < 20; i++ {
+		members = append(members, testMember{name: fmt.Sprintf("m%d", i), data: fmt.Sprintf("member %d", i)})
+	}
+	raw := &closeCounter{ReadSeeker: bytes.NewReader(buildArchive(t, members...))}
+	ar, err := FromInterface(raw, WithAutoClose())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep one file open past the Close of the archive
+	held, err := ar.Open("m0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				name := fmt.Sprintf("m%d", (g+i)%len(members))
+				f, err := ar.Open(name)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := io.ReadAll(f); err != nil {
+					t.Error(err)
+				}
+				f.Close()
+				// Closing twice must not drop a second reference
+				f.Close()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if err := ar.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if raw.closes.Load() != 0 {
+		t.Fatalf("archive closed while a file was still open")
+	}
+	data, err := io.ReadAll(held)
+	if err != nil || string(data) != "member 0" {
+		t.Fatalf("held file should still be readable: %q %v", data, err)
+	}
+	if err := held.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if closes := raw.closes.Load(); closes != 1 {
+		t.Fatalf("archive should be closed exactly once, closed %d times", closes)
+	}
+}
+
+func TestAutoCloseUnused(t *testing.T) {
+	f, err := os.Open("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := &closeCounter{ReadSeeker: f}
+	defer f.Close()
+	ar, err := FromInterface(raw, WithAutoClose())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ar.ReadFile("test1.dat"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ar.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if closes := raw.closes.Load(); closes != 1 {
+		t.Fatalf("archive with no open files should close immediately, closed %d times", closes)
+	}
+}
+
+func TestWithoutOwnership(t *testing.T) {
+	raw, err := os.ReadFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		opts   []Option
+		closes int32
+	}{
+		{nil, 1},
+		{[]Option{WithoutOwnership()}, 0},
+		{[]Option{WithoutOwnership(), WithAutoClose()}, 0},
+	} {
+		r := &closeCounter{ReadSeeker: bytes.NewReader(raw)}
+		ar, err := FromInterface(r, test.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clone, err := ar.Clone()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := ar.Open("test1.dat")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range []io.Closer{f, clone, ar} {
+			if err := c.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if closes := r.closes.Load(); closes != test.closes {
+			t.Errorf("%d options: reader closed %d times, expected %d", len(test.opts), closes, test.closes)
+		}
+	}
+}
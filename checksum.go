@@ -0,0 +1,99 @@
+package goarfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"hash"
+	"io/fs"
+	"sort"
+	"sync"
+)
+
+// Sum calculates the checksum of the named member using the hash returned
+// by h. The contents are streamed through the hash rather than read into
+// memory, and it is safe to call Sum concurrently. If the archive is truncated
+// and the member is shorter than its declared size, io.ErrUnexpectedEOF is
+// returned.
+func (a *ARFS) Sum(name string, h func() hash.Hash) ([]byte, error) {
+	fh, ok := a.getHeader(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "sum", Path: name, Err: fs.ErrNotExist}
+	}
+	return fh.sum(context.Background(), h)
+}
+
+// Sum256 returns the SHA256 checksum of the named member
+func (a *ARFS) Sum256(name string) ([]byte, error) {
+	return a.Sum(name, sha256.New)
+}
+
+func (fh *fileHeader) sum(ctx context.Context, h func() hash.Hash) ([]byte, error) {
+	hasher := h()
+	if _, err := fh.copyTo(ctx, hasher); err != nil {
+		return nil, &fs.PathError{Op: "sum", Path: fh.name, Err: err}
+	}
+	return hasher.Sum(nil), nil
+}
+
+// ChecksumAll calculates the checksum of every member using the hash returned
+// by h, keyed by member name. Members are hashed in parallel when the archive
+// was opened with WithConcurrency. If any member fails, the error for the first
+// such member (by name) is returned.
+func (a *ARFS) ChecksumAll(h func() hash.Hash) (map[string][]byte, error) {
+	return a.ChecksumAllContext(context.Background(), h)
+}
+
+// ChecksumAllContext is the same as ChecksumAll, but is abandoned if ctx is
+// cancelled, returning an error wrapping ctx.Err()
+func (a *ARFS) ChecksumAllContext(ctx context.Context, h func() hash.Hash) (map[string][]byte, error) {
+	idx := a.snapshot()
+	var members []*fileHeader
+	for _, fh := range idx.fileHeaders {
+		if !fh.special {
+			members = append(members, fh)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].name < members[j].name
+	})
+
+	sums := make([][]byte, len(members))
+	errs := make([]error, len(members))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(idx.opts.concurrency, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				sums[i], errs[i] = members[i].sum(ctx, h)
+			}
+		}()
+	}
+	for i := range members {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	if ctx.Err() != nil {
+		done := 0
+		for i := range members {
+			if sums[i] != nil {
+				done++
+			}
+		}
+		return nil, cancelled(ctx, done, len(members))
+	}
+
+	result := make(map[string][]byte, len(members))
+	for i, fh := range members {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		result[fh.name] = sums[i]
+	}
+	return result, nil
+}
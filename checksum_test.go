@@ -0,0 +1,160 @@
+package goarfs
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSum(t *testing.T) {
+	ar, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	sum, err := ar.Sum256("test1.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := sha256.Sum256([]byte("abcdefghijklmnopqrstuvwxyz"))
+	if !bytes.Equal(sum, expected[:]) {
+		t.Fatalf("wrong sha256 for test1.dat: %x", sum)
+	}
+	sum, err = ar.Sum("test2.dat", md5.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedMD5 := md5.Sum([]byte("123"))
+	if !bytes.Equal(sum, expectedMD5[:]) {
+		t.Fatalf("wrong md5 for test2.dat: %x", sum)
+	}
+}
+
+func TestSumTruncated(t *testing.T) {
+	raw, err := os.ReadFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Chop off the last two bytes of data from test2.dat
+	ar, err := FromInterface(bytes.NewReader(raw[:len(raw)-3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ar.Sum256("test2.dat"); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("truncated member should fail with ErrUnexpectedEOF: %v", err)
+	}
+	if _, err := ar.Sum256("test1.dat"); err != nil {
+		t.Fatalf("complete member should still sum: %s", err)
+	}
+}
+
+// seekOnly hides any ReadAt implementation of the underlying reader
+type seekOnly struct {
+	io.ReadSeeker
+}
+
+func TestSumConcurrent(t *testing.T) {
+	raw, err := os.ReadFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar, err := FromInterface(seekOnly{bytes.NewReader(raw)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := sha256.Sum256([]byte("abcdefghijklmnopqrstuvwxyz"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			sum, err := ar.Sum256(name)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if name == "test1.dat" && !bytes.Equal(sum, expected[:]) {
+				t.Errorf("wrong sha256 for test1.dat: %x", sum)
+			}
+		}([]string{"test1.dat", "test2.dat"}[i%2])
+	}
+	wg.Wait()
+}
+
+func TestChecksumAll(t *testing.T) {
+	for _, concurrency := range []int{0, 1, 4} {
+		raw, err := os.ReadFile("testdata/gnu.a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ar, err := FromInterface(seekOnly{bytes.NewReader(raw)}, WithConcurrency(concurrency))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sums, err := ar.ChecksumAll(sha256.New)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sums) != 3 {
+			t.Fatalf("expected 3 checksums, got %d", len(sums))
+		}
+		for name, sum := range sums {
+			expected, err := ar.Sum256(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(sum, expected) {
+				t.Errorf("%d: %s has wrong checksum", concurrency, name)
+			}
+		}
+	}
+
+	raw, err := os.ReadFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar, err := FromInterface(bytes.NewReader(raw[:len(raw)-3]), WithConcurrency(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ar.ChecksumAll(sha256.New); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("truncated member should fail with ErrUnexpectedEOF: %v", err)
+	}
+}
+
+func benchmarkChecksumAll(b *testing.B, concurrency int) {
+	b.Helper()
+	var members []testMember
+	for i := 0; i < 32; i++ {
+		members = append(members, testMember{name: fmt.Sprintf("member%d", i), data: strings.Repeat("x", 256*1024)})
+	}
+	raw := buildArchive(b, members...)
+	ar, err := FromInterface(bytes.NewReader(raw), WithConcurrency(concurrency))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(raw)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ar.ChecksumAll(sha256.New); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkChecksumAll(b *testing.B) {
+	benchmarkChecksumAll(b, 1)
+}
+
+func BenchmarkChecksumAllConcurrent(b *testing.B) {
+	benchmarkChecksumAll(b, 8)
+}
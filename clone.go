@@ -0,0 +1,54 @@
+package goarfs
+
+import (
+	"io"
+	"io/fs"
+)
+
+// Clone returns a new ARFS sharing the parsed index and the underlying reader
+// of a, but with its own options (starting from those of a, with opts applied
+// on top) and its own lifetime. The underlying file is reference counted, so
+// it is only closed once a and all of its clones have been closed. Cloning a
+// closed archive fails with fs.ErrClosed.
+//
+// Names are re-derived from the raw member names using the new options, so a
+// clone can use a different name normaliser or case sensitivity without the
+// archive being parsed again. Members dropped by the name normaliser of a are
+// not available to the clone.
+func (a *ARFS) Clone(opts ...Option) (*ARFS, error) {
+	a.refreshMu.Lock()
+	defer a.refreshMu.Unlock()
+	if a.closed {
+		return nil, &fs.PathError{Op: "clone", Path: a.filename, Err: fs.ErrClosed}
+	}
+
+	o := a.opts
+	o.decompressors = append([]compression(nil), o.decompressors...)
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	old := a.snapshot()
+	idx := &index{
+		rawFile:     old.rawFile,
+		opts:        o,
+		size:        old.size,
+		base:        old.base,
+		format:      old.format,
+		info:        old.info,
+		fileHeaders: make(map[string]*fileHeader, len(old.fileHeaders)),
+	}
+	for _, fh := range old.members {
+		c := *fh
+		c.name = c.rawName
+		if err := idx.addMember(&c); err != nil {
+			return nil, err
+		}
+		c.sectionReader = io.NewSectionReader(idx.readerFor(c.name), c.offset, c.Size())
+	}
+
+	old.rawFile.clones.Add(1)
+	clone := &ARFS{filename: a.filename, opts: o}
+	clone.idx.Store(idx)
+	return clone.watchLeaks(), nil
+}
@@ -0,0 +1,49 @@
+package goarfs
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	ar, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone, err := ar.Clone(WithCaseInsensitive())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ar.Contains("SHORT.O") || !clone.Contains("SHORT.O") {
+		t.Fatalf("clone options should be independent of the parent")
+	}
+
+	// The file stays open until the last reference is closed
+	if err := ar.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ar.Close(); err != nil {
+		t.Fatalf("closing twice should have no effect: %s", err)
+	}
+	if _, err := ar.Clone(); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("cloning a closed archive should fail with ErrClosed: %v", err)
+	}
+	data, err := clone.ReadFile("Notes_With_A_Long_Name.txt")
+	if err != nil {
+		t.Fatalf("clone should still be readable after the parent is closed: %s", err)
+	}
+	if len(data) == 0 {
+		t.Fatalf("clone read no data")
+	}
+	if sym, ok := clone.LookupSymbol("short_func"); !ok || sym != "short.o" {
+		t.Fatalf("clone should have the symbol index: %q %v", sym, ok)
+	}
+
+	if err := clone.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clone.ReadFile("short.o"); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("reading once every reference is closed should fail: %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/AndreRenaud/goarfs"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	if len(args) < 1 {
+		return errors.New("usage: ar create|extract [flags] files...")
+	}
+	switch args[0] {
+	case "create":
+		return create(args[1:])
+	case "extract":
+		return extract(args[1:], stdout)
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// create builds a new archive from a list of files
+func create(args []string) error {
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	out := flags.String("out", "", "AR file to create")
+	format := flags.String("format", "bsd", "Archive format to write: gnu or bsd")
+	deterministic := flags.Bool("deterministic", false, "Zero timestamps, owners & modes for reproducible output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("create: -out is required")
+	}
+
+	opts := []goarfs.WriterOption{}
+	switch *format {
+	case "bsd":
+		opts = append(opts, goarfs.WithFormat(goarfs.FormatBSD))
+	case "gnu":
+		opts = append(opts, goarfs.WithFormat(goarfs.FormatGNU))
+	default:
+		return fmt.Errorf("create: unknown format %q", *format)
+	}
+	if *deterministic {
+		opts = append(opts, goarfs.Deterministic())
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	w := goarfs.NewWriter(f, opts...)
+	for _, filename := range flags.Args() {
+		if err := addFile(w, filename); err != nil {
+			f.Close()
+			return fmt.Errorf("create: %s: %w", filename, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// addFile writes a file from disk as a member, named after its base name
+func addFile(w *goarfs.Writer, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := goarfs.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = filepath.Base(filename)
+	if err := w.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// extract unpacks an archive into a directory, optionally limited to a list
+// of member names (or patterns)
+func extract(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("extract", flag.ContinueOnError)
+	arfile := flags.String("arfile", "", "AR file to extract")
+	dir := flags.String("C", ".", "Directory to extract into")
+	preserve := flags.Bool("preserve", false, "Restore file modes, modification times and owners")
+	list := flags.Bool("list", false, "List what would be extracted, without writing anything")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ar, err := goarfs.FromFile(*arfile)
+	if err != nil {
+		return err
+	}
+	defer ar.Close()
+
+	var opts []goarfs.ExtractOption
+	for _, name := range flags.Args() {
+		matches, err := ar.Glob(name)
+		if err != nil {
+			return fmt.Errorf("extract: %w", err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("extract: %s: not found in archive", name)
+		}
+		opts = append(opts, goarfs.WithInclude(name))
+	}
+	if *preserve {
+		opts = append(opts, goarfs.WithChown())
+	} else {
+		opts = append(opts, goarfs.WithoutMetadata())
+	}
+	if *list {
+		opts = append(opts, goarfs.WithDryRun(func(name, dest string) {
+			fmt.Fprintf(stdout, "%s -> %s\n", name, dest)
+		}))
+	}
+	return ar.ExtractAll(*dir, opts...)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AndreRenaud/goarfs"
+)
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"short.txt":                           "short contents",
+		"this_name_is_longer_than_16.txt":     "long contents",
+		"odd":                                 "odd",
+		"another_quite_long_member_name.data": "",
+	}
+	var args []string
+	for name, contents := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		args = append(args, filename)
+	}
+
+	for _, format := range []string{"bsd", "gnu"} {
+		out := filepath.Join(dir, format+".ar")
+		if err := run(append([]string{"create", "-out", out, "-format", format}, args...), nil); err != nil {
+			t.Fatalf("%s: create: %s", format, err)
+		}
+		ar, err := goarfs.FromFile(out)
+		if err != nil {
+			t.Fatalf("%s: cannot read created archive: %s", format, err)
+		}
+		entries, err := ar.ReadDir(".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(files) {
+			t.Errorf("%s: expected %d members, got %d", format, len(files), len(entries))
+		}
+		for name, contents := range files {
+			data, err := ar.ReadFile(name)
+			if err != nil {
+				t.Errorf("%s: cannot read %s: %s", format, name, err)
+			} else if string(data) != contents {
+				t.Errorf("%s: %s has wrong contents: %q", format, name, data)
+			}
+		}
+		if err := ar.Verify(); err != nil {
+			t.Errorf("%s: created archive doesn't verify: %s", format, err)
+		}
+		ar.Close()
+	}
+}
+
+func TestCreateDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(input, []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var archives [][]byte
+	for i, mtime := range []int64{1000, 2000} {
+		if err := os.Chtimes(input, time.Unix(mtime, 0), time.Unix(mtime, 0)); err != nil {
+			t.Fatal(err)
+		}
+		out := filepath.Join(dir, fmt.Sprintf("%d.ar", i))
+		if err := run([]string{"create", "-out", out, "-deterministic", input}, nil); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		archives = append(archives, data)
+	}
+	if !bytes.Equal(archives[0], archives[1]) {
+		t.Fatalf("deterministic archives differ:\n%q\n%q", archives[0], archives[1])
+	}
+}
+
+func TestExtract(t *testing.T) {
+	dir := t.TempDir()
+	if err := run([]string{"extract", "-arfile", "../../testdata/test1.ar", "-C", dir, "-preserve"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "test1.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "abcdefghijklmnopqrstuvwxyz" {
+		t.Fatalf("test1.dat extracted incorrectly: %q", data)
+	}
+	info, err := os.Stat(filepath.Join(dir, "test2.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(time.Unix(1694666847, 0)) {
+		t.Fatalf("-preserve didn't restore mtime: %s", info.ModTime())
+	}
+
+	// Extract a subset
+	dir = t.TempDir()
+	if err := run([]string{"extract", "-arfile", "../../testdata/gnu.a", "-C", dir, "short.o", "*.txt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name() != "notes_with_a_long_name.txt" || entries[1].Name() != "short.o" {
+		t.Fatalf("wrong subset extracted: %v", entries)
+	}
+	if err := run([]string{"extract", "-arfile", "../../testdata/gnu.a", "-C", dir, "missing.o"}, nil); err == nil {
+		t.Fatalf("extracting a missing member should fail")
+	}
+
+	// Listing doesn't write anything
+	dir = t.TempDir()
+	var out bytes.Buffer
+	if err := run([]string{"extract", "-arfile", "../../testdata/test1.ar", "-C", dir, "-list"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("test1.dat -> %s\ntest2.dat -> %s\n", filepath.Join(dir, "test1.dat"), filepath.Join(dir, "test2.dat"))
+	if out.String() != expected {
+		t.Fatalf("wrong listing: %q", out.String())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("-list wrote %d files", len(entries))
+	}
+}
+
+func TestExtractTraversal(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "evil.ar")
+	var buf bytes.Buffer
+	w := goarfs.NewWriter(&buf)
+	if err := w.WriteHeader(&goarfs.Header{Name: "../evil.txt", Size: 4, Mode: 0o100644}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("evil")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(archive, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "out")
+	if err := run([]string{"extract", "-arfile", archive, "-C", out}, nil); !errors.Is(err, goarfs.ErrUnsafePath) {
+		t.Fatalf("path traversal should be refused: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evil.txt")); err == nil {
+		t.Fatalf("file written outside of the destination")
+	}
+}
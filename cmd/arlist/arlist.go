@@ -1,44 +1,186 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"os"
+	"sort"
 
 	"github.com/AndreRenaud/goarfs"
 )
 
+// memberData is the JSON output for a single member when dumping its contents
+type memberData struct {
+	goarfs.ManifestEntry
+	Data []byte `json:"data"`
+}
+
 func main() {
-	arfile := flag.String("arfile", "", "AR file to list")
-	filename := flag.String("filename", "", "File in archive to dump info about")
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("arlist", flag.ContinueOnError)
+	arfile := flags.String("arfile", "", "AR file to list")
+	filename := flags.String("filename", "", "File in archive to dump info about")
+	jsonOutput := flags.Bool("json", false, "Output JSON rather than human readable text")
+	sortBy := flags.String("sort", "name", "Sort files by name, size or mtime")
+	reverse := flags.Bool("reverse", false, "Reverse the sort order")
+	glob := flags.String("glob", "", "Only list files matching this pattern")
+	summary := flags.Bool("summary", false, "Print a summary of the archive layout after the listing")
 
-	flag.Parse()
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
 
 	ar, err := goarfs.FromFile(*arfile)
 	if err != nil {
-		log.Fatalf("fromfile: %s", err)
+		return fmt.Errorf("fromfile: %w", err)
 	}
 	defer ar.Close()
 
-	files, err := ar.ReadDir("/")
+	files, err := listing(ar, *glob, *sortBy, *reverse)
 	if err != nil {
-		log.Fatalf("readdir: %s", err)
+		return err
+	}
+
+	if *jsonOutput {
+		return outputJSON(ar, files, *filename, stdout)
 	}
-	fmt.Printf("AR File %q contains %d files\n", *arfile, len(files))
-	for _, f := range files {
-		info, err := f.Info()
-		if err != nil {
-			log.Fatalf("info on %s: %s", f.Name(), err)
-		}
 
-		fmt.Printf("%s %8d %s %s\n", info.Mode(), info.Size(), info.ModTime(), f.Name())
+	fmt.Fprintf(stdout, "AR File %q contains %d files\n", *arfile, len(files))
+	for _, info := range files {
+		owner := "-/-"
+		if arInfo, ok := info.(goarfs.ARFileInfo); ok {
+			owner = fmt.Sprintf("%d/%d", arInfo.UID(), arInfo.GID())
+		}
+		fmt.Fprintf(stdout, "%s %-9s %8d %s %s\n", info.Mode(), owner, info.Size(), info.ModTime(), info.Name())
+	}
+	totals := listedTotals(ar, files)
+	fmt.Fprintf(stdout, "Total: %d files, %d bytes, largest %q, newest %s, %d symbols\n",
+		totals.Count, totals.TotalSize, totals.Largest, totals.NewestModTime, totals.SymbolCount)
+	if *summary {
+		info := ar.Info()
+		fmt.Fprintf(stdout, "%d files, %d data bytes, %d overhead bytes (%d padding), largest %q (%d bytes), %d special members\n",
+			info.Members, info.DataBytes, info.OverheadBytes, info.PaddingBytes, info.Largest, info.LargestSize, info.SpecialMembers)
 	}
 
 	if *filename != "" {
 		data, err := ar.ReadFile(*filename)
 		if err != nil {
-			log.Fatalf("open %q: %s", *filename, err)
+			return fmt.Errorf("open %q: %w", *filename, err)
+		}
+		fmt.Fprint(stdout, string(data))
+	}
+	return nil
+}
+
+// listing returns the files to be listed, optionally restricted to those
+// matching glob, in the requested order
+func listing(ar *goarfs.ARFS, glob string, sortBy string, reverse bool) ([]fs.FileInfo, error) {
+	var files []fs.FileInfo
+	if glob != "" {
+		names, err := ar.Glob(glob)
+		if err != nil {
+			return nil, fmt.Errorf("glob: %w", err)
+		}
+		for _, name := range names {
+			info, err := ar.Stat(name)
+			if err != nil {
+				return nil, fmt.Errorf("stat %s: %w", name, err)
+			}
+			files = append(files, info)
+		}
+	} else {
+		entries, err := ar.ReadDir(".")
+		if err != nil {
+			return nil, fmt.Errorf("readdir: %w", err)
+		}
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil {
+				return nil, fmt.Errorf("info on %s: %w", entry.Name(), err)
+			}
+			files = append(files, info)
+		}
+	}
+
+	// Both ReadDir and Glob are already sorted by name, so a stable sort
+	// keeps ties in name order
+	switch sortBy {
+	case "name":
+	case "size":
+		sort.SliceStable(files, func(i, j int) bool {
+			return files[i].Size() < files[j].Size()
+		})
+	case "mtime":
+		sort.SliceStable(files, func(i, j int) bool {
+			return files[i].ModTime().Before(files[j].ModTime())
+		})
+	default:
+		return nil, fmt.Errorf("unknown sort order %q", sortBy)
+	}
+	if reverse {
+		for i, j := 0, len(files)-1; i < j; i, j = i+1, j-1 {
+			files[i], files[j] = files[j], files[i]
+		}
+	}
+	return files, nil
+}
+
+// listedTotals sums up the listed files, rather than the whole archive, so
+// that the totals agree with the listing when using -glob or when names are
+// duplicated
+func listedTotals(ar *goarfs.ARFS, files []fs.FileInfo) goarfs.Totals {
+	var totals goarfs.Totals
+	var largest int64
+	for _, info := range files {
+		totals.Count++
+		totals.TotalSize += info.Size()
+		if totals.Largest == "" || info.Size() > largest {
+			totals.Largest = info.Name()
+			largest = info.Size()
+		}
+		if info.ModTime().After(totals.NewestModTime) {
+			totals.NewestModTime = info.ModTime()
+		}
+		totals.SymbolCount += len(ar.SymbolsOf(info.Name()))
+	}
+	return totals
+}
+
+// outputJSON writes the manifest of the listed files as a JSON array, or if
+// filename is set, a single object with the metadata & contents of that member
+func outputJSON(ar *goarfs.ARFS, files []fs.FileInfo, filename string, stdout io.Writer) error {
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	if filename == "" {
+		entries := map[string]goarfs.ManifestEntry{}
+		for _, entry := range ar.Manifest() {
+			entries[entry.Name] = entry
+		}
+		manifest := []goarfs.ManifestEntry{}
+		for _, info := range files {
+			manifest = append(manifest, entries[info.Name()])
+		}
+		return encoder.Encode(manifest)
+	}
+
+	for _, entry := range ar.Manifest() {
+		if entry.Name != filename {
+			continue
+		}
+		data, err := ar.ReadFile(filename)
+		if err != nil {
+			return fmt.Errorf("open %q: %w", filename, err)
 		}
-		fmt.Print(string(data))
+		return encoder.Encode(memberData{ManifestEntry: entry, Data: data})
 	}
+	return fmt.Errorf("open %q: %w", filename, os.ErrNotExist)
 }
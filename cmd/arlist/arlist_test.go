@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/AndreRenaud/goarfs"
+)
+
+func TestJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := run([]string{"-arfile", "../../testdata/test1.ar", "-json"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	var manifest []goarfs.ManifestEntry
+	if err := json.Unmarshal(out.Bytes(), &manifest); err != nil {
+		t.Fatalf("output is not a JSON manifest: %s\n%s", err, out.String())
+	}
+	if len(manifest) != 2 || manifest[0].Name != "test1.dat" || manifest[1].Size != 3 {
+		t.Fatalf("bad manifest: %#v", manifest)
+	}
+
+	out.Reset()
+	if err := run([]string{"-arfile", "../../testdata/test1.ar", "-json", "-filename", "test2.dat"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	var member memberData
+	if err := json.Unmarshal(out.Bytes(), &member); err != nil {
+		t.Fatalf("output is not a JSON member: %s\n%s", err, out.String())
+	}
+	if member.Name != "test2.dat" || string(member.Data) != "123" {
+		t.Fatalf("bad member: %#v", member)
+	}
+	if !strings.Contains(out.String(), `"data": "MTIz"`) {
+		t.Fatalf("data should be base64 encoded: %s", out.String())
+	}
+}
+
+func listNames(t *testing.T, args ...string) []string {
+	t.Helper()
+	var out bytes.Buffer
+	args = append([]string{"-arfile", "../../testdata/gnu.a", "-json"}, args...)
+	if err := run(args, &out); err != nil {
+		t.Fatal(err)
+	}
+	var manifest []goarfs.ManifestEntry
+	if err := json.Unmarshal(out.Bytes(), &manifest); err != nil {
+		t.Fatalf("output is not a JSON manifest: %s\n%s", err, out.String())
+	}
+	var names []string
+	for _, m := range manifest {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+func TestSortAndGlob(t *testing.T) {
+	for _, test := range []struct {
+		args     []string
+		expected string
+	}{
+		{nil, "a_very_long_object_file_name.o notes_with_a_long_name.txt short.o"},
+		{[]string{"-reverse"}, "short.o notes_with_a_long_name.txt a_very_long_object_file_name.o"},
+		{[]string{"-sort", "size"}, "notes_with_a_long_name.txt short.o a_very_long_object_file_name.o"},
+		{[]string{"-sort", "size", "-reverse"}, "a_very_long_object_file_name.o short.o notes_with_a_long_name.txt"},
+		{[]string{"-glob", "*.o"}, "a_very_long_object_file_name.o short.o"},
+		{[]string{"-glob", "*.o", "-sort", "size"}, "short.o a_very_long_object_file_name.o"},
+		{[]string{"-glob", "*.none"}, ""},
+	} {
+		names := strings.Join(listNames(t, test.args...), " ")
+		if names != test.expected {
+			t.Errorf("%v: expected %q, got %q", test.args, test.expected, names)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	var out bytes.Buffer
+	if err := run([]string{"-arfile", "../../testdata/test1.ar", "-summary"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	expected := `2 files, 29 data bytes, 129 overhead bytes (1 padding), largest "test1.dat" (26 bytes), 0 special members`
+	if !strings.Contains(out.String(), expected) {
+		t.Fatalf("summary missing from output:\n%s", out.String())
+	}
+}
+
+func TestListOwnership(t *testing.T) {
+	var out bytes.Buffer
+	if err := run([]string{"-arfile", "../../testdata/test1.ar"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "-rw-r--r-- 501/20          26 ") {
+		t.Fatalf("listing should include the owner & group:\n%s", out.String())
+	}
+}
+
+func TestTotalsLine(t *testing.T) {
+	var out bytes.Buffer
+	if err := run([]string{"-arfile", "../../testdata/test1.ar"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `Total: 2 files, 29 bytes, largest "test1.dat", newest `) {
+		t.Fatalf("listing should end with the totals:\n%s", out.String())
+	}
+}
+
+func TestTotalsGlob(t *testing.T) {
+	var out bytes.Buffer
+	if err := run([]string{"-arfile", "../../testdata/gnu.a", "-glob", "*.o"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"contains 2 files", "Total: 2 files, "} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("totals should only cover the listed files, missing %q:\n%s", expected, out.String())
+		}
+	}
+
+	// Only the member each name resolves to is listed, and totalled
+	out.Reset()
+	if err := run([]string{"-arfile", "../../testdata/duplicates.a"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"contains 2 files", "Total: 2 files, 12 bytes, "} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("totals should count duplicated names once, missing %q:\n%s", expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"sort"
+)
+
+// CompareOption configures Equal
+type CompareOption func(*compareConfig)
+
+type compareConfig struct {
+	ignoreTimes     bool
+	ignoreOwnership bool
+	ignore          []string
+	order           bool
+	report          func(name, difference string)
+}
+
+// IgnoreTimestamps makes Equal ignore member modification times
+func IgnoreTimestamps() CompareOption {
+	return func(c *compareConfig) {
+		c.ignoreTimes = true
+	}
+}
+
+// IgnoreOwnership makes Equal ignore member owners & groups
+func IgnoreOwnership() CompareOption {
+	return func(c *compareConfig) {
+		c.ignoreOwnership = true
+	}
+}
+
+// IgnoreMembers makes Equal skip members whose names match any of the
+// patterns, using the syntax of path.Match
+func IgnoreMembers(patterns ...string) CompareOption {
+	return func(c *compareConfig) {
+		c.ignore = append(c.ignore, patterns...)
+	}
+}
+
+// CompareOrder makes Equal require the members to be in the same order
+func CompareOrder() CompareOption {
+	return func(c *compareConfig) {
+		c.order = true
+	}
+}
+
+// WithReport makes Equal call report for every difference it finds, rather
+// than stopping at the first one
+func WithReport(report func(name, difference string)) CompareOption {
+	return func(c *compareConfig) {
+		c.report = report
+	}
+}
+
+// errDifferent stops the comparison at the first difference
+var errDifferent = errors.New("archives differ")
+
+// Equal reports whether two archives have the same members, with the same
+// sizes, modes, modification times, ownership and contents. Contents are
+// streamed rather than read into memory. Members are matched up by name
+// regardless of their order, with any duplicated names paired up in archive
+// order, so an extra or changed duplicate is a difference. Options can relax
+// the comparison, require the same order, and request a report of what
+// differed.
+func Equal(a, b *ARFS, opts ...CompareOption) (bool, error) {
+	c := &compareConfig{}
+	for _, o := range opts {
+		o(c)
+	}
+	equal := true
+	different := func(name, format string, args ...any) error {
+		equal = false
+		if c.report == nil {
+			return errDifferent
+		}
+		c.report(name, fmt.Sprintf(format, args...))
+		return nil
+	}
+	err := c.compare(a.snapshot(), b.snapshot(), different)
+	if errors.Is(err, errDifferent) {
+		return false, nil
+	}
+	return equal && err == nil, err
+}
+
+// members returns the names of the members to be compared in archive order,
+// along with the members with each name. Duplicated names are paired up in
+// archive order, in the same way as Diff.
+func (c *compareConfig) members(idx *index) ([]string, map[string][]*fileHeader, error) {
+	var names []string
+	byName := map[string][]*fileHeader{}
+	for _, fh := range idx.visible() {
+		skip := false
+		for _, pattern := range c.ignore {
+			match, err := path.Match(pattern, fh.name)
+			if err != nil {
+				return nil, nil, err
+			}
+			skip = skip || match
+		}
+		if !skip {
+			names = append(names, fh.name)
+			byName[fh.name] = append(byName[fh.name], fh)
+		}
+	}
+	return names, byName, nil
+}
+
+func (c *compareConfig) compare(a, b *index, different func(name, format string, args ...any) error) error {
+	aNames, aMembers, err := c.members(a)
+	if err != nil {
+		return err
+	}
+	bNames, bMembers, err := c.members(b)
+	if err != nil {
+		return err
+	}
+
+	if c.order && !slices.Equal(aNames, bNames) {
+		if err := different("", "member order differs"); err != nil {
+			return err
+		}
+	}
+
+	var names []string
+	for name := range aMembers {
+		names = append(names, name)
+	}
+	for name := range bMembers {
+		if _, ok := aMembers[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for i := 0; i < max(len(aMembers[name]), len(bMembers[name])); i++ {
+			if err := c.compareMember(name, i, aMembers[name], bMembers[name], different); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// compareMember compares the i'th members called name in each archive. Any
+// difference past the first occurrence of the name says which one it is.
+func (c *compareConfig) compareMember(name string, i int, as, bs []*fileHeader, different func(name, format string, args ...any) error) error {
+	var fa, fb *fileHeader
+	if i < len(as) {
+		fa = as[i]
+	}
+	if i < len(bs) {
+		fb = bs[i]
+	}
+	prefix := ""
+	if i > 0 {
+		prefix = fmt.Sprintf("occurrence %d ", i)
+	}
+	switch {
+	case fb == nil:
+		return different(name, prefix+"only in first archive")
+	case fa == nil:
+		return different(name, prefix+"only in second archive")
+	case fa.Size() != fb.Size():
+		return different(name, prefix+"size %d vs %d", fa.Size(), fb.Size())
+	case fa.mode != fb.mode:
+		return different(name, prefix+"mode %o vs %o", fa.mode, fb.mode)
+	case !c.ignoreTimes && !fa.modification.Equal(fb.modification):
+		return different(name, prefix+"modification time %s vs %s", fa.modification, fb.modification)
+	case !c.ignoreOwnership && (fa.owner != fb.owner || fa.group != fb.group):
+		return different(name, prefix+"ownership %d:%d vs %d:%d", fa.owner, fa.group, fb.owner, fb.group)
+	}
+	same, err := sameContents(fa, fb)
+	if err == nil && !same {
+		err = different(name, prefix+"contents differ")
+	}
+	return err
+}
+
+// compareChunk is how much of each member is compared at a time
+const compareChunk = 32 * 1024
+
+// sameContents compares the contents of two members of the same size
+func sameContents(a, b *fileHeader) (bool, error) {
+	ra, rb := a.reader(), b.reader()
+	bufA := make([]byte, compareChunk)
+	bufB := make([]byte, compareChunk)
+	for remaining := a.Size(); remaining > 0; {
+		n := min(remaining, compareChunk)
+		if _, err := io.ReadFull(ra, bufA[:n]); err != nil {
+			return false, fmt.Errorf("%s: %w", a.name, err)
+		}
+		if _, err := io.ReadFull(rb, bufB[:n]); err != nil {
+			return false, fmt.Errorf("%s: %w", b.name, err)
+		}
+		if !bytes.Equal(bufA[:n], bufB[:n]) {
+			return false, nil
+		}
+		remaining -= n
+	}
+	return true, nil
+}
@@ -0,0 +1,96 @@
+package goarfs
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	open := func(members ...testMember) *ARFS {
+		t.Helper()
+		ar, err := FromInterface(bytes.NewReader(buildArchive(t, members...)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ar
+	}
+	big := strings.Repeat("0123456789", 10000)
+	base := open(
+		testMember{name: "a.o", data: "aaa", modtime: 100},
+		testMember{name: "big.o", data: big, modtime: 100},
+		testMember{name: "BUILDINFO", data: "built at 1", modtime: 100},
+	)
+	rebuilt := open(
+		testMember{name: "a.o", data: "aaa", modtime: 200},
+		testMember{name: "big.o", data: big, modtime: 200},
+		testMember{name: "BUILDINFO", data: "built at 2", modtime: 200},
+	)
+	reordered := open(
+		testMember{name: "big.o", data: big, modtime: 100},
+		testMember{name: "a.o", data: "aaa", modtime: 100},
+		testMember{name: "BUILDINFO", data: "built at 1", modtime: 100},
+	)
+	changed := open(
+		testMember{name: "a.o", data: "aaa", modtime: 100},
+		testMember{name: "big.o", data: big[:len(big)-1] + "!", modtime: 100},
+		testMember{name: "BUILDINFO", data: "built at 1", modtime: 100},
+	)
+	duplicated := open(
+		testMember{name: "a.o", data: "aaa", modtime: 100},
+		testMember{name: "big.o", data: big, modtime: 100},
+		testMember{name: "BUILDINFO", data: "built at 1", modtime: 100},
+		testMember{name: "a.o", data: "aaa", modtime: 100},
+	)
+
+	for _, test := range []struct {
+		name     string
+		other    *ARFS
+		opts     []CompareOption
+		expected bool
+	}{
+		{"identical", base, nil, true},
+		{"timestamps", rebuilt, nil, false},
+		{"ignore timestamps", rebuilt, []CompareOption{IgnoreTimestamps()}, false},
+		{"ignore buildinfo", rebuilt, []CompareOption{IgnoreTimestamps(), IgnoreMembers("BUILD*")}, true},
+		{"reordered", reordered, nil, true},
+		{"compare order", reordered, []CompareOption{CompareOrder()}, false},
+		{"contents", changed, nil, false},
+		{"duplicate", duplicated, nil, false},
+	} {
+		equal, err := Equal(base, test.other, test.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if equal != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, equal)
+		}
+	}
+
+	var differences []string
+	equal, err := Equal(base, rebuilt, WithReport(func(name, difference string) {
+		differences = append(differences, name+": "+difference)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if equal || len(differences) != 3 {
+		t.Fatalf("expected all 3 members to differ: %v", differences)
+	}
+	if !strings.HasPrefix(differences[0], "BUILDINFO: modification time") {
+		t.Fatalf("unexpected difference: %q", differences[0])
+	}
+
+	differences = nil
+	if equal, err := Equal(base, duplicated, WithReport(func(name, difference string) {
+		differences = append(differences, name+": "+difference)
+	})); err != nil || equal {
+		t.Fatalf("extra duplicate should be a difference: %v", err)
+	}
+	if strings.Join(differences, "\n") != "a.o: occurrence 1 only in second archive" {
+		t.Fatalf("unexpected differences: %q", differences)
+	}
+	if equal, err := Equal(duplicated, duplicated); err != nil || !equal {
+		t.Fatalf("archive with duplicates should equal itself: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package goarfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+)
+
+// sniffLength is the most data http.DetectContentType considers
+const sniffLength = 512
+
+// ContentType returns the MIME type of the named member. It is taken from the
+// file extension if that is known, otherwise the start of the contents is
+// sniffed with http.DetectContentType, which falls back to
+// "application/octet-stream". The result is remembered for later calls.
+func (a *ARFS) ContentType(name string) (string, error) {
+	idx := a.snapshot()
+	fh, ok := idx.lookup(name)
+	if !ok {
+		return "", &fs.PathError{Op: "contenttype", Path: name, Err: fs.ErrNotExist}
+	}
+	if cached, ok := idx.contentTypes.Load(fh); ok {
+		if ct, ok := cached.(string); ok {
+			return ct, nil
+		}
+	}
+
+	ct := mime.TypeByExtension(path.Ext(fh.name))
+	if ct == "" {
+		buf := make([]byte, min(fh.Size(), sniffLength))
+		n, err := fh.sectionReader.ReadAt(buf, 0)
+		if n < len(buf) && !errors.Is(err, io.EOF) {
+			return "", &fs.PathError{Op: "contenttype", Path: name, Err: err}
+		}
+		ct = http.DetectContentType(buf[:n])
+	}
+	idx.contentTypes.Store(fh, ct)
+	return ct, nil
+}
+
+// ServeContent replies to an HTTP request with the contents of the named
+// member, using http.ServeContent so that range and conditional requests are
+// handled. The member's modification time is used for Last-Modified, and its
+// name for the Content-Type if the handler hasn't already set one. Each call
+// reads through its own io.ReadSeeker, so concurrent requests don't interfere.
+// An error wrapping fs.ErrNotExist is returned without writing a response if
+// there is no such member, so the caller can reply with a 404.
+func (a *ARFS) ServeContent(w http.ResponseWriter, r *http.Request, name string) error {
+	fh, ok := a.getHeader(name)
+	if !ok || fh.special {
+		return &fs.PathError{Op: "serve", Path: name, Err: fs.ErrNotExist}
+	}
+	http.ServeContent(w, r, fh.name, fh.ModTime(), fh.reader())
+	return nil
+}
@@ -0,0 +1,109 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContentType(t *testing.T) {
+	ar, err := FromInterface(bytes.NewReader(buildArchive(t,
+		testMember{name: "page.html", data: "plain text really"},
+		testMember{name: "picture", data: "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 600)},
+		testMember{name: "notes", data: "just some text"},
+		testMember{name: "blob", data: "\x00\x01\x02\x03"},
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sniffing must not move the position of an already open reader
+	f, err := ar.Open("picture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	first := make([]byte, 4)
+	if _, err := io.ReadFull(f, first); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{
+		"page.html": "text/html; charset=utf-8",
+		"picture":   "image/png",
+		"notes":     "text/plain; charset=utf-8",
+		"blob":      "application/octet-stream",
+	} {
+		for i := 0; i < 2; i++ {
+			ct, err := ar.ContentType(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ct != expected {
+				t.Errorf("%s: expected %q, got %q", name, expected, ct)
+			}
+		}
+	}
+
+	rest := make([]byte, 4)
+	if _, err := io.ReadFull(f, rest); err != nil {
+		t.Fatal(err)
+	}
+	if string(first)+string(rest) != "\x89PNG\r\n\x1a\n" {
+		t.Fatalf("open reader was disturbed: %q %q", first, rest)
+	}
+	if _, err := ar.ContentType("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("missing member should fail with ErrNotExist: %v", err)
+	}
+}
+
+func TestServeContent(t *testing.T) {
+	modTime := time.Unix(1700000000, 0)
+	ar, err := FromInterface(bytes.NewReader(buildArchive(t,
+		testMember{name: "page.html", data: "<p>hello</p>", modtime: modTime.Unix()},
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := ar.ServeContent(w, r, strings.TrimPrefix(r.URL.Path, "/")); errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+		}
+	})
+
+	for _, test := range []struct {
+		path    string
+		since   time.Time
+		status  int
+		content string
+	}{
+		{"/page.html", time.Time{}, http.StatusOK, "<p>hello</p>"},
+		{"/page.html", modTime, http.StatusNotModified, ""},
+		{"/page.html", modTime.Add(-time.Hour), http.StatusOK, "<p>hello</p>"},
+		{"/missing.html", time.Time{}, http.StatusNotFound, "404 page not found\n"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if !test.since.IsZero() {
+			req.Header.Set("If-Modified-Since", test.since.UTC().Format(http.TimeFormat))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != test.status || rec.Body.String() != test.content {
+			t.Errorf("%s since %s: expected %d %q, got %d %q", test.path, test.since, test.status, test.content, rec.Code, rec.Body)
+		}
+		if test.status == http.StatusOK {
+			if ct := rec.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+				t.Errorf("%s: wrong content type %q", test.path, ct)
+			}
+			if lm := rec.Header().Get("Last-Modified"); lm != modTime.UTC().Format(http.TimeFormat) {
+				t.Errorf("%s: wrong last modified %q", test.path, lm)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package goarfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cancelReader cancels a context once reading reaches a given offset
+type cancelReader struct {
+	*bytes.Reader
+	at     int64
+	cancel context.CancelFunc
+}
+
+func (c *cancelReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= c.at {
+		c.cancel()
+	}
+	return c.Reader.ReadAt(p, off)
+}
+
+func TestParseCancel(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("!<arch>\n")
+	const members = 100000
+	for i := 0; i < members; i++ {
+		fmt.Fprintf(&buf, "%-16s%-12d%-6d%-6d%-8o%-10d`\n%s\n", fmt.Sprintf("m%d", i), 0, 0, 0, 0o100644, 1, "x")
+	}
+	raw := buf.Bytes()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &cancelReader{Reader: bytes.NewReader(raw), at: int64(len(raw) / 2), cancel: cancel}
+	start := time.Now()
+	if _, err := FromReaderContext(ctx, r); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled parse should fail with context.Canceled: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("cancelled parse took too long: %s", elapsed)
+	}
+
+	if _, err := FromFileContext(ctx, "testdata/test1.ar"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("parse with a cancelled context should fail: %v", err)
+	}
+
+	ar, err := FromReaderContext(context.Background(), bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ar.List()) != members {
+		t.Fatalf("expected %d members, got %d", members, len(ar.List()))
+	}
+}
+
+func TestFromReader(t *testing.T) {
+	raw := buildArchive(t, testMember{name: "a.txt", data: "streamed"})
+	// MultiReader hides the Seek method, so the archive has to be buffered
+	ar, err := FromReader(io.MultiReader(bytes.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ar.ReadFile("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "streamed" {
+		t.Fatalf("bad contents: %q", data)
+	}
+}
+
+func TestExtractAllContext(t *testing.T) {
+	big := strings.Repeat("x", 1024*1024)
+	raw := buildArchive(t,
+		testMember{name: "first.txt", data: "first"},
+		testMember{name: "big.bin", data: big},
+		testMember{name: "last.txt", data: "last"},
+	)
+
+	// Cancel part way through copying big.bin
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &cancelReader{Reader: bytes.NewReader(raw), at: int64(len(raw)), cancel: cancel}
+	ar, err := FromInterface(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.at = int64(len(raw) / 2)
+	dir := t.TempDir()
+	err = ar.ExtractAllContext(ctx, dir)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled extraction should fail with context.Canceled: %v", err)
+	}
+	if !strings.Contains(err.Error(), "after 1 of 3 members") {
+		t.Fatalf("error should say how far extraction got: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "first.txt")); err != nil {
+		t.Fatalf("first member should have been extracted: %s", err)
+	}
+	for _, name := range []string{"big.bin", "last.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("%s should not have been extracted: %v", name, err)
+		}
+	}
+}
+
+func TestBulkContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := FromFile("testdata/gnu.a", WithContext(ctx)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("parse with a cancelled context should fail: %v", err)
+	}
+
+	ar, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	if err := ar.VerifyContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("verify with a cancelled context should fail: %v", err)
+	}
+	if _, err := ar.ChecksumAllContext(ctx, sha256.New); !errors.Is(err, context.Canceled) {
+		t.Fatalf("checksums with a cancelled context should fail: %v", err)
+	}
+	if err := ar.VerifyContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,102 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestFormatDetection(t *testing.T) {
+	for filename, expected := range map[string]Format{
+		"testdata/darwin.a":    FormatDarwin,
+		"testdata/gnu.a":       FormatGNU,
+		"testdata/test1.ar":    FormatBSD,
+		"testdata/extended.ar": FormatDarwin,
+	} {
+		ar, err := FromFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ar.Format() != expected {
+			t.Errorf("%s: detected %s, expected %s", filename, ar.Format(), expected)
+		}
+		ar.Close()
+	}
+
+	empty, err := FromInterface(bytes.NewReader(goodSignature))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.Format() != FormatUnknown {
+		t.Errorf("empty archive should have an unknown format: %s", empty.Format())
+	}
+}
+
+func TestDarwinSymbols(t *testing.T) {
+	// Written by llvm-libtool-darwin, which sorts the symbols but doesn't
+	// mark the index as sorted
+	ar, err := FromFile("testdata/darwin.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	if ar.SymbolsSorted() {
+		t.Errorf("'__.SYMDEF' index should not be reported as sorted")
+	}
+	if member, ok := ar.LookupSymbol("_gamma"); !ok || member != "second_object_with_long_name.o" {
+		t.Errorf("cannot look up _gamma: %q %v", member, ok)
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithFormat(FormatDarwin))
+	for _, m := range []struct {
+		name    string
+		data    string
+		symbols []string
+	}{
+		{"zeta.o", "odd", []string{"_zeta", "_alpha"}},
+		{"a_member_with_a_long_name.o", "even", []string{"_middle"}},
+	} {
+		if err := w.WriteHeader(&Header{Name: m.name, Size: int64(len(m.data)), Mode: 0o100644}); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.AddSymbols(m.symbols...); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(m.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("!<arch>\n#1/20 ")) || !bytes.Contains(buf.Bytes(), []byte("__.SYMDEF SORTED\x00")) {
+		t.Fatalf("symbol index should be the first member:\n%q", buf.Bytes())
+	}
+
+	written, err := FromInterface(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written.Format() != FormatDarwin || !written.SymbolsSorted() {
+		t.Fatalf("written archive should be Darwin with a sorted index: %s %v", written.Format(), written.SymbolsSorted())
+	}
+	symbols := written.Symbols()
+	if len(symbols) != 3 || symbols["_alpha"] != "zeta.o" || symbols["_zeta"] != "zeta.o" || symbols["_middle"] != "a_member_with_a_long_name.o" {
+		t.Fatalf("wrong symbols: %v", symbols)
+	}
+	if syms := written.SymbolsOf("zeta.o"); len(syms) != 2 || syms[0] != "_alpha" {
+		t.Fatalf("index should be sorted by symbol: %v", syms)
+	}
+	if data, err := written.ReadFile("a_member_with_a_long_name.o"); err != nil || string(data) != "even" {
+		t.Fatalf("wrong contents: %q %v", data, err)
+	}
+
+	w = NewWriter(&bytes.Buffer{}, WithFormat(FormatGNU))
+	if err := w.WriteHeader(&Header{Name: "a.o"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddSymbols("_a"); !errors.Is(err, ErrNoSymbolIndex) {
+		t.Fatalf("symbols for a GNU archive should fail with ErrNoSymbolIndex: %v", err)
+	}
+}
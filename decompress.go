@@ -0,0 +1,164 @@
+package goarfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+)
+
+// Decompressor returns a reader which decompresses the data read from r
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+// UnsupportedCompressionError is returned by OpenDecompressed when a member is
+// compressed in a format which has no registered Decompressor
+type UnsupportedCompressionError struct {
+	Member string
+	Format string
+}
+
+func (e *UnsupportedCompressionError) Error() string {
+	return fmt.Sprintf("%s: no decompressor registered for %s compression", e.Member, e.Format)
+}
+
+// compression describes how to recognise a compressed member, and how to
+// decompress it (if that is supported)
+type compression struct {
+	format     string
+	magic      []byte
+	extension  string
+	decompress Decompressor
+}
+
+var (
+	compressionsMu sync.RWMutex
+	// compressions holds the formats which are recognised, whether or not
+	// there is a Decompressor available for them
+	compressions = []compression{
+		{format: "gzip", magic: []byte{0x1f, 0x8b}, extension: ".gz", decompress: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		}},
+		{format: "xz", magic: []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, extension: ".xz"},
+		{format: "zstd", magic: []byte{0x28, 0xb5, 0x2f, 0xfd}, extension: ".zst"},
+		{format: "bzip2", magic: []byte("BZh"), extension: ".bz2"},
+	}
+)
+
+// maxMagic is the most bytes needed to recognise any compression format
+const maxMagic = 16
+
+// newCompression describes a format being registered, taking the magic and
+// extension from the existing entry when they aren't given
+func newCompression(format string, magic []byte, d Decompressor) compression {
+	c := compression{format: format, magic: magic, decompress: d}
+	for _, known := range compressions {
+		if known.format == format {
+			if c.magic == nil {
+				c.magic = known.magic
+			}
+			c.extension = known.extension
+			break
+		}
+	}
+	return c
+}
+
+// RegisterDecompressor makes a Decompressor available to OpenDecompressed for
+// all archives. The format is a name such as "xz" or "zstd"; the magic may be
+// nil for the formats which are already recognised (gzip, xz, zstd & bzip2),
+// otherwise it is the prefix which identifies the compressed data. gzip is
+// registered by default. It is safe to call RegisterDecompressor concurrently
+// with other use of the package.
+func RegisterDecompressor(format string, magic []byte, d Decompressor) {
+	compressionsMu.Lock()
+	defer compressionsMu.Unlock()
+	updated := []compression{newCompression(format, magic, d)}
+	for _, c := range compressions {
+		if c.format != format {
+			updated = append(updated, c)
+		}
+	}
+	compressions = updated
+}
+
+// WithDecompressor makes a Decompressor available to OpenDecompressed for a
+// single archive, in preference to those from RegisterDecompressor. A nil magic
+// can be used for the formats which are already recognised.
+func WithDecompressor(format string, magic []byte, d Decompressor) Option {
+	return func(o *options) {
+		compressionsMu.RLock()
+		defer compressionsMu.RUnlock()
+		o.decompressors = append(o.decompressors, newCompression(format, magic, d))
+	}
+}
+
+// detectCompression finds the compression format of a member from its initial
+// bytes, falling back to the filename extension. It returns nil if the member
+// is not compressed.
+func (o *options) detectCompression(name string, magic []byte) *compression {
+	compressionsMu.RLock()
+	available := append(append([]compression(nil), o.decompressors...), compressions...)
+	compressionsMu.RUnlock()
+
+	for i, c := range available {
+		if len(c.magic) > 0 && bytes.HasPrefix(magic, c.magic) {
+			return &available[i]
+		}
+	}
+	for i, c := range available {
+		if c.extension != "" && strings.HasSuffix(name, c.extension) {
+			return &available[i]
+		}
+	}
+	return nil
+}
+
+// decompressedFile closes both the decompressor & the member it reads from
+type decompressedFile struct {
+	io.ReadCloser
+	member io.Closer
+}
+
+func (d *decompressedFile) Close() error {
+	err := d.ReadCloser.Close()
+	if cerr := d.member.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// OpenDecompressed opens the named member, transparently decompressing it if
+// it is compressed. Compression is detected from the contents of the member,
+// falling back to the filename extension. Members which aren't compressed are
+// returned as-is, so it can be used unconditionally. gzip is supported by
+// default, and other formats can be added with RegisterDecompressor or
+// WithDecompressor. Compressed members in a recognised format without a
+// Decompressor give an *UnsupportedCompressionError.
+//
+// Stat on the member (and the sizes reported by ReadDir etc) still describes
+// the compressed data.
+func (a *ARFS) OpenDecompressed(name string) (io.ReadCloser, error) {
+	fh, ok := a.getHeader(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "opendecompressed", Path: name, Err: fs.ErrNotExist}
+	}
+	f := fh.open()
+
+	magic := make([]byte, maxMagic)
+	n, _ := f.ReadAt(magic, 0)
+	c := a.opts.detectCompression(fh.name, magic[:n])
+	if c == nil {
+		return f, nil
+	}
+	if c.decompress == nil {
+		return nil, &UnsupportedCompressionError{Member: fh.name, Format: c.format}
+	}
+	r, err := c.decompress(f)
+	if err != nil {
+		return nil, &fs.PathError{Op: "opendecompressed", Path: name, Err: err}
+	}
+	return &decompressedFile{ReadCloser: r, member: f}, nil
+}
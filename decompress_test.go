@@ -0,0 +1,121 @@
+package goarfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestOpenDecompressed(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write([]byte("compressed contents")); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ar, err := FromInterface(bytes.NewReader(buildArchive(t,
+		testMember{name: "data.tar.gz", data: compressed.String()},
+		testMember{name: "no_extension", data: compressed.String()},
+		testMember{name: "plain.txt", data: "plain contents"},
+		testMember{name: "broken.gz", data: "not really gzip"},
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{
+		"data.tar.gz":  "compressed contents",
+		"no_extension": "compressed contents",
+		"plain.txt":    "plain contents",
+	} {
+		r, err := ar.OpenDecompressed(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if string(data) != expected {
+			t.Errorf("%s: expected %q, got %q", name, expected, data)
+		}
+		if err := r.Close(); err != nil {
+			t.Errorf("%s: close: %s", name, err)
+		}
+	}
+
+	if _, err := ar.OpenDecompressed("broken.gz"); !errors.Is(err, gzip.ErrHeader) {
+		t.Errorf("bad gzip data should fail with ErrHeader: %v", err)
+	}
+	if _, err := ar.OpenDecompressed("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing member should fail with ErrNotExist: %v", err)
+	}
+}
+
+// upperDecompressor is a stand-in for a real decompressor, which upper cases
+// the data following its 4 byte magic
+func upperDecompressor(r io.Reader) (io.ReadCloser, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(strings.ToUpper(string(data[4:])))), nil
+}
+
+func TestDecompressors(t *testing.T) {
+	zstdMagic := "\x28\xb5\x2f\xfd"
+	raw := buildArchive(t,
+		testMember{name: "data.tar.zst", data: zstdMagic + "zstd data"},
+		testMember{name: "data.tar.xz", data: "\xfd7zXZ\x00xz data"},
+		testMember{name: "custom", data: "CUST custom data"},
+	)
+	ar, err := FromInterface(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unsupported *UnsupportedCompressionError
+	if _, err := ar.OpenDecompressed("data.tar.xz"); !errors.As(err, &unsupported) || unsupported.Format != "xz" {
+		t.Fatalf("xz member should fail with UnsupportedCompressionError: %v", err)
+	}
+
+	// Per-archive decompressors don't affect other archives
+	local, err := FromInterface(bytes.NewReader(raw), WithDecompressor("zstd", nil, upperDecompressor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ar.OpenDecompressed("data.tar.zst"); !errors.As(err, &unsupported) || unsupported.Format != "zstd" {
+		t.Fatalf("zstd member should fail with UnsupportedCompressionError: %v", err)
+	}
+	r, err := local.OpenDecompressed("data.tar.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if string(data) != "ZSTD DATA" {
+		t.Fatalf("zstd member not decompressed: %q", data)
+	}
+
+	RegisterDecompressor("custom", []byte("CUST"), upperDecompressor)
+	r, err = ar.OpenDecompressed("custom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if string(data) != " CUSTOM DATA" {
+		t.Fatalf("custom member not decompressed: %q", data)
+	}
+}
@@ -0,0 +1,136 @@
+package goarfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"sort"
+)
+
+// DiffKind categorises how a member differs between two archives
+type DiffKind int
+
+const (
+	// DiffAdded is a member only in the new archive
+	DiffAdded DiffKind = iota
+	// DiffRemoved is a member only in the old archive
+	DiffRemoved
+	// DiffContent is a member whose contents have changed
+	DiffContent
+	// DiffMetadata is a member with the same contents, but a different mode,
+	// modification time or ownership
+	DiffMetadata
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffContent:
+		return "content"
+	case DiffMetadata:
+		return "metadata"
+	}
+	return "unknown"
+}
+
+// DiffEntry describes a single member which differs between two archives
+type DiffEntry struct {
+	Name string
+	// Occurrence counts members with the same name, from zero. Duplicates are
+	// paired up in the order they appear in each archive.
+	Occurrence int
+	Kind       DiffKind
+	// Old and New are the headers from each archive, or nil if the member is
+	// missing from it
+	Old *Header
+	New *Header
+	// OldSum and NewSum are the SHA256 checksums of the contents, only set for
+	// DiffContent when the sizes are the same (and so don't already show the
+	// change)
+	OldSum []byte
+	NewSum []byte
+}
+
+// DiffReport lists the members which differ between two archives, sorted by
+// name
+type DiffReport struct {
+	Entries []DiffEntry
+}
+
+// Diff compares two versions of an archive member by member, reporting which
+// members have been added, removed, or changed in contents or only in
+// metadata. Contents are streamed rather than read into memory.
+func Diff(before, after *ARFS) (DiffReport, error) {
+	var report DiffReport
+	oldMembers := membersByName(before.snapshot())
+	newMembers := membersByName(after.snapshot())
+
+	var names []string
+	for name := range oldMembers {
+		names = append(names, name)
+	}
+	for name := range newMembers {
+		if _, ok := oldMembers[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		o, n := oldMembers[name], newMembers[name]
+		for i := 0; i < max(len(o), len(n)); i++ {
+			entry := DiffEntry{Name: name, Occurrence: i}
+			var fo, fn *fileHeader
+			if i < len(o) {
+				fo = o[i]
+				h := fo.header()
+				entry.Old = &h
+			}
+			if i < len(n) {
+				fn = n[i]
+				h := fn.header()
+				entry.New = &h
+			}
+
+			switch {
+			case fn == nil:
+				entry.Kind = DiffRemoved
+			case fo == nil:
+				entry.Kind = DiffAdded
+			case fo.Size() != fn.Size():
+				entry.Kind = DiffContent
+			default:
+				same, err := sameContents(fo, fn)
+				if err != nil {
+					return report, err
+				}
+				if !same {
+					entry.Kind = DiffContent
+					if entry.OldSum, err = fo.sum(context.Background(), sha256.New); err != nil {
+						return report, err
+					}
+					if entry.NewSum, err = fn.sum(context.Background(), sha256.New); err != nil {
+						return report, err
+					}
+				} else if fo.mode != fn.mode || !fo.modification.Equal(fn.modification) || fo.owner != fn.owner || fo.group != fn.group {
+					entry.Kind = DiffMetadata
+				} else {
+					continue
+				}
+			}
+			report.Entries = append(report.Entries, entry)
+		}
+	}
+	return report, nil
+}
+
+// membersByName groups the visible members by name, in archive order
+func membersByName(idx *index) map[string][]*fileHeader {
+	members := map[string][]*fileHeader{}
+	for _, fh := range idx.visible() {
+		members[fh.name] = append(members[fh.name], fh)
+	}
+	return members
+}
@@ -0,0 +1,75 @@
+package goarfs
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	open := func(members ...testMember) *ARFS {
+		t.Helper()
+		ar, err := FromInterface(bytes.NewReader(buildArchive(t, members...)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ar
+	}
+	old := open(
+		testMember{name: "same.o", data: "same"},
+		testMember{name: "removed.o", data: "gone"},
+		testMember{name: "resized.o", data: "short"},
+		testMember{name: "edited.o", data: "before"},
+		testMember{name: "touched.o", data: "touch", modtime: 1},
+		testMember{name: "dup.o", data: "one"},
+		testMember{name: "dup.o", data: "two"},
+	)
+	updated := open(
+		testMember{name: "dup.o", data: "one"},
+		testMember{name: "dup.o", data: "TWO"},
+		testMember{name: "dup.o", data: "three"},
+		testMember{name: "same.o", data: "same"},
+		testMember{name: "resized.o", data: "much longer"},
+		testMember{name: "edited.o", data: "after!"},
+		testMember{name: "touched.o", data: "touch", modtime: 2},
+		testMember{name: "added.o", data: "new"},
+	)
+	report, err := Diff(old, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary []string
+	for _, e := range report.Entries {
+		summary = append(summary, fmt.Sprintf("%s#%d:%s", e.Name, e.Occurrence, e.Kind))
+	}
+	expected := "added.o#0:added dup.o#1:content dup.o#2:added edited.o#0:content removed.o#0:removed resized.o#0:content touched.o#0:metadata"
+	if strings.Join(summary, " ") != expected {
+		t.Fatalf("bad diff:\n%s\nexpected:\n%s", strings.Join(summary, " "), expected)
+	}
+
+	for _, e := range report.Entries {
+		switch e.Name {
+		case "edited.o":
+			if len(e.OldSum) == 0 || bytes.Equal(e.OldSum, e.NewSum) {
+				t.Errorf("same size content change should have differing checksums")
+			}
+		case "resized.o":
+			if e.OldSum != nil || e.Old.Size != 5 || e.New.Size != 11 {
+				t.Errorf("resized member should report sizes without checksums: %#v", e)
+			}
+		case "removed.o":
+			if e.New != nil || e.Old == nil {
+				t.Errorf("removed member should only have an old header")
+			}
+		}
+	}
+
+	report, err = Diff(old, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Entries) != 0 {
+		t.Fatalf("archive should not differ from itself: %v", report.Entries)
+	}
+}
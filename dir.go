@@ -0,0 +1,187 @@
+package goarfs
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// isRoot reports whether name refers to the root of the archive, which is the
+// only directory
+func isRoot(name string) bool {
+	return name == "." || name == "/"
+}
+
+// trimDirSlash removes a trailing slash from name, reporting whether there was
+// one. By convention it asserts that name is a directory, so naming a file
+// this way fails with ErrNotDir, while for a directory it makes no difference.
+func trimDirSlash(name string) (string, bool) {
+	if len(name) > 1 && strings.HasSuffix(name, "/") {
+		return strings.TrimSuffix(name, "/"), true
+	}
+	return name, false
+}
+
+// dirInfo describes a directory, such as the root of the archive
+type dirInfo struct {
+	name    string
+	modTime time.Time
+}
+
+func (di dirInfo) Name() string               { return di.name }
+func (dirInfo) Size() int64                   { return 0 }
+func (dirInfo) Mode() fs.FileMode             { return fs.ModeDir | 0o555 }
+func (di dirInfo) ModTime() time.Time         { return di.modTime }
+func (dirInfo) IsDir() bool                   { return true }
+func (dirInfo) Sys() any                      { return nil }
+func (dirInfo) Type() fs.FileMode             { return fs.ModeDir }
+func (di dirInfo) Info() (fs.FileInfo, error) { return di, nil }
+
+// dirInfo returns the FileInfo of a directory, which has the modification time
+// of the archive if it was loaded with FromFile
+func (idx *index) dirInfo(name string) dirInfo {
+	di := dirInfo{name: name}
+	if idx.info != nil {
+		di.modTime = idx.info.ModTime()
+	}
+	return di
+}
+
+// dirFile is an open directory, such as the root of the archive. The entries
+// are fixed when it is opened, so paging through them with ReadDir isn't
+// affected by a Refresh.
+type dirFile struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	// offset is the position of the next entry to be returned by ReadDir
+	offset int
+}
+
+var _ fs.ReadDirFile = (*dirFile)(nil)
+
+func (d *dirFile) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: fs.ErrInvalid}
+}
+
+func (d *dirFile) Close() error {
+	return nil
+}
+
+// ReadDir returns the next n entries, sorted by name, following the contract
+// of fs.ReadDirFile: with n > 0 an empty result is returned with io.EOF once
+// every entry has been read, while with n <= 0 all of the remaining entries are
+// returned.
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(remaining))
+	d.offset += n
+	return remaining[:n], nil
+}
+
+// dirMember is a member listed in a virtual directory, which is named by the
+// last element of its path
+type dirMember struct {
+	*fileHeader
+}
+
+func (m dirMember) Name() string               { return path.Base(m.fileHeader.name) }
+func (m dirMember) Info() (fs.FileInfo, error) { return m, nil }
+
+// entryHeader returns the member listed by a directory entry, which is false
+// for a directory
+func entryHeader(entry fs.DirEntry) (*fileHeader, bool) {
+	switch e := entry.(type) {
+	case *fileHeader:
+		return e, true
+	case dirMember:
+		return e.fileHeader, true
+	}
+	return nil, false
+}
+
+// virtualDirs returns the contents of every directory implied by the member
+// names, sorted by name and keyed by the (possibly lower cased) path of the
+// directory, with "." for the root
+func (idx *index) virtualDirs() map[string][]fs.DirEntry {
+	idx.dirsOnce.Do(func() {
+		dirs := map[string][]fs.DirEntry{".": nil}
+		for _, entry := range idx.rootEntries() {
+			fh, _ := entry.(*fileHeader)
+			if !fs.ValidPath(fh.name) {
+				continue
+			}
+			// Add any missing directories from the top down, so that each
+			// one's parent already exists
+			parent := "."
+			dir, _ := path.Split(fh.name)
+			for _, elem := range strings.Split(strings.TrimSuffix(dir, "/"), "/") {
+				if elem == "" {
+					break
+				}
+				child := path.Join(parent, elem)
+				if _, ok := dirs[idx.opts.key(child)]; !ok {
+					dirs[idx.opts.key(child)] = nil
+					if _, hidden := idx.fileHeaders[idx.opts.key(child)]; !hidden {
+						dirs[idx.opts.key(parent)] = append(dirs[idx.opts.key(parent)], idx.dirInfo(elem))
+					}
+				}
+				parent = child
+			}
+			dirs[idx.opts.key(parent)] = append(dirs[idx.opts.key(parent)], dirMember{fh})
+		}
+		for _, entries := range dirs {
+			sort.Slice(entries, func(i, j int) bool {
+				return entries[i].Name() < entries[j].Name()
+			})
+		}
+		idx.dirs = dirs
+	})
+	return idx.dirs
+}
+
+// dirEntries returns the contents of the directory name, which is either the
+// root or, when using WithVirtualDirs, one implied by the member names
+func (idx *index) dirEntries(name string) ([]fs.DirEntry, bool) {
+	if !idx.opts.virtualDirs {
+		if isRoot(name) {
+			return idx.rootEntries(), true
+		}
+		return nil, false
+	}
+	if isRoot(name) {
+		name = "."
+	}
+	entries, ok := idx.virtualDirs()[idx.opts.key(cleanName(name))]
+	return slices.Clone(entries), ok
+}
+
+// validPath reports whether name can be used with Open, Stat & ReadDir. With
+// WithVirtualDirs it must be a valid fs path, as there are now directories for
+// it to wander around, while flat archives accept any name.
+func (idx *index) validPath(name string) bool {
+	return !idx.opts.virtualDirs || fs.ValidPath(name)
+}
+
+// memberInfo returns the FileInfo of a member, which is named by its base name
+// when using WithVirtualDirs
+func (idx *index) memberInfo(fh *fileHeader) fs.FileInfo {
+	if idx.opts.virtualDirs {
+		return dirMember{fh}
+	}
+	return fh
+}
@@ -0,0 +1,117 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"testing"
+)
+
+func TestReadDirPaging(t *testing.T) {
+	var members []testMember
+	for i := 0; i < 250; i++ {
+		members = append(members, testMember{name: fmt.Sprintf("m%03d", 249-i), data: "x"})
+	}
+	ar, err := FromInterface(bytes.NewReader(buildArchive(t, members...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ar.Open(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	dir, ok := f.(fs.ReadDirFile)
+	if !ok {
+		t.Fatalf("root should be an fs.ReadDirFile")
+	}
+	if info, err := dir.Stat(); err != nil || !info.IsDir() {
+		t.Fatalf("root should be a directory: %v", err)
+	}
+
+	var names []string
+	for _, expected := range []int{100, 100, 50} {
+		entries, err := dir.ReadDir(100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != expected {
+			t.Fatalf("expected %d entries, got %d", expected, len(entries))
+		}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+	}
+	if entries, err := dir.ReadDir(100); len(entries) != 0 || !errors.Is(err, io.EOF) {
+		t.Fatalf("exhausted directory should return io.EOF: %d %v", len(entries), err)
+	}
+	for i, name := range names {
+		if name != fmt.Sprintf("m%03d", i) {
+			t.Fatalf("entry %d is %q, entries should be sorted", i, name)
+		}
+	}
+	if entries, err := dir.ReadDir(-1); len(entries) != 0 || err != nil {
+		t.Fatalf("reading everything from an exhausted directory should return nothing: %d %v", len(entries), err)
+	}
+}
+
+func TestTrailingSlash(t *testing.T) {
+	inner := buildArchive(t, testMember{name: "foo.o", data: "foo"})
+	raw := buildArchive(t,
+		testMember{name: "file.txt", data: "abc"},
+		testMember{name: "inner.a", data: string(inner)},
+	)
+	flat, err := FromInterface(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer flat.Close()
+	nested, err := FromInterface(bytes.NewReader(raw), WithNestedArchives())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nested.Close()
+	prefixed, err := flat.WithPrefix("assets/icons")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered, err := flat.Filter("*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		desc   string
+		fsys   fs.FS
+		file   string
+		dir    string
+		dirLen int
+	}{
+		{"flat", flat, "file.txt/", "./", 2},
+		{"nested", nested, "inner.a/foo.o/", "inner.a/", 1},
+		{"nested member", nested, "file.txt/", "inner.a/", 1},
+		{"prefix", prefixed, "assets/icons/file.txt/", "assets/icons/", 2},
+		{"prefix level", prefixed, "assets/icons/inner.a/", "assets/", 1},
+		{"filter", filtered, "file.txt/", "./", 2},
+		{"union", Union(flat), "file.txt/", "./", 2},
+	} {
+		var pathErr *fs.PathError
+		if _, err := fs.Stat(test.fsys, test.file); !errors.Is(err, ErrNotDir) || !errors.As(err, &pathErr) {
+			t.Errorf("%s: Stat(%q) should fail with ErrNotDir: %v", test.desc, test.file, err)
+		}
+		if _, err := test.fsys.Open(test.file); !errors.Is(err, ErrNotDir) {
+			t.Errorf("%s: Open(%q) should fail with ErrNotDir: %v", test.desc, test.file, err)
+		}
+		if _, err := fs.ReadFile(test.fsys, test.file); !errors.Is(err, ErrNotDir) || !errors.As(err, &pathErr) {
+			t.Errorf("%s: ReadFile(%q) should fail with ErrNotDir: %v", test.desc, test.file, err)
+		}
+		if info, err := fs.Stat(test.fsys, test.dir); err != nil || !info.IsDir() {
+			t.Errorf("%s: Stat(%q) should be a directory: %v", test.desc, test.dir, err)
+		}
+		if entries, err := fs.ReadDir(test.fsys, test.dir); err != nil || len(entries) != test.dirLen {
+			t.Errorf("%s: ReadDir(%q) should list %d entries: %v %v", test.desc, test.dir, test.dirLen, entries, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package goarfs
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// maxStringMembers is the most members listed by String
+const maxStringMembers = 50
+
+// Dump writes a table of every member in archive order, including symbol
+// indexes & long filename tables (which are flagged as special), along with
+// the detected format and any problems noticed in the layout. Only the parsed
+// headers are used, so no member data is read, and the output is the same for
+// the same archive, so it can be used for golden tests. Times are in UTC.
+func (a *ARFS) Dump(w io.Writer) error {
+	idx := a.snapshot()
+	return idx.dump(w, len(idx.members))
+}
+
+// String returns the output of Dump, cut short after the first 50 members
+func (a *ARFS) String() string {
+	var sb strings.Builder
+	_ = a.snapshot().dump(&sb, maxStringMembers)
+	return sb.String()
+}
+
+func (idx *index) dump(w io.Writer, limit int) error {
+	if _, err := fmt.Fprintf(w, "format: %s, %d members, %d bytes\n", idx.format, len(idx.members), idx.size); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tname\tsize\tmode\tuid/gid\tmtime\toffset\tflags")
+	for i, fh := range idx.members[:min(limit, len(idx.members))] {
+		fmt.Fprintf(tw, "%d\t%q\t%d\t%s\t%d/%d\t%s\t%d\t%s\n", i, fh.name, fh.Size(), fh.Mode(),
+			fh.owner, fh.group, fh.modification.UTC().Format(time.RFC3339), fh.offset, idx.flags(fh))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if limit < len(idx.members) {
+		if _, err := fmt.Fprintf(w, "... %d more members\n", len(idx.members)-limit); err != nil {
+			return err
+		}
+	}
+	for _, warning := range idx.warnings() {
+		if _, err := fmt.Fprintf(w, "warning: %s\n", warning); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flags describes anything unusual about a member for dump
+func (idx *index) flags(fh *fileHeader) string {
+	var flags []string
+	if fh.special {
+		flags = append(flags, "special")
+	}
+	if !fh.special && idx.fileHeaders[idx.opts.key(fh.name)] != fh {
+		flags = append(flags, "hidden")
+	}
+	if fh.rawName != fh.name {
+		flags = append(flags, fmt.Sprintf("raw=%q", fh.rawName))
+	}
+	if len(flags) == 0 {
+		return "-"
+	}
+	return strings.Join(flags, ",")
+}
+
+// warnings describes any problems with the layout of the archive which were
+// tolerated when it was parsed
+func (idx *index) warnings() []string {
+	var warnings []string
+	if idx.base > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d bytes of preamble before the signature", idx.base))
+	}
+	for _, fh := range idx.members {
+		switch {
+		case fh.offset+fh.Size() > idx.size:
+			warnings = append(warnings, fmt.Sprintf("%q is truncated by %d bytes", fh.name, fh.offset+fh.Size()-idx.size))
+		case fh.offset+fh.span > idx.size:
+			warnings = append(warnings, fmt.Sprintf("%q is missing its padding", fh.name))
+		}
+	}
+	return warnings
+}
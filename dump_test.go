@@ -0,0 +1,68 @@
+package goarfs
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	ar, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	var buf bytes.Buffer
+	if err := ar.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `format: bsd, 2 members, 158 bytes
+#  name         size  mode        uid/gid  mtime                 offset  flags
+0  "test1.dat"  26    -rw-r--r--  501/20   2023-09-14T04:47:19Z  68      -
+1  "test2.dat"  3     -rw-r--r--  501/20   2023-09-14T04:47:27Z  154     -
+`
+	if buf.String() != expected {
+		t.Fatalf("wrong dump:\n%s\nexpected\n%s", buf.String(), expected)
+	}
+	if ar.String() != expected {
+		t.Fatalf("String should match Dump:\n%s", ar.String())
+	}
+}
+
+func TestDumpWarnings(t *testing.T) {
+	for _, test := range []struct {
+		filename string
+		expected string
+	}{
+		{"testdata/gnu.a", `1  "//"   60    ----------  0/0      1970-01-01T00:00:00Z  178     special`},
+		{"testdata/truncated.a", `warning: "cutoff.txt" is truncated by 200 bytes`},
+		{"testdata/duplicates.a", `0  "dup.txt"    11    -rw-r--r--  0/0      2023-11-14T22:13:20Z  68      hidden`},
+	} {
+		ar, err := FromFile(test.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ar.Close()
+		// Compare with the alignment collapsed, as it depends on every row
+		dump := ar.String()
+		if !strings.Contains(strings.Join(strings.Fields(dump), " "), strings.Join(strings.Fields(test.expected), " ")) {
+			t.Errorf("%s: dump should contain %q:\n%s", test.filename, test.expected, dump)
+		}
+	}
+}
+
+func TestStringLimit(t *testing.T) {
+	var members []testMember
+	for i := 0; i < maxStringMembers+10; i++ {
+		members = append(members, testMember{name: fmt.Sprintf("m%d", i), data: "x"})
+	}
+	ar, err := FromInterface(bytes.NewReader(buildArchive(t, members...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	if s := ar.String(); !strings.HasSuffix(s, "... 10 more members\n") {
+		t.Errorf("String should be cut short:\n%s", s)
+	}
+}
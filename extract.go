@@ -0,0 +1,298 @@
+package goarfs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+)
+
+var (
+	ErrUnsafePath = errors.New("unsafe member path")
+)
+
+// OverwritePolicy controls what happens when an extracted member would
+// replace a file which already exists on disk.
+type OverwritePolicy int
+
+const (
+	// OverwriteFail returns an error if the destination already exists
+	OverwriteFail OverwritePolicy = iota
+	// OverwriteSkip leaves an existing destination untouched
+	OverwriteSkip
+	// OverwriteReplace replaces an existing destination
+	OverwriteReplace
+)
+
+// ExtractError records a failure to extract an archive member to disk
+type ExtractError struct {
+	Member string
+	Dest   string
+	Err    error
+}
+
+func (e *ExtractError) Error() string {
+	if e.Dest == "" {
+		return "extract " + e.Member + ": " + e.Err.Error()
+	}
+	return "extract " + e.Member + " to " + e.Dest + ": " + e.Err.Error()
+}
+
+func (e *ExtractError) Unwrap() error {
+	return e.Err
+}
+
+// ExtractOption configures the behaviour of ExtractAll and Extract
+type ExtractOption func(*extractConfig)
+
+type extractConfig struct {
+	overwrite OverwritePolicy
+	include   []string
+	exclude   []string
+	chown     bool
+	parents   bool
+	noMeta    bool
+	dryRun    func(name, dest string)
+	ctx       context.Context
+}
+
+// WithOverwrite sets the policy for destinations which already exist. The
+// default is OverwriteFail.
+func WithOverwrite(policy OverwritePolicy) ExtractOption {
+	return func(c *extractConfig) {
+		c.overwrite = policy
+	}
+}
+
+// WithInclude restricts extraction to members matching at least one of the
+// given patterns (see path.Match).
+func WithInclude(patterns ...string) ExtractOption {
+	return func(c *extractConfig) {
+		c.include = append(c.include, patterns...)
+	}
+}
+
+// WithExclude skips members matching any of the given patterns (see path.Match).
+func WithExclude(patterns ...string) ExtractOption {
+	return func(c *extractConfig) {
+		c.exclude = append(c.exclude, patterns...)
+	}
+}
+
+// WithChown restores the owner & group of each member from the archive. This
+// only has an effect when running as root, and is ignored on Windows.
+func WithChown() ExtractOption {
+	return func(c *extractConfig) {
+		c.chown = true
+	}
+}
+
+// WithoutMetadata skips restoring the file mode & modification time, leaving
+// them as the defaults for a newly created file.
+func WithoutMetadata() ExtractOption {
+	return func(c *extractConfig) {
+		c.noMeta = true
+	}
+}
+
+// WithParents causes Extract to create any missing parent directories of the
+// destination. ExtractAll always creates the directories it needs.
+func WithParents() ExtractOption {
+	return func(c *extractConfig) {
+		c.parents = true
+	}
+}
+
+// WithDryRun causes nothing to be written to disk. Instead report is called
+// with the member name and destination path for each file that would have
+// been written.
+func WithDryRun(report func(name, dest string)) ExtractOption {
+	return func(c *extractConfig) {
+		c.dryRun = report
+	}
+}
+
+func matchAny(patterns []string, name string) (bool, error) {
+	for _, p := range patterns {
+		match, err := path.Match(p, name)
+		if err != nil {
+			return false, err
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *extractConfig) selected(name string) (bool, error) {
+	if len(c.include) > 0 {
+		match, err := matchAny(c.include, name)
+		if err != nil || !match {
+			return false, err
+		}
+	}
+	match, err := matchAny(c.exclude, name)
+	if err != nil {
+		return false, err
+	}
+	return !match, nil
+}
+
+// destination determines where a member should be written under dir. Member
+// names which would escape dir (absolute paths, '..' components, drive letters
+// etc...) are rejected rather than re-rooted.
+func destination(dir, name string) (string, error) {
+	local := filepath.FromSlash(name)
+	if !filepath.IsLocal(local) {
+		return "", ErrUnsafePath
+	}
+	return filepath.Join(dir, local), nil
+}
+
+// ExtractAll writes every member of the archive into the directory dir,
+// creating it if required. The file mode & modification time are restored from
+// the archive headers. Members whose names would place them outside of dir
+// cause an error wrapping ErrUnsafePath, and nothing is written for them.
+// When a name is duplicated, only the member which Open finds for it under the
+// WithDuplicates policy is extracted, so by default the last one wins as with
+// 'ar x'.
+func (a *ARFS) ExtractAll(dir string, opts ...ExtractOption) error {
+	return a.ExtractAllContext(context.Background(), dir, opts...)
+}
+
+// ExtractAllContext is the same as ExtractAll, but is abandoned if ctx is
+// cancelled, returning an error wrapping ctx.Err() which says how many members
+// had been extracted. A member which was partially written is removed.
+func (a *ARFS) ExtractAllContext(ctx context.Context, dir string, opts ...ExtractOption) error {
+	config := extractConfig{ctx: ctx}
+	for _, o := range opts {
+		o(&config)
+	}
+
+	idx := a.snapshot()
+	members := idx.visible()
+	for i, fh := range members {
+		if ctx.Err() != nil {
+			return cancelled(ctx, i, len(members))
+		}
+		if idx.fileHeaders[idx.opts.key(fh.name)] != fh {
+			continue
+		}
+		selected, err := config.selected(fh.name)
+		if err != nil {
+			return err
+		}
+		if !selected {
+			continue
+		}
+		dest, err := destination(dir, fh.name)
+		if err != nil {
+			return &ExtractError{Member: fh.name, Err: err}
+		}
+		if err := config.extract(fh, dest, true); err != nil {
+			if ctx.Err() != nil {
+				return cancelled(ctx, i, len(members))
+			}
+			return &ExtractError{Member: fh.name, Dest: dest, Err: err}
+		}
+	}
+	return nil
+}
+
+// Extract writes a single member of the archive to destPath, restoring the
+// file mode & modification time from the archive header. As with cp, if
+// destPath is an existing directory then the member is written inside it using
+// the base name of the member. The contents are streamed from the archive
+// rather than being read into memory.
+func (a *ARFS) Extract(name, destPath string, opts ...ExtractOption) error {
+	config := extractConfig{ctx: context.Background()}
+	for _, o := range opts {
+		o(&config)
+	}
+
+	fh, ok := a.getHeader(name)
+	if !ok {
+		return &ExtractError{Member: name, Dest: destPath, Err: fs.ErrNotExist}
+	}
+	if info, err := os.Stat(destPath); err == nil && info.IsDir() {
+		destPath = filepath.Join(destPath, path.Base(fh.name))
+	}
+	if err := config.extract(fh, destPath, config.parents); err != nil {
+		return &ExtractError{Member: name, Dest: destPath, Err: err}
+	}
+	return nil
+}
+
+func (c *extractConfig) extract(fh *fileHeader, dest string, parents bool) error {
+	if info, err := os.Lstat(dest); err == nil {
+		switch {
+		case c.overwrite == OverwriteSkip:
+			return nil
+		case c.overwrite == OverwriteFail:
+			return fs.ErrExist
+		case info.IsDir():
+			return errors.New("destination is a directory")
+		}
+		if c.dryRun == nil {
+			// Remove rather than truncate, so that we never write through
+			// a symlink to somewhere outside of the destination
+			if err := os.Remove(dest); err != nil {
+				return err
+			}
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if c.dryRun != nil {
+		c.dryRun(fh.name, dest)
+		return nil
+	}
+
+	if parents {
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+	}
+	perm := fs.FileMode(0o600)
+	if c.noMeta {
+		perm = 0o666
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := fh.copyTo(c.ctx, f); err != nil {
+		f.Close()
+		os.Remove(dest)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return c.restoreMetadata(fh, dest)
+}
+
+func (c *extractConfig) restoreMetadata(fh *fileHeader, dest string) error {
+	if !c.noMeta {
+		if err := os.Chmod(dest, fh.Mode().Perm()); err != nil {
+			return err
+		}
+		// A zero time (see WithZeroModTime) means there is none to restore
+		if mtime := fh.ModTime(); !mtime.IsZero() {
+			if err := os.Chtimes(dest, mtime, mtime); err != nil {
+				return err
+			}
+		}
+	}
+	if c.chown && runtime.GOOS != "windows" && os.Geteuid() == 0 {
+		if err := os.Chown(dest, int(fh.owner), int(fh.group)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExtractAll(t *testing.T) {
+	ar, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	dir := t.TempDir()
+	if err := ar.ExtractAll(dir); err != nil {
+		t.Fatalf("extract: %s", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "test1.dat"))
+	if err != nil {
+		t.Fatalf("cannot read extracted test1.dat: %s", err)
+	}
+	if string(data) != "abcdefghijklmnopqrstuvwxyz" {
+		t.Fatalf("test1.dat has wrong contents: %q", data)
+	}
+	info, err := os.Stat(filepath.Join(dir, "test2.dat"))
+	if err != nil {
+		t.Fatalf("cannot stat extracted test2.dat: %s", err)
+	}
+	if !info.ModTime().Equal(time.Unix(1694666847, 0)) {
+		t.Fatalf("test2.dat has wrong mtime: %s", info.ModTime())
+	}
+	if info.Mode().Perm() != 0o644 {
+		t.Fatalf("test2.dat has wrong mode: %s", info.Mode())
+	}
+
+	// A second extraction fails by default, as the files already exist
+	if err := ar.ExtractAll(dir); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("re-extract should fail with ErrExist: %v", err)
+	}
+	if err := ar.ExtractAll(dir, WithOverwrite(OverwriteSkip)); err != nil {
+		t.Fatalf("re-extract with skip: %s", err)
+	}
+	if err := ar.ExtractAll(dir, WithOverwrite(OverwriteReplace)); err != nil {
+		t.Fatalf("re-extract with replace: %s", err)
+	}
+}
+
+func TestExtractAllDuplicates(t *testing.T) {
+	for _, test := range []struct {
+		policy DuplicatePolicy
+		files  []string
+	}{
+		{DuplicateLast, []string{"dup.txt", "other.txt"}},
+		{DuplicateFirst, []string{"dup.txt", "other.txt"}},
+		{DuplicateIndexed, []string{"dup.txt", "dup.txt~1", "dup.txt~2", "other.txt"}},
+	} {
+		ar, err := FromFile("testdata/duplicates.a", WithDuplicates(test.policy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ar.Close()
+		dir := t.TempDir()
+		if err := ar.ExtractAll(dir); err != nil {
+			t.Fatalf("%v: duplicated names should extract: %v", test.policy, err)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(test.files) {
+			t.Fatalf("%v: extracted %d files, expected %v", test.policy, len(entries), test.files)
+		}
+		for i, entry := range entries {
+			if entry.Name() != test.files[i] {
+				t.Fatalf("%v: extracted %q, expected %q", test.policy, entry.Name(), test.files[i])
+			}
+			expected, err := ar.ReadFile(entry.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+			if err != nil || !bytes.Equal(data, expected) {
+				t.Errorf("%v: %s should match the member Open finds, got %q %v", test.policy, entry.Name(), data, err)
+			}
+		}
+	}
+}
+
+func TestExtractAllUnsafe(t *testing.T) {
+	for _, name := range []string{"../escape", "/etc/passwd", "a/../../escape"} {
+		raw := buildArchive(t, testMember{name: name, data: "bad"})
+		ar, err := FromInterface(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parent := t.TempDir()
+		dir := filepath.Join(parent, "out")
+		if err := ar.ExtractAll(dir); !errors.Is(err, ErrUnsafePath) {
+			t.Fatalf("%q: expected ErrUnsafePath, got %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(parent, "escape")); err == nil {
+			t.Fatalf("%q: file written outside of destination", name)
+		}
+	}
+}
+
+func TestExtractAllFilter(t *testing.T) {
+	raw := buildArchive(t,
+		testMember{name: "a.o", data: "a"},
+		testMember{name: "b.o", data: "b"},
+		testMember{name: "c.txt", data: "c"},
+		testMember{name: "sub/d.o", data: "d"},
+	)
+	ar, err := FromInterface(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	var reported []string
+	err = ar.ExtractAll(dir, WithInclude("*.o", "sub/*"), WithExclude("b.*"), WithDryRun(func(name, dest string) {
+		if dest != filepath.Join(dir, filepath.FromSlash(name)) {
+			t.Errorf("%q has unexpected destination %q", name, dest)
+		}
+		reported = append(reported, name)
+	}))
+	if err != nil {
+		t.Fatalf("dry run: %s", err)
+	}
+	if len(reported) != 2 || reported[0] != "a.o" || reported[1] != "sub/d.o" {
+		t.Fatalf("dry run reported wrong members: %#v", reported)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("dry run wrote %d files", len(entries))
+	}
+
+	if err := ar.ExtractAll(dir, WithInclude("*.o", "sub/*"), WithExclude("b.*")); err != nil {
+		t.Fatalf("extract: %s", err)
+	}
+	for name, want := range map[string]bool{"a.o": true, "b.o": false, "c.txt": false, "sub/d.o": true} {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		if (err == nil) != want {
+			t.Errorf("%q: expected extracted=%v, got %v", name, want, err)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	ar, err := FromFile("testdata/extended.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "zeros.bin")
+	if err := ar.Extract("zeros", dest); err != nil {
+		t.Fatalf("extract: %s", err)
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1024 || !bytes.Equal(data, make([]byte, 1024)) {
+		t.Fatalf("zeros extracted incorrectly: %d bytes", len(data))
+	}
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(time.Unix(1694814407, 0)) {
+		t.Fatalf("zeros has wrong mtime: %s", info.ModTime())
+	}
+
+	// Extracting into an existing directory uses the member name, like cp
+	if err := ar.Extract("this_is_a_file_with_a_massive_filename", dir); err != nil {
+		t.Fatalf("extract into directory: %s", err)
+	}
+	info, err = os.Stat(filepath.Join(dir, "this_is_a_file_with_a_massive_filename"))
+	if err != nil {
+		t.Fatalf("member not written inside directory: %s", err)
+	}
+	if info.Size() != 127 {
+		t.Fatalf("long filename extracted with wrong size: %d", info.Size())
+	}
+
+	// Missing parents are only created on request
+	nested := filepath.Join(dir, "a", "b", "zeros")
+	if err := ar.Extract("zeros", nested); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("extract without parents should fail: %v", err)
+	}
+	if err := ar.Extract("zeros", nested, WithParents()); err != nil {
+		t.Fatalf("extract with parents: %s", err)
+	}
+
+	err = ar.Extract("missing", filepath.Join(dir, "missing"))
+	var extractErr *ExtractError
+	if !errors.As(err, &extractErr) || !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("extracting a missing member should fail with ErrNotExist: %v", err)
+	}
+	if extractErr.Member != "missing" || extractErr.Dest != filepath.Join(dir, "missing") {
+		t.Fatalf("error should name member and destination: %s", err)
+	}
+}
+
+func TestZeroModTime(t *testing.T) {
+	raw := buildArchive(t, testMember{name: "epoch.txt", data: "deterministic"})
+
+	plain, err := FromInterface(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	info, err := plain.Stat("epoch.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(time.Unix(0, 0)) {
+		t.Errorf("the epoch should be reported by default: %s", info.ModTime())
+	}
+
+	ar, err := FromInterface(bytes.NewReader(raw), WithZeroModTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	info, err = ar.Stat("epoch.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().IsZero() {
+		t.Errorf("the epoch should be reported as the zero time: %s", info.ModTime())
+	}
+	if h := ar.List()[0]; !h.ModTime.Equal(time.Unix(0, 0)) {
+		t.Errorf("the header should keep the raw epoch: %s", h.ModTime)
+	}
+
+	dir := t.TempDir()
+	before := time.Now().Add(-time.Minute)
+	if err := ar.ExtractAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	extracted, err := os.Stat(filepath.Join(dir, "epoch.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extracted.ModTime().Before(before) {
+		t.Errorf("a zero modification time should not be restored: %s", extracted.ModTime())
+	}
+}
@@ -0,0 +1,146 @@
+package goarfs
+
+import (
+	"io/fs"
+	"path"
+)
+
+// filterFS is a view of an archive which only shows some of the members
+type filterFS struct {
+	a    *ARFS
+	keep func(Header) bool
+}
+
+var _ fs.ReadDirFS = (*filterFS)(nil)
+var _ fs.ReadFileFS = (*filterFS)(nil)
+var _ fs.StatFS = (*filterFS)(nil)
+var _ fs.GlobFS = (*filterFS)(nil)
+
+// Filter returns a view of the archive containing only the members whose
+// names match pattern, using the syntax of path.Match. The view shares the
+// parsed archive rather than copying anything, and members which don't match
+// behave as if they don't exist. An invalid pattern gives path.ErrBadPattern.
+func (a *ARFS) Filter(pattern string) (fs.FS, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	return a.FilterFunc(func(h Header) bool {
+		match, _ := path.Match(pattern, h.Name)
+		return match
+	}), nil
+}
+
+// FilterFunc returns a view of the archive containing only the members for
+// which keep returns true, in the same way as Filter
+func (a *ARFS) FilterFunc(keep func(Header) bool) fs.FS {
+	return &filterFS{a: a, keep: keep}
+}
+
+// lookup finds a member which is kept by the view
+func (f *filterFS) lookup(idx *index, name string) (*fileHeader, bool) {
+	if !idx.validPath(name) {
+		return nil, false
+	}
+	fh, ok := idx.lookup(name)
+	if !ok || fh.special || isRoot(name) || !f.keep(fh.header()) {
+		return nil, false
+	}
+	return fh, true
+}
+
+// dir returns the contents of a directory, such as the root, without the
+// members which aren't kept
+func (f *filterFS) dir(idx *index, name string) ([]fs.DirEntry, bool) {
+	if !idx.validPath(name) {
+		return nil, false
+	}
+	entries, ok := idx.dirEntries(name)
+	if !ok {
+		return nil, false
+	}
+	var kept []fs.DirEntry
+	for _, entry := range entries {
+		if fh, ok := entryHeader(entry); ok && !f.keep(fh.header()) {
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	return kept, true
+}
+
+func (f *filterFS) Open(name string) (fs.File, error) {
+	idx := f.a.snapshot()
+	clean, mustBeDir := trimDirSlash(name)
+	if !idx.validPath(clean) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if fh, ok := f.lookup(idx, clean); ok {
+		if mustBeDir {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: ErrNotDir}
+		}
+		file := fh.open()
+		file.info = idx.memberInfo(fh)
+		return idx.track(file), nil
+	}
+	if entries, ok := f.dir(idx, clean); ok {
+		return &dirFile{info: idx.dirInfo(path.Base(cleanName(clean))), entries: entries}, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (f *filterFS) Stat(name string) (fs.FileInfo, error) {
+	idx := f.a.snapshot()
+	clean, mustBeDir := trimDirSlash(name)
+	if !idx.validPath(clean) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if fh, ok := f.lookup(idx, clean); ok {
+		if mustBeDir {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: ErrNotDir}
+		}
+		return idx.memberInfo(fh), nil
+	}
+	if _, ok := f.dir(idx, clean); ok {
+		return idx.dirInfo(path.Base(cleanName(clean))), nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+func (f *filterFS) ReadFile(name string) ([]byte, error) {
+	idx := f.a.snapshot()
+	clean, mustBeDir := trimDirSlash(name)
+	fh, ok := f.lookup(idx, clean)
+	switch {
+	case !ok:
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	case mustBeDir:
+		return nil, &fs.PathError{Op: "read", Path: name, Err: ErrNotDir}
+	}
+	return idx.readFile(name, fh)
+}
+
+func (f *filterFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	clean, _ := trimDirSlash(name)
+	entries, ok := f.dir(f.a.snapshot(), clean)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return entries, nil
+}
+
+func (f *filterFS) Glob(pattern string) ([]string, error) {
+	names, err := f.a.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	idx := f.a.snapshot()
+	var ret []string
+	for _, name := range names {
+		if _, ok := f.lookup(idx, name); ok {
+			ret = append(ret, name)
+		} else if _, ok := f.dir(idx, name); ok {
+			ret = append(ret, name)
+		}
+	}
+	return ret, nil
+}
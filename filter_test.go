@@ -0,0 +1,144 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFilter(t *testing.T) {
+	ar, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	if _, err := ar.Filter("[bad"); !errors.Is(err, path.ErrBadPattern) {
+		t.Fatalf("bad pattern should fail: %v", err)
+	}
+	view, err := ar.Filter("*.o")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"short.o", "a_very_long_object_file_name.o"} {
+		data, err := fs.ReadFile(view, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := ar.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != string(expected) {
+			t.Errorf("%s has the wrong contents", name)
+		}
+	}
+	if _, err := fs.ReadFile(view, "notes_with_a_long_name.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("filtered member should not exist: %v", err)
+	}
+	if _, err := fs.Stat(view, "notes_with_a_long_name.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("filtered member should not exist: %v", err)
+	}
+	names, err := fs.Glob(view, "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, " ") != "a_very_long_object_file_name.o short.o" {
+		t.Fatalf("glob should only show matching members: %v", names)
+	}
+
+	small := ar.FilterFunc(func(h Header) bool { return h.Size < 1000 })
+	entries, err := fs.ReadDir(small, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() >= 1000 {
+			t.Errorf("%s should have been filtered out", e.Name())
+		}
+	}
+	if len(entries) == 0 {
+		t.Fatalf("expected some small members")
+	}
+}
+
+func TestFilterFS(t *testing.T) {
+	ar, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	view, err := ar.Filter("*.o")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var walked []string
+	err = fs.WalkDir(view, ".", func(name string, d fs.DirEntry, err error) error {
+		walked = append(walked, name)
+		return err
+	})
+	if err != nil || strings.Join(walked, " ") != ". a_very_long_object_file_name.o short.o" {
+		t.Fatalf("walking the view should give the root & matching members: %v %v", walked, err)
+	}
+
+	// Directories implied by the names are kept, while only their kept
+	// members are listed
+	nested, err := FromInterface(bytes.NewReader(buildArchive(t,
+		testMember{name: "top.o", data: "top"},
+		testMember{name: "sub/a.o", data: "a"},
+		testMember{name: "sub/b.txt", data: "b"},
+	)), WithVirtualDirs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	view = nested.FilterFunc(func(h Header) bool { return path.Ext(h.Name) == ".o" })
+	if err := fstest.TestFS(view, "top.o", "sub", "sub/a.o"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(view, "sub/b.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("filtered member in a directory should not exist: %v", err)
+	}
+
+	truncated, err := FromFile("testdata/truncated.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer truncated.Close()
+	data, err := fs.ReadFile(truncated.FilterFunc(func(Header) bool { return true }), "cutoff.txt")
+	if !errors.Is(err, io.ErrUnexpectedEOF) || len(data) != 100 {
+		t.Fatalf("reading a truncated member should fail with ErrUnexpectedEOF: %d %v", len(data), err)
+	}
+}
+
+func TestFilterAutoClose(t *testing.T) {
+	raw := &closeCounter{ReadSeeker: bytes.NewReader(buildArchive(t, testMember{name: "kept.txt", data: "kept"}))}
+	ar, err := FromInterface(raw, WithAutoClose())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ar.FilterFunc(func(Header) bool { return true }).Open("kept.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ar.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if raw.closes.Load() != 0 {
+		t.Fatalf("archive closed while a file opened through the filter was still open")
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if closes := raw.closes.Load(); closes != 1 {
+		t.Fatalf("archive should be closed once the file is, closed %d times", closes)
+	}
+}
@@ -0,0 +1,81 @@
+package goarfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"regexp"
+)
+
+const (
+	// grepChunkSize is how much of a member Grep reads at a time
+	grepChunkSize = 64 << 10
+	// grepOverlap is how much of each chunk Grep keeps to search again with
+	// the next one, which is the longest match guaranteed to be found whole
+	grepOverlap = 4 << 10
+)
+
+// Grep searches the contents of every file in archive order, calling fn with
+// the name of the member and the text of each match of re. Members are read in
+// bounded chunks rather than all at once, with enough overlap that matches of
+// up to 4KiB which span chunks are still found. Longer matches may be cut
+// short, and '^' & '$' match at the edges of a chunk as well as of the member.
+// match is only valid until fn returns. Searching stops at the first error
+// returned by fn, which is returned from Grep, except for fs.SkipAll which
+// stops without an error.
+func (a *ARFS) Grep(re *regexp.Regexp, fn func(name string, match []byte) error) error {
+	buf := make([]byte, grepOverlap+grepChunkSize)
+	for _, fh := range a.snapshot().visible() {
+		if err := grepMember(re, fh, buf, fn); err != nil {
+			if errors.Is(err, fs.SkipAll) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// grepMember searches a single member, using buf to hold each chunk along with
+// the overlap from the one before
+func grepMember(re *regexp.Regexp, fh *fileHeader, buf []byte, fn func(name string, match []byte) error) error {
+	r := fh.reader()
+	// carry is the length of the overlap at the start of buf, and skip is
+	// where the last match reported ended within it
+	carry, skip := 0, 0
+	var total int64
+	for {
+		n, err := io.ReadFull(r, buf[carry:])
+		total += int64(n)
+		last := err != nil
+		if last && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return &fs.PathError{Op: "grep", Path: fh.name, Err: err}
+		}
+		window := buf[:carry+n]
+		// Matches starting in the overlap are left for the next chunk, which
+		// might extend them
+		limit := len(window)
+		if !last {
+			limit -= grepOverlap
+		}
+		for _, loc := range re.FindAllIndex(window, -1) {
+			if loc[0] < skip || loc[0] >= limit {
+				continue
+			}
+			if err := fn(fh.name, window[loc[0]:loc[1]]); err != nil {
+				return err
+			}
+			skip = loc[1]
+		}
+		if last {
+			// The member may be cut short by the end of the archive
+			if total < fh.Size() {
+				return &fs.PathError{Op: "grep", Path: fh.name, Err: io.ErrUnexpectedEOF}
+			}
+			return nil
+		}
+		copy(buf, window[limit:])
+		carry = len(window) - limit
+		skip = max(skip-limit, 0)
+	}
+}
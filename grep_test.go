@@ -0,0 +1,67 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGrep(t *testing.T) {
+	// Put matches either side of, and straddling, the chunk boundaries
+	big := []byte(strings.Repeat(".", 3*grepChunkSize))
+	for _, pos := range []int{10, grepChunkSize - grepOverlap - 3, grepChunkSize - 3, 2*grepChunkSize - grepOverlap - 2, len(big) - 9} {
+		copy(big[pos:], "KEY=12345")
+	}
+	ar, err := FromInterface(bytes.NewReader(buildArchive(t,
+		testMember{name: "first.txt", data: "nothing, then KEY=1 and KEY=22"},
+		testMember{name: "none.txt", data: "no keys here"},
+		testMember{name: "big.dat", data: string(big)},
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	var found []string
+	err = ar.Grep(regexp.MustCompile(`KEY=[0-9]+`), func(name string, match []byte) error {
+		found = append(found, fmt.Sprintf("%s:%s", name, match))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "first.txt:KEY=1 first.txt:KEY=22" + strings.Repeat(" big.dat:KEY=12345", 5)
+	if strings.Join(found, " ") != expected {
+		t.Fatalf("wrong matches:\n%v\nexpected\n%v", found, expected)
+	}
+
+	count := 0
+	err = ar.Grep(regexp.MustCompile(`KEY`), func(name string, match []byte) error {
+		count++
+		return fs.SkipAll
+	})
+	if err != nil || count != 1 {
+		t.Errorf("SkipAll should stop without an error: %d %v", count, err)
+	}
+	stop := errors.New("stop")
+	if err := ar.Grep(regexp.MustCompile(`KEY`), func(string, []byte) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("errors from fn should be returned: %v", err)
+	}
+}
+
+func TestGrepTruncated(t *testing.T) {
+	ar, err := FromFile("testdata/truncated.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	err = ar.Grep(regexp.MustCompile(`x`), func(string, []byte) error { return nil })
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated member should fail with ErrUnexpectedEOF: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package goarfs
+
+import (
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// Format identifies the flavour of AR archive, which mostly affects how long
+// filenames are stored
+type Format int
+
+const (
+	// FormatUnknown is used when the format cannot be determined
+	FormatUnknown Format = iota
+	// FormatBSD stores long filenames at the start of the member data, using
+	// a name of the form '#1/n'
+	FormatBSD
+	// FormatGNU stores long filenames in a '//' table member, and terminates
+	// short names with a '/'
+	FormatGNU
+	// FormatDarwin is the BSD format as written by the Apple tools, which
+	// include a '__.SYMDEF' or '__.SYMDEF SORTED' symbol index
+	FormatDarwin
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatBSD:
+		return "bsd"
+	case FormatGNU:
+		return "gnu"
+	case FormatDarwin:
+		return "darwin"
+	}
+	return "unknown"
+}
+
+// Header describes a single member of an archive
+type Header struct {
+	Name string
+	// RawName is the name as stored in the archive, which may differ from
+	// Name when using WithNameNormalizer or WithDuplicates. It is only set
+	// when reading, and is ignored by Writer.
+	RawName string
+	Size    int64
+	Mode    uint32
+	ModTime time.Time
+	UID     int
+	GID     int
+	// Offset is the position of the member data within the archive. It is
+	// only set when reading, and is ignored by Writer.
+	Offset int64
+	// HeaderOffset is the position of the member header, which is further
+	// before the data than the header size when a BSD extended filename
+	// comes in between. It is only set when reading, and is ignored by
+	// Writer.
+	HeaderOffset int64
+}
+
+// ARFileInfo is implemented by the fs.FileInfo of every member of an archive,
+// such as that returned by Stat, giving portable access to the owner & group
+// stored in the member header
+type ARFileInfo interface {
+	fs.FileInfo
+	UID() int
+	GID() int
+}
+
+// modeRegular is the st_mode file type bits for a regular file
+const modeRegular = 0o100000
+
+// FileInfoHeader creates a Header for a regular file from its fs.FileInfo. The
+// caller may need to adjust Name, as only the base name of the file is known.
+func FileInfoHeader(fi fs.FileInfo) (*Header, error) {
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w: %s", ErrNotRegular, fi.Name())
+	}
+	return &Header{
+		Name:    fi.Name(),
+		Size:    fi.Size(),
+		Mode:    modeRegular | uint32(fi.Mode().Perm()),
+		ModTime: fi.ModTime(),
+	}, nil
+}
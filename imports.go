@@ -0,0 +1,110 @@
+package goarfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrBadImport = errors.New("bad short import member")
+
+// ImportType is the kind of symbol imported by a Windows import library member
+type ImportType int
+
+const (
+	// ImportCode is an executable function
+	ImportCode ImportType = iota
+	// ImportData is a data variable
+	ImportData
+	// ImportConst is a constant
+	ImportConst
+)
+
+func (t ImportType) String() string {
+	switch t {
+	case ImportCode:
+		return "code"
+	case ImportData:
+		return "data"
+	case ImportConst:
+		return "const"
+	}
+	return fmt.Sprintf("ImportType(%d)", int(t))
+}
+
+// ImportEntry describes a symbol imported from a DLL by a Windows import
+// library
+type ImportEntry struct {
+	// Member is the name of the archive member holding the import
+	Member string
+	Symbol string
+	DLL    string
+	Type   ImportType
+	// Hint is the ordinal when ByOrdinal is set, otherwise a hint for where
+	// to find Symbol in the export table of the DLL
+	Hint      uint16
+	ByOrdinal bool
+}
+
+const (
+	// importHeaderSize is the size of IMPORT_OBJECT_HEADER
+	importHeaderSize = 20
+	// importSig2 marks a member as a short import rather than a COFF object
+	importSig2 = 0xffff
+)
+
+// ImportSymbols lists the symbols imported by the short import members of a
+// Windows import library (.lib), in archive order. Members which aren't short
+// imports, including the long format imports written by some older tools, are
+// skipped, so it is empty for other kinds of archive.
+func (a *ARFS) ImportSymbols() ([]ImportEntry, error) {
+	var entries []ImportEntry
+	for _, fh := range a.snapshot().visible() {
+		if fh.Size() < importHeaderSize {
+			continue
+		}
+		r := fh.reader()
+		var header [importHeaderSize]byte
+		if _, err := r.ReadAt(header[:], 0); err != nil {
+			return nil, err
+		}
+		if binary.LittleEndian.Uint16(header[0:]) != 0 || binary.LittleEndian.Uint16(header[2:]) != importSig2 {
+			continue
+		}
+		data, err := io.ReadAll(io.NewSectionReader(r, importHeaderSize, fh.Size()-importHeaderSize))
+		if err != nil {
+			return nil, err
+		}
+		entry, err := decodeImport(header, data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q at offset %d", err, fh.name, fh.headerOffset)
+		}
+		entry.Member = fh.name
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// decodeImport decodes a short import member from its header, and the NUL
+// terminated symbol & DLL names which follow it
+func decodeImport(header [importHeaderSize]byte, data []byte) (ImportEntry, error) {
+	size := binary.LittleEndian.Uint32(header[12:])
+	if uint64(size) > uint64(len(data)) {
+		return ImportEntry{}, ErrBadImport
+	}
+	names := bytes.SplitN(data[:size], []byte{0}, 3)
+	if len(names) < 3 {
+		return ImportEntry{}, ErrBadImport
+	}
+	flags := binary.LittleEndian.Uint16(header[18:])
+	return ImportEntry{
+		Symbol: string(names[0]),
+		DLL:    string(names[1]),
+		Type:   ImportType(flags & 0x3),
+		Hint:   binary.LittleEndian.Uint16(header[16:]),
+		// The name type is in bits 2-4, with zero meaning by ordinal
+		ByOrdinal: (flags>>2)&0x7 == 0,
+	}, nil
+}
@@ -0,0 +1,45 @@
+package goarfs
+
+import (
+	"testing"
+)
+
+func TestImportSymbols(t *testing.T) {
+	ar, err := FromFile("testdata/imports.lib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	entries, err := ar.ImportSymbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ImportEntry{
+		{Symbol: "ExampleFunction", Type: ImportCode},
+		{Symbol: "ExampleData", Type: ImportData},
+		{Symbol: "ExampleByOrdinal", Type: ImportCode, Hint: 7, ByOrdinal: true},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d imports, got %#v", len(expected), entries)
+	}
+	for i, e := range expected {
+		e.Member = "goarfs_example_library.dll"
+		e.DLL = "goarfs_example_library.dll"
+		if entries[i] != e {
+			t.Errorf("import %d: expected %#v, got %#v", i, e, entries[i])
+		}
+	}
+	// The symbol index of the import library is still available
+	if member, ok := ar.LookupSymbol("__imp_ExampleData"); !ok || member != "goarfs_example_library.dll" {
+		t.Errorf("cannot look up import symbol: %q %v", member, ok)
+	}
+
+	ordinary, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ordinary.Close()
+	if entries, err := ordinary.ImportSymbols(); err != nil || len(entries) != 0 {
+		t.Errorf("ordinary archive should have no imports: %v %v", entries, err)
+	}
+}
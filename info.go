@@ -0,0 +1,107 @@
+package goarfs
+
+import "time"
+
+// ArchiveInfo summarises the layout of an archive
+type ArchiveInfo struct {
+	// Members is the number of files, not counting special members
+	Members int
+	// DataBytes is the total size of the contents of the files
+	DataBytes int64
+	// PaddingBytes is the total size of the alignment padding after members
+	PaddingBytes int64
+	// OverheadBytes is everything in the archive other than the contents of
+	// the files: the signature, headers, extended names, special members and
+	// padding
+	OverheadBytes int64
+	// Largest is the name of the largest file, with the first in archive order
+	// winning a tie, and LargestSize is its size
+	Largest     string
+	LargestSize int64
+	// SpecialMembers is the number of symbol indexes and long filename tables
+	SpecialMembers int
+	HasSymbolIndex bool
+	HasNameTable   bool
+}
+
+// Info summarises the archive, as of when it was last parsed. It is computed
+// from the parsed index, without reading the archive.
+func (a *ARFS) Info() ArchiveInfo {
+	idx := a.snapshot()
+	var info ArchiveInfo
+	for _, fh := range idx.members {
+		// The padding is missing if the final member was written without it
+		info.PaddingBytes += max(min(fh.offset+fh.span, idx.size)-fh.offset-fh.Size(), 0)
+		if fh.special {
+			info.SpecialMembers++
+			if fh.name == "//" {
+				info.HasNameTable = true
+			} else {
+				info.HasSymbolIndex = true
+			}
+			continue
+		}
+		info.Members++
+		info.DataBytes += fh.Size()
+		if info.Largest == "" || fh.Size() > info.LargestSize {
+			info.Largest = fh.name
+			info.LargestSize = fh.Size()
+		}
+	}
+	info.OverheadBytes = idx.size - info.DataBytes
+	return info
+}
+
+// Totals holds aggregate statistics over the files in an archive
+type Totals struct {
+	// Count is the number of files, not counting special members
+	Count int
+	// TotalSize is the total size of the contents of the files
+	TotalSize int64
+	// Largest is the name of the largest file, with the first in archive order
+	// winning a tie
+	Largest string
+	// NewestModTime is the latest modification time of any file
+	NewestModTime time.Time
+	// SymbolCount is the number of entries in the symbol index, or 0 if the
+	// archive doesn't have one
+	SymbolCount int
+}
+
+// Totals returns aggregate statistics over the files in the archive
+func (a *ARFS) Totals() Totals {
+	idx := a.snapshot()
+	var totals Totals
+	var largest int64
+	for _, fh := range idx.members {
+		if fh.special {
+			continue
+		}
+		totals.Count++
+		totals.TotalSize += fh.Size()
+		if totals.Largest == "" || fh.Size() > largest {
+			totals.Largest = fh.name
+			largest = fh.Size()
+		}
+		if fh.ModTime().After(totals.NewestModTime) {
+			totals.NewestModTime = fh.ModTime()
+		}
+	}
+	for _, symbols := range idx.symbolIndex().byMember {
+		totals.SymbolCount += len(symbols)
+	}
+	return totals
+}
+
+// CheckModTimes returns the names of the members, in archive order, whose
+// modification times are before earliest or after latest. This can catch
+// timestamps leaking into archives which should be reproducible.
+func (a *ARFS) CheckModTimes(earliest, latest time.Time) []string {
+	var names []string
+	for _, fh := range a.snapshot().visible() {
+		if mtime := fh.ModTime(); mtime.Before(earliest) || mtime.After(latest) {
+			names = append(names, fh.name)
+		}
+	}
+	return names
+}
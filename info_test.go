@@ -0,0 +1,109 @@
+package goarfs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestInfo(t *testing.T) {
+	raw, err := os.ReadFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "info.a")
+	if err := os.WriteFile(filename, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ar, err := FromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	expected := ArchiveInfo{
+		Members:       2,
+		DataBytes:     29,
+		PaddingBytes:  1,
+		OverheadBytes: 129,
+		Largest:       "test1.dat",
+		LargestSize:   26,
+	}
+	if info := ar.Info(); info != expected {
+		t.Fatalf("wrong info:\n%#v\nexpected\n%#v", info, expected)
+	}
+
+	gnu, err := os.ReadFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, gnu, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ar.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	info := ar.Info()
+	if info.Members != 3 || info.DataBytes != 856+992+6 || info.Largest != "a_very_long_object_file_name.o" || info.LargestSize != 992 {
+		t.Fatalf("info should be updated by Refresh: %#v", info)
+	}
+	if info.SpecialMembers != 2 || !info.HasSymbolIndex || !info.HasNameTable {
+		t.Fatalf("info should report the special members: %#v", info)
+	}
+	if info.OverheadBytes != int64(len(gnu))-info.DataBytes {
+		t.Fatalf("wrong overhead: %d", info.OverheadBytes)
+	}
+}
+
+func TestTotals(t *testing.T) {
+	ar, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	expected := Totals{
+		Count:         2,
+		TotalSize:     29,
+		Largest:       "test1.dat",
+		NewestModTime: time.Unix(1694666847, 0),
+	}
+	totals := ar.Totals()
+	if !totals.NewestModTime.Equal(expected.NewestModTime) {
+		t.Errorf("wrong newest modification time %s, expected %s", totals.NewestModTime, expected.NewestModTime)
+	}
+	totals.NewestModTime = expected.NewestModTime
+	if totals != expected {
+		t.Fatalf("wrong totals:\n%#v\nexpected\n%#v", totals, expected)
+	}
+
+	gnu, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gnu.Close()
+	if totals := gnu.Totals(); totals.SymbolCount != 3 {
+		t.Errorf("gnu.a should have 3 symbols, has %d", totals.SymbolCount)
+	}
+}
+
+func TestCheckModTimes(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	ar, err := FromInterface(bytes.NewReader(buildArchive(t,
+		testMember{name: "built.o", data: "a", modtime: start.Unix()},
+		testMember{name: "leaked.o", data: "b", modtime: start.Add(time.Hour).Unix()},
+		testMember{name: "end.o", data: "c", modtime: start.Add(time.Minute).Unix()},
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outside := ar.CheckModTimes(start, start.Add(time.Minute)); !slices.Equal(outside, []string{"leaked.o"}) {
+		t.Errorf("only leaked.o should be outside the range: %v", outside)
+	}
+	if outside := ar.CheckModTimes(start.Add(time.Second), start.Add(2*time.Hour)); !slices.Equal(outside, []string{"built.o"}) {
+		t.Errorf("only built.o should be before the range: %v", outside)
+	}
+}
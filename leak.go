@@ -0,0 +1,22 @@
+package goarfs
+
+import "runtime"
+
+// watchLeaks arranges for a warning to be logged if the archive is garbage
+// collected without having been closed, when using WithLeakWarning
+func (a *ARFS) watchLeaks() *ARFS {
+	if a.opts.leakLogger == nil {
+		return a
+	}
+	runtime.SetFinalizer(a, func(a *ARFS) {
+		if a.closed {
+			return
+		}
+		name := a.filename
+		if name == "" {
+			name = "(not from a file)"
+		}
+		a.opts.leakLogger.Printf("goarfs: archive %s was garbage collected without Close being called", name)
+	})
+	return a
+}
@@ -0,0 +1,69 @@
+package goarfs
+
+import (
+	"bytes"
+	"log"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer which is safe to write from a finalizer
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.String()
+}
+
+// collect runs the garbage collector until the finalizers have had a chance
+// to run
+func collect(t *testing.T, done func() bool) {
+	t.Helper()
+	for i := 0; i < 20 && !done(); i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLeakWarning(t *testing.T) {
+	var out syncBuffer
+	logger := log.New(&out, "", 0)
+	func() {
+		if _, err := FromFile("testdata/test1.ar", WithLeakWarning(logger)); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	collect(t, func() bool { return out.String() != "" })
+	if !strings.Contains(out.String(), "testdata/test1.ar was garbage collected without Close") {
+		t.Fatalf("unclosed archive should be reported: %q", out.String())
+	}
+}
+
+func TestLeakWarningClosed(t *testing.T) {
+	var out syncBuffer
+	logger := log.New(&out, "", 0)
+	func() {
+		ar, err := FromFile("testdata/test1.ar", WithLeakWarning(logger))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ar.Close()
+	}()
+	collect(t, func() bool { return false })
+	if out.String() != "" {
+		t.Fatalf("closed archive should not be reported: %q", out.String())
+	}
+}
@@ -0,0 +1,41 @@
+package goarfs
+
+import (
+	"time"
+)
+
+// ManifestEntry describes a single member of an archive, in a form suitable
+// for serialising (e.g. as JSON)
+type ManifestEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	Mode    uint32    `json:"mode"`
+	ModTime time.Time `json:"modtime"`
+	UID     int       `json:"uid"`
+	GID     int       `json:"gid"`
+}
+
+// Manifest returns a description of every file in the archive, in the order
+// they appear in the archive. Symbol indexes and long filename tables are not
+// included.
+func (a *ARFS) Manifest() []ManifestEntry {
+	var manifest []ManifestEntry
+	for _, fh := range a.snapshot().members {
+		if fh.special {
+			continue
+		}
+		manifest = append(manifest, fh.manifestEntry())
+	}
+	return manifest
+}
+
+func (fh *fileHeader) manifestEntry() ManifestEntry {
+	return ManifestEntry{
+		Name:    fh.name,
+		Size:    fh.Size(),
+		Mode:    fh.mode,
+		ModTime: fh.modification,
+		UID:     int(fh.owner),
+		GID:     int(fh.group),
+	}
+}
@@ -0,0 +1,31 @@
+package goarfs
+
+import (
+	"io/fs"
+	"testing/fstest"
+)
+
+// ToMapFS copies every member of the archive into an fstest.MapFS, which can
+// then be modified freely, for example to tweak a file in a test fixture.
+// Symbol indexes and long filename tables are not included. The entire
+// contents of the archive are held in memory, so it is not suitable for large
+// archives.
+func (a *ARFS) ToMapFS() (fstest.MapFS, error) {
+	idx := a.snapshot()
+	m := fstest.MapFS{}
+	for _, fh := range idx.fileHeaders {
+		if fh.special {
+			continue
+		}
+		data := make([]byte, fh.Size())
+		if err := fh.readInto(data); err != nil {
+			return nil, &fs.PathError{Op: "read", Path: fh.name, Err: err}
+		}
+		m[fh.name] = &fstest.MapFile{
+			Data:    data,
+			Mode:    fh.Mode(),
+			ModTime: fh.ModTime(),
+		}
+	}
+	return m, nil
+}
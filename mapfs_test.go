@@ -0,0 +1,53 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestToMapFS(t *testing.T) {
+	ar, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	m, err := ar.ToMapFS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 3 {
+		t.Fatalf("expected 3 files, got %d", len(m))
+	}
+	if err := fstest.TestFS(m, "short.o", "a_very_long_object_file_name.o", "notes_with_a_long_name.txt"); err != nil {
+		t.Fatal(err)
+	}
+	for name, f := range m {
+		data, err := ar.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := ar.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, f.Data) || f.Mode != info.Mode() || !f.ModTime.Equal(info.ModTime()) {
+			t.Errorf("%s differs between the archive and the MapFS", name)
+		}
+	}
+
+	raw, err := os.ReadFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar, err = FromInterface(bytes.NewReader(raw[:len(raw)-3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ar.ToMapFS(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("truncated archive should fail: %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package goarfs
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	ErrNoReader         = errors.New("AR index has no reader attached")
+	ErrBadMarshaledData = errors.New("invalid marshaled AR index")
+)
+
+// marshalVersion is bumped whenever marshaledIndex changes incompatibly
+const marshalVersion = 1
+
+// marshaledIndex is the serialised form of an index, without any contents
+type marshaledIndex struct {
+	Version int
+	Size    int64
+	Base    int64
+	Format  Format
+	Members []marshaledMember
+	// Keys maps each lookup name to its position in Members
+	Keys map[string]int
+}
+
+type marshaledMember struct {
+	Name         string
+	RawName      string
+	ModTime      int64
+	Owner        uint32
+	Group        uint32
+	Mode         uint32
+	Size         uint32
+	HeaderOffset int64
+	Offset       int64
+	Span         int64
+	Special      bool
+}
+
+// detachedReader is used by an unmarshaled index until AttachReader is called
+type detachedReader struct{}
+
+func (detachedReader) ReadAt(p []byte, off int64) (int, error) {
+	return 0, ErrNoReader
+}
+
+// MarshalBinary encodes the parsed index of the archive (member names,
+// positions & metadata, but not their contents), so that it can be cached and
+// restored with UnmarshalBinary without parsing the archive again. Options
+// given when the archive was opened are not included, but their effect on the
+// names of the members is.
+func (a *ARFS) MarshalBinary() ([]byte, error) {
+	idx := a.snapshot()
+	m := marshaledIndex{
+		Version: marshalVersion,
+		Size:    idx.size,
+		Base:    idx.base,
+		Format:  idx.format,
+		Keys:    make(map[string]int, len(idx.fileHeaders)),
+	}
+	positions := make(map[*fileHeader]int, len(idx.members))
+	for i, fh := range idx.members {
+		positions[fh] = i
+		m.Members = append(m.Members, marshaledMember{
+			Name:         fh.name,
+			RawName:      fh.rawName,
+			ModTime:      fh.modification.Unix(),
+			Owner:        fh.owner,
+			Group:        fh.group,
+			Mode:         fh.mode,
+			Size:         fh.size,
+			HeaderOffset: fh.headerOffset,
+			Offset:       fh.offset,
+			Span:         fh.span,
+			Special:      fh.special,
+		})
+	}
+	for key, fh := range idx.fileHeaders {
+		m.Keys[key] = positions[fh]
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(m); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary restores an index encoded by MarshalBinary. The contents of
+// the members can't be read until the archive itself is attached with
+// AttachReader; until then reads fail with ErrNoReader.
+func (a *ARFS) UnmarshalBinary(data []byte) error {
+	var m marshaledIndex
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&m); err != nil {
+		return errors.Join(ErrBadMarshaledData, err)
+	}
+	if m.Version != marshalVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrBadMarshaledData, m.Version)
+	}
+
+	idx := &index{size: m.Size, base: m.Base, format: m.Format, fileHeaders: make(map[string]*fileHeader, len(m.Keys))}
+	for _, mm := range m.Members {
+		idx.members = append(idx.members, &fileHeader{
+			name:         mm.Name,
+			rawName:      mm.RawName,
+			modification: time.Unix(mm.ModTime, 0),
+			owner:        mm.Owner,
+			group:        mm.Group,
+			mode:         mm.Mode,
+			size:         mm.Size,
+			headerOffset: mm.HeaderOffset,
+			offset:       mm.Offset,
+			span:         mm.Span,
+			special:      mm.Special,
+		})
+	}
+	for key, i := range m.Keys {
+		if i < 0 || i >= len(idx.members) {
+			return fmt.Errorf("%w: no member %d for %q", ErrBadMarshaledData, i, key)
+		}
+		idx.fileHeaders[key] = idx.members[i]
+	}
+	a.idx.Store(idx.attach(detachedReader{}))
+	return nil
+}
+
+// AttachReader binds an index restored by UnmarshalBinary to the archive it
+// was created from, so that member contents can be read. Only the signature is
+// checked, so r must hold the same archive that was marshaled. Close does not
+// close r.
+func (a *ARFS) AttachReader(r io.ReaderAt) error {
+	idx := a.snapshot().attach(r)
+	var signature [8]byte
+	if err := idx.readFull(signature[:], idx.base); err != nil {
+		return err
+	}
+	if !bytes.Equal(signature[:], goodSignature) {
+		return ErrBadSignature
+	}
+	a.idx.Store(idx)
+	return nil
+}
+
+// attach returns a copy of the index which reads its contents from r
+func (idx *index) attach(r io.ReaderAt) *index {
+	attached := &index{
+		rawFile:     &arfsReader{ReadSeeker: io.NewSectionReader(r, 0, idx.size)},
+		opts:        idx.opts,
+		size:        idx.size,
+		base:        idx.base,
+		format:      idx.format,
+		info:        idx.info,
+		fileHeaders: make(map[string]*fileHeader, len(idx.fileHeaders)),
+	}
+	copies := make(map[*fileHeader]*fileHeader, len(idx.members))
+	for _, fh := range idx.members {
+		c := *fh
+		c.sectionReader = io.NewSectionReader(attached.readerFor(c.name), c.offset, c.Size())
+		copies[fh] = &c
+		attached.members = append(attached.members, &c)
+	}
+	for key, fh := range idx.fileHeaders {
+		attached.fileHeaders[key] = copies[fh]
+	}
+	return attached
+}
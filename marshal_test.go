@@ -0,0 +1,68 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestMarshalBinary(t *testing.T) {
+	ar, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	data, err := ar.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var restored ARFS
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restored.ReadFile("short.o"); !errors.Is(err, ErrNoReader) {
+		t.Fatalf("reading before AttachReader should fail with ErrNoReader: %v", err)
+	}
+	if len(restored.List()) != len(ar.List()) {
+		t.Fatalf("restored index has %d members, expected %d", len(restored.List()), len(ar.List()))
+	}
+	for i, hdr := range ar.List() {
+		if restored.List()[i] != hdr {
+			t.Errorf("member %d differs: %#v vs %#v", i, restored.List()[i], hdr)
+		}
+	}
+
+	f, err := os.Open("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := restored.AttachReader(f); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"short.o", "notes_with_a_long_name.txt"} {
+		expected, err := ar.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := restored.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != string(expected) {
+			t.Errorf("%s has the wrong contents after AttachReader", name)
+		}
+	}
+	if member, ok := restored.LookupSymbol("short_func"); !ok || member != "short.o" {
+		t.Errorf("symbols should be available after AttachReader: %q %v", member, ok)
+	}
+
+	if err := restored.AttachReader(bytes.NewReader(make([]byte, ar.Size()))); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("attaching something other than an archive should fail: %v", err)
+	}
+	if err := restored.UnmarshalBinary([]byte("garbage")); !errors.Is(err, ErrBadMarshaledData) {
+		t.Fatalf("bad data should fail with ErrBadMarshaledData: %v", err)
+	}
+}
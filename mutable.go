@@ -0,0 +1,362 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MutableARFS stages changes to an archive in memory, to be written out as a
+// new archive. Reads through it reflect the staged changes. The original
+// archive is never modified, and the contents of members which haven't been
+// changed are copied straight from it when writing, so it must stay open until
+// then. Changes which haven't been written are lost when the archive is
+// closed.
+type MutableARFS struct {
+	a *ARFS
+
+	mu sync.Mutex
+	// entries holds the members in the order they will be written
+	entries []*mutableEntry
+}
+
+var _ fs.ReadDirFS = (*MutableARFS)(nil)
+var _ fs.ReadFileFS = (*MutableARFS)(nil)
+var _ fs.StatFS = (*MutableARFS)(nil)
+var _ ARFileInfo = headerInfo{}
+
+// mutableEntry is a member of a MutableARFS, either unchanged from the
+// original archive (source is set) or staged in memory
+type mutableEntry struct {
+	hdr    Header
+	source *fileHeader
+	data   []byte
+}
+
+// Mutable returns a MutableARFS starting from the current members of the
+// archive, including any with duplicated names
+func (a *ARFS) Mutable() *MutableARFS {
+	m := &MutableARFS{a: a}
+	for _, fh := range a.snapshot().visible() {
+		m.entries = append(m.entries, &mutableEntry{hdr: fh.header(), source: fh})
+	}
+	return m
+}
+
+// find returns the position of the member called name, or -1 if there isn't
+// one. If the name is duplicated, the member found is the one chosen by the
+// duplicate policy of the archive.
+func (m *MutableARFS) find(name string) int {
+	key := m.a.opts.key(name)
+	if m.a.opts.duplicates == DuplicateFirst {
+		for i, e := range m.entries {
+			if m.a.opts.key(e.hdr.Name) == key {
+				return i
+			}
+		}
+		return -1
+	}
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		if m.a.opts.key(m.entries[i].hdr.Name) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// WriteFile stages the contents of the member called name, replacing it if it
+// already exists, or adding it to the end of the archive if it doesn't
+func (m *MutableARFS) WriteFile(name string, data []byte, mode fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := &mutableEntry{
+		hdr: Header{
+			Name:    name,
+			Size:    int64(len(data)),
+			Mode:    modeRegular | uint32(mode.Perm()),
+			ModTime: time.Now(),
+		},
+		data: bytes.Clone(data),
+	}
+	if i := m.find(name); i >= 0 {
+		e.hdr.RawName = m.entries[i].hdr.RawName
+		e.hdr.UID = m.entries[i].hdr.UID
+		e.hdr.GID = m.entries[i].hdr.GID
+		m.entries[i] = e
+		return nil
+	}
+	m.entries = append(m.entries, e)
+	return nil
+}
+
+// Remove stages the removal of the member called name
+func (m *MutableARFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.find(name)
+	if i < 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	m.entries = append(m.entries[:i], m.entries[i+1:]...)
+	return nil
+}
+
+// Rename stages a change to the name of the member called oldName, keeping
+// its contents & position in the archive. Whether the new name is stored in
+// the header or as a long filename is decided when the archive is written,
+// according to its length and the format. If newName is already taken, the
+// duplicate policy of the archive applies: DuplicateError fails with
+// ErrDuplicate, while the others keep both members, with the one found by the
+// name chosen by the policy.
+func (m *MutableARFS) Rename(oldName, newName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.find(oldName)
+	if i < 0 {
+		return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: fs.ErrNotExist}
+	}
+	if j := m.find(newName); j >= 0 && j != i && m.a.opts.duplicates == DuplicateError {
+		return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: ErrDuplicate}
+	}
+	e := *m.entries[i]
+	e.hdr.Name = newName
+	e.hdr.RawName = newName
+	m.entries[i] = &e
+	return nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// WriteTo writes the archive with the staged changes to w, in the same format
+// as the original archive
+func (m *MutableARFS) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	err := m.Flush(cw)
+	return cw.n, err
+}
+
+// Flush writes the archive with the staged changes to w. It is written in the
+// same format as the original archive unless opts select another. Members
+// which haven't been renamed keep the names stored in the archive, rather than
+// those given by WithNameNormalizer or DuplicateIndexed. The contents of
+// unchanged members are streamed from the original archive, and staged members
+// from memory.
+func (m *MutableARFS) Flush(w io.Writer, opts ...WriterOption) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	format := m.a.Format()
+	if format == FormatUnknown {
+		format = FormatBSD
+	}
+	aw := NewWriter(w, append([]WriterOption{WithFormat(format)}, opts...)...)
+	for _, e := range m.entries {
+		hdr := e.hdr
+		if hdr.RawName != "" {
+			hdr.Name = hdr.RawName
+		}
+		if _, err := aw.WriteFrom(&hdr, e.reader()); err != nil {
+			return err
+		}
+	}
+	return aw.Close()
+}
+
+// reader returns a new reader over the contents of the member
+func (e *mutableEntry) reader() *io.SectionReader {
+	if e.source != nil {
+		return e.source.reader()
+	}
+	return io.NewSectionReader(bytes.NewReader(e.data), 0, int64(len(e.data)))
+}
+
+// Chtimes stages a change to the modification time of the member called name.
+// AR archives don't record the access time, so atime is ignored.
+func (m *MutableARFS) Chtimes(name string, atime, mtime time.Time) error {
+	return m.update("chtimes", name, func(hdr *Header) {
+		hdr.ModTime = mtime
+	})
+}
+
+// Chmod stages a change to the permissions of the member called name
+func (m *MutableARFS) Chmod(name string, mode fs.FileMode) error {
+	return m.update("chmod", name, func(hdr *Header) {
+		hdr.Mode = hdr.Mode&^uint32(fs.ModePerm) | uint32(mode.Perm())
+	})
+}
+
+// update changes the header of the member called name, leaving its contents
+// where they are
+func (m *MutableARFS) update(op, name string, fn func(*Header)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.find(name)
+	if i < 0 {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	e := *m.entries[i]
+	fn(&e.hdr)
+	m.entries[i] = &e
+	return nil
+}
+
+// lookup returns the member called name, with the staged changes applied
+func (m *MutableARFS) lookup(name string) (*mutableEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.find(name)
+	if i < 0 {
+		return nil, false
+	}
+	return m.entries[i], true
+}
+
+// Open opens the member called name, or the root directory, with the staged
+// changes applied
+func (m *MutableARFS) Open(name string) (fs.File, error) {
+	if isRoot(name) {
+		entries, err := m.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &dirFile{info: m.a.snapshot().dirInfo("."), entries: entries}, nil
+	}
+	e, ok := m.lookup(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &stagedFile{SectionReader: e.reader(), info: headerInfo{e.hdr}}, nil
+}
+
+func (m *MutableARFS) ReadFile(name string) ([]byte, error) {
+	e, ok := m.lookup(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	if err := m.a.opts.checkReadSize(name, e.hdr.Size); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(e.reader())
+}
+
+func (m *MutableARFS) Stat(name string) (fs.FileInfo, error) {
+	if isRoot(name) {
+		return m.a.snapshot().dirInfo("."), nil
+	}
+	e, ok := m.lookup(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return headerInfo{e.hdr}, nil
+}
+
+// ReadDir returns the members with the staged changes applied, sorted by name.
+// Only the member found by each name is included when names are duplicated.
+func (m *MutableARFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !isRoot(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ret []fs.DirEntry
+	for i, e := range m.entries {
+		if m.find(e.hdr.Name) == i {
+			ret = append(ret, headerInfo{e.hdr})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name() < ret[j].Name()
+	})
+	return ret, nil
+}
+
+// stagedFile is an open member of a MutableARFS
+type stagedFile struct {
+	*io.SectionReader
+	info headerInfo
+}
+
+func (f *stagedFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *stagedFile) Close() error {
+	return nil
+}
+
+// headerInfo describes a member from its Header
+type headerInfo struct {
+	hdr Header
+}
+
+func (hi headerInfo) Name() string               { return hi.hdr.Name }
+func (hi headerInfo) Size() int64                { return hi.hdr.Size }
+func (hi headerInfo) Mode() fs.FileMode          { return fs.FileMode(hi.hdr.Mode) }
+func (hi headerInfo) ModTime() time.Time         { return hi.hdr.ModTime }
+func (hi headerInfo) IsDir() bool                { return false }
+func (hi headerInfo) Sys() any                   { return nil }
+func (hi headerInfo) Type() fs.FileMode          { return hi.Mode().Type() }
+func (hi headerInfo) Info() (fs.FileInfo, error) { return hi, nil }
+func (hi headerInfo) UID() int                   { return hi.hdr.UID }
+func (hi headerInfo) GID() int                   { return hi.hdr.GID }
+
+// Save writes the archive with the staged changes to filename, replacing it
+// atomically: the archive is written to a temporary file in the same directory,
+// synced to disk, and then renamed over filename, so that a crash never leaves
+// a partially written archive. filename may be the original archive.
+func (m *MutableARFS) Save(filename string) error {
+	perm := fs.FileMode(0o644)
+	if info, err := os.Stat(filename); err == nil {
+		perm = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".goarfs-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := m.WriteTo(tmp); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return replaceFile(tmp.Name(), filename)
+}
+
+// replaceFile renames from over to. Windows refuses to replace a file which is
+// still open (such as the original archive), so there it falls back to
+// removing to first.
+func replaceFile(from, to string) error {
+	err := os.Rename(from, to)
+	if err == nil || runtime.GOOS != "windows" {
+		return err
+	}
+	if rerr := os.Remove(to); rerr != nil && !errors.Is(rerr, fs.ErrNotExist) {
+		return err
+	}
+	return os.Rename(from, to)
+}
@@ -0,0 +1,269 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMutableSave(t *testing.T) {
+	raw, err := os.ReadFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "copy.a")
+	if err := os.WriteFile(filename, raw, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	ar, err := FromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	original, err := ar.ReadFile("a_very_long_object_file_name.o")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := ar.Mutable()
+	if err := m.WriteFile("notes_with_a_long_name.txt", []byte("replaced\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteFile("added.txt", []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remove("short.o"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remove("missing.o"); err == nil {
+		t.Fatalf("removing a missing member should fail")
+	}
+	// Save over the archive which is still open
+	if err := m.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := FromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer saved.Close()
+	names, err := saved.Names("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, " ") != "a_very_long_object_file_name.o added.txt notes_with_a_long_name.txt" {
+		t.Fatalf("saved archive has the wrong members: %v", names)
+	}
+	for name, expected := range map[string]string{
+		"a_very_long_object_file_name.o": string(original),
+		"notes_with_a_long_name.txt":     "replaced\n",
+		"added.txt":                      "new",
+	} {
+		data, err := saved.ReadFile(name)
+		if err != nil || string(data) != expected {
+			t.Errorf("%s has the wrong contents: %v", name, err)
+		}
+	}
+	if saved.Format() != FormatGNU {
+		t.Errorf("saved archive should keep the GNU format, is %s", saved.Format())
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o640 {
+		t.Errorf("saved archive should keep the permissions of the original: %s", info.Mode())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary file should not be left behind: %v", entries)
+	}
+}
+
+func TestMutableFlush(t *testing.T) {
+	ar, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	m := ar.Mutable()
+	if err := m.WriteFile("added.txt", []byte("new"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remove("short.o"); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := m.Chtimes("notes_with_a_long_name.txt", time.Time{}, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Chmod("notes_with_a_long_name.txt", 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Chmod("short.o", 0o600); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("chmod of a removed member should fail with ErrNotExist: %v", err)
+	}
+
+	// Reads reflect the staged changes, but the original is untouched
+	if data, err := m.ReadFile("added.txt"); err != nil || string(data) != "new" {
+		t.Errorf("staged member has the wrong contents: %q %v", data, err)
+	}
+	if _, err := m.Stat("short.o"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("removed member should not exist: %v", err)
+	}
+	if _, err := ar.Stat("short.o"); err != nil {
+		t.Errorf("original archive should still have the removed member: %v", err)
+	}
+	info, err := m.Stat("notes_with_a_long_name.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(mtime) || info.Mode().Perm() != 0o600 {
+		t.Errorf("staged metadata not applied: %s %s", info.ModTime(), info.Mode())
+	}
+	entries, err := m.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if strings.Join(names, " ") != "a_very_long_object_file_name.o added.txt notes_with_a_long_name.txt" {
+		t.Errorf("staged directory has the wrong members: %v", names)
+	}
+
+	var buf bytes.Buffer
+	if err := m.Flush(&buf, WithFormat(FormatBSD)); err != nil {
+		t.Fatal(err)
+	}
+	flushed, err := FromReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer flushed.Close()
+	if flushed.Format() != FormatBSD {
+		t.Errorf("flush options should override the format, got %s", flushed.Format())
+	}
+	original, err := ar.ReadFile("notes_with_a_long_name.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := flushed.ReadFile("notes_with_a_long_name.txt")
+	if err != nil || !bytes.Equal(data, original) {
+		t.Errorf("unchanged contents not streamed from the original: %v", err)
+	}
+	info, err = flushed.Stat("notes_with_a_long_name.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(mtime) || info.Mode().Perm() != 0o600 {
+		t.Errorf("flushed metadata not applied: %s %s", info.ModTime(), info.Mode())
+	}
+}
+
+func TestMutableRename(t *testing.T) {
+	for _, format := range []Format{FormatGNU, FormatBSD} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, WithFormat(format))
+		for _, name := range []string{"a.o", "b.o"} {
+			if _, err := w.WriteFrom(&Header{Name: name, Size: 4, Mode: 0o644}, strings.NewReader(name+"!")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		ar, err := FromInterface(bytes.NewReader(buf.Bytes()), WithDuplicates(DuplicateError))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ar.Close()
+
+		m := ar.Mutable()
+		long := strings.Repeat("x", 38) + ".o"
+		if err := m.Rename("a.o", long); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Rename("missing.o", "c.o"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: renaming a missing member should fail with ErrNotExist: %v", format, err)
+		}
+		if err := m.Rename("b.o", long); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("%s: renaming over an existing member should follow the duplicate policy: %v", format, err)
+		}
+
+		var out bytes.Buffer
+		if _, err := m.WriteTo(&out); err != nil {
+			t.Fatal(err)
+		}
+		renamed, err := FromInterface(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer renamed.Close()
+		data, err := renamed.ReadFile(long)
+		if err != nil || string(data) != "a.o!" {
+			t.Errorf("%s: renamed member has the wrong contents: %q %v", format, data, err)
+		}
+		if renamed.Contains("a.o") {
+			t.Errorf("%s: old name should be gone", format)
+		}
+	}
+}
+
+func TestMutableFlushStoredNames(t *testing.T) {
+	raw, err := os.ReadFile("testdata/duplicates.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar, err := FromInterface(bytes.NewReader(raw), WithDuplicates(DuplicateIndexed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := ar.Mutable()
+	if err := m.Chmod("dup.txt~1", 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteFile("dup.txt~2", []byte("replaced"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := m.Flush(&buf); err != nil {
+		t.Fatal(err)
+	}
+	flushed, err := FromInterface(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, h := range flushed.List() {
+		names = append(names, h.Name)
+	}
+	if strings.Join(names, " ") != "dup.txt other.txt dup.txt dup.txt" {
+		t.Errorf("unrenamed members should keep their stored names: %v", names)
+	}
+	if data, err := flushed.ReadFile("dup.txt"); err != nil || string(data) != "replaced" {
+		t.Errorf("last dup.txt should have the staged contents: %q %v", data, err)
+	}
+
+	// Without any changes the archive is reproduced
+	buf.Reset()
+	if err := ar.Mutable().Flush(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), raw) {
+		t.Errorf("unchanged archive should be flushed byte for byte")
+	}
+}
@@ -0,0 +1,202 @@
+package goarfs
+
+import (
+	"bytes"
+	"io/fs"
+	"strings"
+	"sync"
+)
+
+// OpenArchive opens a member which is itself an AR archive (such as a static
+// library inside a bundle), reading it directly from the outer archive without
+// copying it into memory. A missing member gives an error wrapping
+// fs.ErrNotExist, while a member which isn't a valid archive gives the parse
+// error (such as ErrBadSignature).
+//
+// The inner archive reads through the outer one, so it becomes unusable once
+// the outer archive is closed or refreshed.
+func (a *ARFS) OpenArchive(name string, opts ...Option) (*ARFS, error) {
+	fh, ok := a.getHeader(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "openarchive", Path: name, Err: fs.ErrNotExist}
+	}
+	inner, err := FromInterface(fh.reader(), opts...)
+	if err != nil {
+		return nil, &fs.PathError{Op: "openarchive", Path: name, Err: err}
+	}
+	return inner, nil
+}
+
+// nestedDepth is how many levels of archives within archives WithNestedArchives
+// mounts as directories. Any deeper are left as plain files, so that a
+// maliciously deep archive can't make us parse without limit.
+const nestedDepth = 8
+
+// nestedArchive caches what is known about a member which may be an archive
+// itself. Detection only reads the signature, so that listing a directory
+// doesn't parse every archive in it.
+type nestedArchive struct {
+	detectOnce sync.Once
+	isArchive  bool
+
+	parseOnce sync.Once
+	idx       *index
+	err       error
+}
+
+// nestedState returns the cached state of a possibly nested archive
+func (idx *index) nestedState(fh *fileHeader) *nestedArchive {
+	state, _ := idx.nested.LoadOrStore(fh, &nestedArchive{})
+	na, _ := state.(*nestedArchive)
+	return na
+}
+
+// isArchive reports whether the member is an archive which is mounted as a
+// directory, which is decided by its signature alone
+func (idx *index) isArchive(fh *fileHeader) bool {
+	if idx.opts.nestedDepth <= 0 || fh.special || fh.Size() < int64(len(goodSignature)) {
+		return false
+	}
+	na := idx.nestedState(fh)
+	na.detectOnce.Do(func() {
+		sig := make([]byte, len(goodSignature))
+		na.isArchive = fh.readInto(sig) == nil && bytes.Equal(sig, goodSignature)
+	})
+	return na.isArchive
+}
+
+// archive parses a nested archive the first time its contents are needed. The
+// inner archive has the same options, but one less level of nesting. Reads are
+// only reported to the observer for the outer member.
+func (idx *index) archive(fh *fileHeader) (*index, error) {
+	na := idx.nestedState(fh)
+	na.parseOnce.Do(func() {
+		o := idx.opts
+		o.nestedDepth--
+		o.signatureScan = 0
+		o.progress = nil
+		o.readObserver = nil
+		o.autoClose = false
+		na.idx = &index{rawFile: &arfsReader{ReadSeeker: fh.reader(), borrowed: true}, opts: o}
+		na.err = na.idx.parse(o.context())
+	})
+	return na.idx, na.err
+}
+
+// resolve follows name down through any nested archives, returning the index
+// holding the final element of the path, and the member it names, which is nil
+// for the root of the index. Unlike a flat archive, the name must be a valid
+// fs path (other than a trailing slash), as there are now directories for it
+// to wander around.
+func (idx *index) resolve(name string) (*index, *fileHeader, error) {
+	name, mustBeDir := trimDirSlash(name)
+	if !fs.ValidPath(name) {
+		return nil, nil, fs.ErrInvalid
+	}
+	if name == "." {
+		return idx, nil, nil
+	}
+	for {
+		if fh, ok := idx.lookup(name); ok {
+			if mustBeDir && !idx.isArchive(fh) {
+				return nil, nil, ErrNotDir
+			}
+			return idx, fh, nil
+		}
+		first, rest, _ := strings.Cut(name, "/")
+		fh, ok := idx.lookup(first)
+		if !ok || rest == "" || !idx.isArchive(fh) {
+			return nil, nil, fs.ErrNotExist
+		}
+		inner, err := idx.archive(fh)
+		if err != nil {
+			return nil, nil, err
+		}
+		idx, name = inner, rest
+	}
+}
+
+// resolveDir is the same as resolve, but if the path names a directory (the
+// root or a nested archive) then the index of that directory is returned as
+// well
+func (idx *index) resolveDir(name string) (*index, *fileHeader, *index, error) {
+	parent, fh, err := idx.resolve(name)
+	switch {
+	case err != nil:
+		return nil, nil, nil, err
+	case fh == nil:
+		return parent, nil, parent, nil
+	case !parent.isArchive(fh):
+		return parent, fh, nil, nil
+	}
+	dir, err := parent.archive(fh)
+	return parent, fh, dir, err
+}
+
+// nestedInfo returns the FileInfo of a member, which is a directory if it is a
+// nested archive
+func (idx *index) nestedInfo(fh *fileHeader) fs.FileInfo {
+	if idx.isArchive(fh) {
+		return dirInfo{name: fh.name, modTime: fh.ModTime()}
+	}
+	return fh
+}
+
+// nestedEntries returns the files in the archive sorted by name, with nested
+// archives as directories
+func (idx *index) nestedEntries() []fs.DirEntry {
+	entries := idx.rootEntries()
+	for i, entry := range entries {
+		if fh, ok := entry.(*fileHeader); ok && idx.isArchive(fh) {
+			entries[i] = dirInfo{name: fh.name, modTime: fh.ModTime()}
+		}
+	}
+	return entries
+}
+
+// openNested is Open for archives using WithNestedArchives
+func (idx *index) openNested(name string) (fs.File, error) {
+	parent, fh, dir, err := idx.resolveDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	switch {
+	case fh == nil:
+		return &dirFile{info: parent.dirInfo("."), entries: dir.nestedEntries()}, nil
+	case dir != nil:
+		return &dirFile{info: dirInfo{name: fh.name, modTime: fh.ModTime()}, entries: dir.nestedEntries()}, nil
+	}
+	// Files in nested archives still hold on to the outermost reader
+	return idx.track(fh.open()), nil
+}
+
+// statNested is Stat for archives using WithNestedArchives
+func (idx *index) statNested(name string) (fs.FileInfo, error) {
+	parent, fh, err := idx.resolve(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	if fh == nil {
+		return parent.dirInfo("."), nil
+	}
+	return parent.nestedInfo(fh), nil
+}
+
+// readDirNested is ReadDir for archives using WithNestedArchives
+func (idx *index) readDirNested(name string) ([]fs.DirEntry, error) {
+	_, _, dir, err := idx.resolveDir(name)
+	if err == nil && dir == nil {
+		err = fs.ErrInvalid
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return dir.nestedEntries(), nil
+}
+
+// nestedGlob hides the Glob method of an ARFS, so that fs.Glob matches each
+// element of the pattern against the directories found by ReadDir, whether
+// they are nested archives or virtual directories
+type nestedGlob struct {
+	fs.ReadDirFS
+}
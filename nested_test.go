@@ -0,0 +1,162 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestOpenArchive(t *testing.T) {
+	inner := buildArchive(t, testMember{name: "inner.txt", data: "hello from inside"})
+	outer := buildArchive(t,
+		testMember{name: "first.txt", data: "abc"},
+		testMember{name: "libinner.a", data: string(inner)},
+	)
+	ar, err := FromInterface(bytes.NewReader(outer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nested, err := ar.OpenArchive("libinner.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nested.Close()
+	data, err := nested.ReadFile("inner.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello from inside" {
+		t.Fatalf("bad nested data: %q", data)
+	}
+
+	if _, err := ar.OpenArchive("missing.a"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("missing member should fail with ErrNotExist: %v", err)
+	}
+	if _, err := ar.OpenArchive("first.txt"); errors.Is(err, fs.ErrNotExist) || !errors.Is(err, ErrTooShort) {
+		t.Fatalf("non-archive member should fail to parse: %v", err)
+	}
+}
+
+func TestOpenArchiveClosed(t *testing.T) {
+	inner := buildArchive(t, testMember{name: "x", data: "y"})
+	filename := filepath.Join(t.TempDir(), "outer.a")
+	if err := os.WriteFile(filename, buildArchive(t, testMember{name: "inner.a", data: string(inner)}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ar, err := FromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested, err := ar.OpenArchive("inner.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ar.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nested.ReadFile("x"); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("reading after the outer archive is closed should fail with ErrClosed: %v", err)
+	}
+}
+
+func TestNestedArchives(t *testing.T) {
+	deepest := buildArchive(t, testMember{name: "deep.txt", data: "bottom"})
+	inner := buildArchive(t,
+		testMember{name: "foo.o", data: "foo object"},
+		testMember{name: "libdeep.a", data: string(deepest)},
+	)
+	outer := buildArchive(t,
+		testMember{name: "first.txt", data: "abc"},
+		testMember{name: "inner.a", data: string(inner)},
+	)
+	var reads []string
+	ar, err := FromInterface(bytes.NewReader(outer), WithNestedArchives(), WithReadObserver(func(member string, off int64, n int, err error) {
+		reads = append(reads, member)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	if _, ok := ar.snapshot().nested.Load(ar.snapshot().fileHeaders["inner.a"]); ok {
+		t.Errorf("nested archive should not be examined until it is used")
+	}
+
+	data, err := ar.ReadFile("inner.a/foo.o")
+	if err != nil || string(data) != "foo object" {
+		t.Fatalf("bad nested data: %q %v", data, err)
+	}
+	data, err = ar.ReadFile("inner.a/libdeep.a/deep.txt")
+	if err != nil || string(data) != "bottom" {
+		t.Fatalf("bad doubly nested data: %q %v", data, err)
+	}
+	for _, member := range reads {
+		if member != indexMember && member != "inner.a" {
+			t.Errorf("reads of nested archives should be reported for the outer member, not %q", member)
+		}
+	}
+
+	info, err := ar.Stat("inner.a")
+	if err != nil || !info.IsDir() {
+		t.Fatalf("nested archive should be a directory: %v %v", info, err)
+	}
+	entries, err := ar.ReadDir("inner.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name() != "foo.o" || !entries[1].IsDir() {
+		t.Fatalf("bad nested directory: %v", entries)
+	}
+	if _, err := ar.Open("inner.a/missing.o"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing nested member should fail with ErrNotExist: %v", err)
+	}
+	if _, err := ar.Open("first.txt/foo.o"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("plain files should not be directories: %v", err)
+	}
+	matches, err := ar.Glob("inner.a/*/*.txt")
+	if err != nil || len(matches) != 1 || matches[0] != "inner.a/libdeep.a/deep.txt" {
+		t.Errorf("bad nested glob: %v %v", matches, err)
+	}
+
+	// The raw bytes are still available
+	f, err := ar.OpenIndex(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	raw, err := io.ReadAll(f)
+	if err != nil || !bytes.Equal(raw, inner) {
+		t.Errorf("OpenIndex should give the raw nested archive: %v", err)
+	}
+
+	if err := fstest.TestFS(ar, "first.txt", "inner.a/foo.o", "inner.a/libdeep.a/deep.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNestedArchivesDepth(t *testing.T) {
+	archive := buildArchive(t, testMember{name: "leaf.txt", data: "leaf"})
+	name := "leaf.txt"
+	for i := 0; i < nestedDepth+1; i++ {
+		archive = buildArchive(t, testMember{name: "n.a", data: string(archive)})
+		name = "n.a/" + name
+	}
+	ar, err := FromInterface(bytes.NewReader(archive), WithNestedArchives())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	if _, err := ar.Stat(name); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("archives nested too deeply should not be mounted: %v", err)
+	}
+	info, err := ar.Stat(path.Dir(name))
+	if err != nil || info.IsDir() {
+		t.Errorf("the deepest archive should be a plain file: %v %v", info, err)
+	}
+}
@@ -0,0 +1,77 @@
+package goarfs
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"io"
+)
+
+// objectSymbols returns the external symbols defined by an ELF, Mach-O or
+// COFF object file, in the order they appear in its symbol table, as listed in
+// an archive symbol index by 'ar s'. Anything else defines no symbols.
+func objectSymbols(r io.ReaderAt) []string {
+	if f, err := elf.NewFile(r); err == nil {
+		return elfSymbols(f)
+	}
+	if f, err := macho.NewFile(r); err == nil {
+		return machoSymbols(f)
+	}
+	if f, err := pe.NewFile(r); err == nil {
+		return peSymbols(f)
+	}
+	return nil
+}
+
+func elfSymbols(f *elf.File) []string {
+	syms, err := f.Symbols()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, s := range syms {
+		bind, typ := elf.ST_BIND(s.Info), elf.ST_TYPE(s.Info)
+		if s.Section == elf.SHN_UNDEF || (bind != elf.STB_GLOBAL && bind != elf.STB_WEAK) ||
+			typ == elf.STT_FILE || typ == elf.STT_SECTION {
+			continue
+		}
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// Mach-O symbol type bits, from <mach-o/nlist.h>
+const (
+	machoStab = 0xe0
+	machoType = 0x0e
+	machoUndf = 0x00
+	machoExt  = 0x01
+)
+
+func machoSymbols(f *macho.File) []string {
+	if f.Symtab == nil {
+		return nil
+	}
+	var names []string
+	for _, s := range f.Symtab.Syms {
+		if s.Type&machoStab != 0 || s.Type&machoExt == 0 || s.Type&machoType == machoUndf {
+			continue
+		}
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// imageSymClassExternal is the storage class of COFF symbols visible to other
+// objects
+const imageSymClassExternal = 2
+
+func peSymbols(f *pe.File) []string {
+	var names []string
+	for _, s := range f.Symbols {
+		if s.StorageClass == imageSymClassExternal && s.SectionNumber > 0 {
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
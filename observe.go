@@ -0,0 +1,29 @@
+package goarfs
+
+import "io"
+
+// indexMember is the member which reads made while parsing the archive are
+// reported for by WithReadObserver
+const indexMember = "<index>"
+
+// observedReader reports every read of the archive made on behalf of member
+type observedReader struct {
+	r        io.ReaderAt
+	member   string
+	observer func(member string, off int64, n int, err error)
+}
+
+func (o *observedReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := o.r.ReadAt(p, off)
+	o.observer(o.member, off, n, err)
+	return n, err
+}
+
+// readerFor returns the reader to use for reads made on behalf of member,
+// which is the archive itself unless there is a read observer
+func (idx *index) readerFor(member string) io.ReaderAt {
+	if idx.opts.readObserver == nil {
+		return idx.rawFile
+	}
+	return &observedReader{r: idx.rawFile, member: member, observer: idx.opts.readObserver}
+}
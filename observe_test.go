@@ -0,0 +1,79 @@
+package goarfs
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestReadObserver(t *testing.T) {
+	type read struct {
+		member string
+		off    int64
+		n      int
+	}
+	var mu sync.Mutex
+	var reads []read
+	observer := func(member string, off int64, n int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reads = append(reads, read{member, off, n})
+	}
+	ar, err := FromFile("testdata/gnu.a", WithReadObserver(observer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	for _, r := range reads {
+		if r.member != indexMember {
+			t.Fatalf("parsing should only read for %q, not %q", indexMember, r.member)
+		}
+	}
+	if len(reads) == 0 {
+		t.Fatalf("parsing should have been observed")
+	}
+
+	names, err := ar.Names("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	reads = nil
+	mu.Unlock()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		for _, name := range names {
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+				if _, err := ar.ReadFile(name); err != nil {
+					t.Error(err)
+				}
+			}(name)
+		}
+	}
+	wg.Wait()
+
+	total := map[string]int{}
+	for _, r := range reads {
+		offset, size, _, err := ar.Locate(r.member)
+		if err != nil {
+			t.Fatalf("read attributed to unknown member %q: %s", r.member, err)
+		}
+		if r.off < offset || r.off+int64(r.n) > offset+size {
+			t.Errorf("read of %d bytes at %d is outside %q (%d bytes at %d)", r.n, r.off, r.member, size, offset)
+		}
+		total[r.member] += r.n
+	}
+	for _, name := range names {
+		info, err := ar.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total[name] != 10*int(info.Size()) {
+			t.Errorf("%q: observed %d bytes, expected %d", name, total[name], 10*info.Size())
+		}
+	}
+	if len(total) != len(names) {
+		t.Errorf("expected reads for %d members, got %v", len(names), total)
+	}
+}
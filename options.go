@@ -0,0 +1,298 @@
+package goarfs
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Option configures how an archive is parsed & accessed by FromFile and
+// FromInterface
+type Option func(*options)
+
+type options struct {
+	caseInsensitive bool
+	duplicates      DuplicatePolicy
+	decompressors   []compression
+	normalizer      func(raw string) (string, bool)
+	progress        func(membersParsed int, bytesRead int64)
+	concurrency     int
+	signatureScan   int64
+	rawNames        bool
+	autoClose       bool
+	borrowed        bool
+	readObserver    func(member string, off int64, n int, err error)
+	nestedDepth     int
+	virtualDirs     bool
+	suggestions     bool
+	zeroModTime     bool
+	spill           bool
+	spillThreshold  int64
+	tempDir         string
+	leakLogger      *log.Logger
+	pool            *sync.Pool
+	maxReadFileSize int64
+	ctx             context.Context
+}
+
+// DuplicatePolicy controls what happens when an archive contains more than one
+// member with the same name, which is legal in AR archives. All members remain
+// available in archive order via OpenIndex regardless of the policy.
+type DuplicatePolicy int
+
+const (
+	// DuplicateLast makes the last member with a name the one which is found
+	// by that name. This is the default, and matches the behaviour of 'ar x'.
+	DuplicateLast DuplicatePolicy = iota
+	// DuplicateFirst makes the first member with a name the one which is
+	// found by that name
+	DuplicateFirst
+	// DuplicateError fails parsing with ErrDuplicate
+	DuplicateError
+	// DuplicateIndexed keeps the first member under its own name, and renames
+	// the later ones to 'name~1', 'name~2' and so on
+	DuplicateIndexed
+)
+
+// WithCaseInsensitive makes name lookups ignore case, so that Open("Foo.O")
+// finds "foo.o". Real AR archives are case sensitive, so archives containing
+// members whose names differ only by case will fail to parse with
+// ErrDuplicate.
+func WithCaseInsensitive() Option {
+	return func(o *options) {
+		o.caseInsensitive = true
+	}
+}
+
+// WithDuplicates sets the policy for members with duplicated names. The
+// default is DuplicateLast.
+func WithDuplicates(policy DuplicatePolicy) Option {
+	return func(o *options) {
+		o.duplicates = policy
+	}
+}
+
+// WithNameNormalizer maps the name of each member as the archive is parsed.
+// The returned name is used for lookups & listings, and returning false drops
+// the member entirely. Symbol indexes and long filename tables are not passed
+// to fn. Mapping distinct names to the same one fails with ErrDuplicate. The
+// original name is available as Header.RawName.
+func WithNameNormalizer(fn func(raw string) (string, bool)) Option {
+	return func(o *options) {
+		o.normalizer = fn
+	}
+}
+
+// WithProgress calls fn after each member is parsed, with the number of
+// members (including symbol indexes & long filename tables) and bytes of the
+// archive parsed so far. It is called synchronously, and never after parsing
+// has finished. The same fn is also used when the archive is refreshed.
+func WithProgress(fn func(membersParsed int, bytesRead int64)) Option {
+	return func(o *options) {
+		o.progress = fn
+	}
+}
+
+// WithConcurrency allows bulk operations such as ChecksumAll to process up to n
+// members at once. The default is to process them one at a time.
+func WithConcurrency(n int) Option {
+	return func(o *options) {
+		o.concurrency = n
+	}
+}
+
+// WithSignatureScan allows the archive to have up to maxOffset bytes of
+// preamble before the signature, such as a byte order mark or a prepended
+// header. By default the signature must be at the very start.
+func WithSignatureScan(maxOffset int64) Option {
+	return func(o *options) {
+		o.signatureScan = maxOffset
+	}
+}
+
+// WithRawNames keeps member names exactly as they are stored, only removing
+// the padding & terminator which follow them, rather than also trimming any
+// surrounding spaces, so that names with leading spaces survive. Trailing
+// spaces can only be preserved for extended names and GNU short names, as the
+// BSD short name format can't distinguish them from padding.
+func WithRawNames() Option {
+	return func(o *options) {
+		o.rawNames = true
+	}
+}
+
+// WithAutoClose keeps the underlying file open while any file returned by Open
+// or OpenIndex is still open, so that the archive can be closed as soon as no
+// new files are needed. The underlying file is then closed when the last open
+// file is closed. This also applies to files opened before a Refresh.
+func WithAutoClose() Option {
+	return func(o *options) {
+		o.autoClose = true
+	}
+}
+
+// WithoutOwnership stops Close from closing the reader given to FromInterface
+// or FromReader, for when its lifetime is managed by the caller. By default it
+// is closed if it implements io.Closer. Files opened by FromFile are always
+// closed.
+func WithoutOwnership() Option {
+	return func(o *options) {
+		o.borrowed = true
+	}
+}
+
+// WithReadObserver calls fn after every read of the underlying archive, with
+// the name of the member the read was for, the offset within the archive, and
+// the result of the read. Reads made while parsing the archive are reported
+// for the member "<index>". fn may be called concurrently, and is never called
+// with a lock held.
+func WithReadObserver(fn func(member string, off int64, n int, err error)) Option {
+	return func(o *options) {
+		o.readObserver = fn
+	}
+}
+
+// WithNestedArchives makes members which are themselves AR archives appear as
+// directories to Open, Stat, ReadDir, ReadFile and Glob, so that
+// Open("libinner.a/foo.o") reads foo.o from inside libinner.a. Names must then
+// be valid fs paths, as checked by fs.ValidPath. A member (including a .deb
+// package) is recognised as an archive by its signature, and is only parsed
+// when a path inside it is first used, after which it is cached until the next
+// Refresh. Archives are mounted up to 8 levels deep, below which they are left
+// as plain files. The raw bytes of a nested archive remain available through
+// OpenIndex, CopyTo, ReadFileInto, RawMember and OpenArchive, which always
+// treat members as files.
+func WithNestedArchives() Option {
+	return func(o *options) {
+		o.nestedDepth = nestedDepth
+	}
+}
+
+// WithVirtualDirs makes the slashes in member names form a tree of
+// directories, as written by Create from a nested fs.FS, so that "pkg/a.o"
+// is listed by ReadDir("pkg") rather than the root, and fs.WalkDir recreates
+// the original tree. The directories are found from the names when first
+// needed. Names must then be valid fs paths, as checked by fs.ValidPath, so
+// members whose names aren't (such as "/abs.o") are neither listed nor found,
+// other than through OpenIndex. A member hides a directory with the same
+// name. It has no effect with WithNestedArchives, which takes precedence.
+func WithVirtualDirs() Option {
+	return func(o *options) {
+		o.virtualDirs = true
+	}
+}
+
+// WithSuggestions makes Open, Stat and ReadFile report a missing member with a
+// NotFoundError, which lists up to three members with similar names, to help
+// track down typos and stale names. Only the first few thousand members are
+// considered, and symbol indexes & long filename tables are never suggested.
+func WithSuggestions() Option {
+	return func(o *options) {
+		o.suggestions = true
+	}
+}
+
+// WithZeroModTime makes ModTime return the zero time.Time for members with a
+// modification time of 0, as written to deterministic archives, rather than
+// January 1 1970, so that callers can check IsZero and fall back to something
+// sensible. ExtractAll & Extract then leave the modification time of the
+// extracted file alone, and http.FileServer doesn't send a Last-Modified
+// header. The raw value is still reported by Header.ModTime.
+func WithZeroModTime() Option {
+	return func(o *options) {
+		o.zeroModTime = true
+	}
+}
+
+// WithSpillThreshold makes FromReader copy archives larger than threshold bytes
+// to a temporary file rather than holding them in memory. The temporary file is
+// removed when the archive is closed. It has no effect on readers which are
+// already an io.ReadSeeker, as they are used directly.
+func WithSpillThreshold(threshold int64) Option {
+	return func(o *options) {
+		o.spill = true
+		o.spillThreshold = threshold
+	}
+}
+
+// WithTempDir sets the directory used for the temporary files made by
+// WithSpillThreshold, instead of os.TempDir. On its own it spills archives
+// larger than 32MiB.
+func WithTempDir(dir string) Option {
+	return func(o *options) {
+		o.spill = true
+		o.tempDir = dir
+	}
+}
+
+// WithLeakWarning logs a warning to logger if the archive is garbage collected
+// without Close having been called, to help track down leaked file
+// descriptors during development. It is off by default.
+func WithLeakWarning(logger *log.Logger) Option {
+	return func(o *options) {
+		o.leakLogger = logger
+	}
+}
+
+// WithBufferPool sets the pool of buffers lent out by ReadFileFunc, which must
+// only hold *[]byte, so that it can be shared with other archives or code. By
+// default a pool shared by every archive is used.
+func WithBufferPool(pool *sync.Pool) Option {
+	return func(o *options) {
+		o.pool = pool
+	}
+}
+
+// WithMaxReadFileSize makes ReadFile fail with ErrTooLarge for members larger
+// than limit bytes, before anything is allocated for them, so that a crafted
+// archive claiming a huge member can't exhaust memory. Files returned by Open
+// are unaffected, so large members can still be streamed.
+func WithMaxReadFileSize(limit int64) Option {
+	return func(o *options) {
+		o.maxReadFileSize = limit
+	}
+}
+
+// WithContext abandons parsing with ctx.Err() if ctx is cancelled, for
+// constructors which don't take a context directly, and for Refresh
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// context returns the context given by WithContext
+func (o *options) context() context.Context {
+	if o.ctx == nil {
+		return context.Background()
+	}
+	return o.ctx
+}
+
+// checkReadSize fails with ErrTooLarge if a member of the given size is larger
+// than allowed by WithMaxReadFileSize
+func (o *options) checkReadSize(name string, size int64) error {
+	if o.maxReadFileSize > 0 && size > o.maxReadFileSize {
+		return &fs.PathError{Op: "read", Path: name, Err: fmt.Errorf("%w: %d bytes is over %d", ErrTooLarge, size, o.maxReadFileSize)}
+	}
+	return nil
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// key returns the name used to index a member
+func (o *options) key(name string) string {
+	if o.caseInsensitive {
+		return strings.ToLower(name)
+	}
+	return name
+}
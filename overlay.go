@@ -0,0 +1,100 @@
+package goarfs
+
+import (
+	"errors"
+	"io/fs"
+	"sort"
+)
+
+// overlayFS serves files from primary, falling back to an archive
+type overlayFS struct {
+	primary  fs.FS
+	fallback *ARFS
+}
+
+var _ fs.ReadDirFS = (*overlayFS)(nil)
+var _ fs.ReadFileFS = (*overlayFS)(nil)
+var _ fs.StatFS = (*overlayFS)(nil)
+
+// Overlay returns an fs.FS which serves files from primary (such as
+// os.DirFS(".") during development) when they exist there, and otherwise from
+// the fallback archive. Errors from primary other than fs.ErrNotExist are
+// returned rather than falling back.
+func Overlay(primary fs.FS, fallback *ARFS) fs.FS {
+	return &overlayFS{primary: primary, fallback: fallback}
+}
+
+// Open returns directories which exist in primary with the merged listing of
+// ReadDir, so that walking the overlay also finds the members of the archive
+func (o *overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.primary.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.fallback.Open(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !info.IsDir() {
+		return f, nil
+	}
+	f.Close()
+	entries, err := o.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	return &dirFile{info: info, entries: entries}, nil
+}
+
+func (o *overlayFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := fs.Stat(o.primary, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.fallback.Stat(name)
+	}
+	return info, err
+}
+
+func (o *overlayFS) ReadFile(name string) ([]byte, error) {
+	data, err := fs.ReadFile(o.primary, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.fallback.ReadFile(name)
+	}
+	return data, err
+}
+
+// ReadDir merges the listings of both, with entries from primary hiding those
+// of the same name in the archive
+func (o *overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	primary, err := fs.ReadDir(o.primary, name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	fallback, ferr := o.fallback.ReadDir(name)
+	if ferr != nil {
+		if err != nil {
+			// Missing from both
+			return nil, err
+		}
+		fallback = nil
+	}
+
+	merged := map[string]fs.DirEntry{}
+	for _, entry := range fallback {
+		merged[entry.Name()] = entry
+	}
+	for _, entry := range primary {
+		merged[entry.Name()] = entry
+	}
+	ret := make([]fs.DirEntry, 0, len(merged))
+	for _, entry := range merged {
+		ret = append(ret, entry)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name() < ret[j].Name()
+	})
+	return ret, nil
+}
@@ -0,0 +1,120 @@
+package goarfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// failFS fails every operation with a permission error
+type failFS struct{}
+
+func (failFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+}
+
+func TestOverlay(t *testing.T) {
+	ar, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	primary := fstest.MapFS{
+		"test2.dat": {Data: []byte("local")},
+		"local.txt": {Data: []byte("only local")},
+	}
+	o := Overlay(primary, ar)
+
+	for name, expected := range map[string]string{
+		"test2.dat": "local",
+		"local.txt": "only local",
+		"test1.dat": "abcdefghijklmnopqrstuvwxyz",
+	} {
+		data, err := fs.ReadFile(o, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != expected {
+			t.Errorf("%s: expected %q, got %q", name, expected, data)
+		}
+		f, err := o.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		if info.Size() != int64(len(expected)) {
+			t.Errorf("%s: wrong size %d", name, info.Size())
+		}
+	}
+	if _, err := fs.Stat(o, "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing file should fail with ErrNotExist: %v", err)
+	}
+
+	entries, err := fs.ReadDir(o, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, e.Name())
+		if e.Name() == "test2.dat" && info.Size() != 5 {
+			t.Errorf("listing should show the local test2.dat")
+		}
+	}
+	if strings.Join(names, " ") != "local.txt test1.dat test2.dat" {
+		t.Fatalf("bad merged listing: %v", names)
+	}
+
+	broken := Overlay(failFS{}, ar)
+	if _, err := fs.ReadFile(broken, "test1.dat"); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("primary errors should not fall back: %v", err)
+	}
+	if _, err := fs.ReadDir(broken, "."); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("primary errors should not fall back: %v", err)
+	}
+}
+
+func TestOverlayFS(t *testing.T) {
+	ar, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "local.txt"), []byte("only local"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	o := Overlay(os.DirFS(dir), ar)
+	if err := fstest.TestFS(o, "local.txt", "short.o", "a_very_long_object_file_name.o"); err != nil {
+		t.Fatal(err)
+	}
+
+	var walked []string
+	err = fs.WalkDir(o, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			walked = append(walked, name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(walked, " ") != "a_very_long_object_file_name.o local.txt notes_with_a_long_name.txt short.o" {
+		t.Fatalf("walk should find local files and members: %v", walked)
+	}
+}
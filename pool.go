@@ -0,0 +1,50 @@
+package goarfs
+
+import (
+	"io/fs"
+	"sync"
+)
+
+// defaultBufferPool holds the buffers lent out by ReadFileFunc when no pool is
+// given with WithBufferPool
+var defaultBufferPool sync.Pool
+
+// bufferPool returns the pool of *[]byte used by ReadFileFunc
+func (o *options) bufferPool() *sync.Pool {
+	if o.pool == nil {
+		return &defaultBufferPool
+	}
+	return o.pool
+}
+
+// ReadFileFunc reads the contents of the named member into a buffer borrowed
+// from a pool, and calls fn with it. The buffer is returned to the pool once fn
+// returns, so data must not be retained or modified after that; copy it if it
+// is needed for longer. Reusing buffers makes this much cheaper than ReadFile
+// when reading many members, such as when serving them over HTTP. The error
+// from fn is returned as is.
+func (a *ARFS) ReadFileFunc(name string, fn func(data []byte) error) error {
+	idx := a.snapshot()
+	fh, ok := idx.lookup(name)
+	if !ok {
+		return &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	if err := idx.opts.checkReadSize(name, fh.Size()); err != nil {
+		return err
+	}
+	pool := idx.opts.bufferPool()
+	bp, _ := pool.Get().(*[]byte)
+	if bp == nil {
+		bp = new([]byte)
+	}
+	defer pool.Put(bp)
+	size := int(fh.Size())
+	if cap(*bp) < size {
+		*bp = make([]byte, size)
+	}
+	data := (*bp)[:size]
+	if err := fh.readInto(data); err != nil {
+		return &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return fn(data)
+}
@@ -0,0 +1,107 @@
+package goarfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"sort"
+)
+
+var ErrBadIndex = errors.New("invalid AR index entry")
+
+// Index returns the headers of every member in archive order, with Offset &
+// HeaderOffset set, so that the archive can later be opened with NewFromIndex
+// without parsing it. Symbol indexes and long filename tables are not
+// included.
+func (a *ARFS) Index() []Header {
+	return a.List()
+}
+
+// readerAtSize determines the length of r, if it is able to report it
+func readerAtSize(r io.ReaderAt) (int64, bool) {
+	switch sized := r.(type) {
+	case interface{ Size() int64 }:
+		return sized.Size(), true
+	case interface{ Stat() (fs.FileInfo, error) }:
+		info, err := sized.Stat()
+		if err != nil {
+			return 0, false
+		}
+		return info.Size(), true
+	}
+	return 0, false
+}
+
+// NewFromIndex creates an ARFS over the archive in r using entries (such as
+// those returned by Index) instead of parsing the member headers. Each entry
+// needs its Name, Offset & Size set, along with HeaderOffset for members with a
+// BSD long filename; without it the header is taken to immediately precede the
+// data. The entries are only checked to be within the archive, when the size
+// of r can be determined, and not to overlap, so they must describe r
+// accurately. Close does not close r.
+func NewFromIndex(r io.ReaderAt, entries []Header, opts ...Option) (*ARFS, error) {
+	sorted := append([]Header(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Offset < sorted[j].Offset
+	})
+
+	size, known := readerAtSize(r)
+	var end int64
+	for i := range sorted {
+		h := &sorted[i]
+		if h.HeaderOffset == 0 {
+			h.HeaderOffset = h.Offset - headerSize
+		}
+		switch {
+		case h.Name == "":
+			return nil, fmt.Errorf("%w: empty name at offset %d", ErrBadIndex, h.Offset)
+		case h.Offset < 0 || h.Size < 0 || h.Size > math.MaxUint32:
+			return nil, fmt.Errorf("%w: %q has offset %d and size %d", ErrBadIndex, h.Name, h.Offset, h.Size)
+		case h.HeaderOffset < 0 || h.HeaderOffset > h.Offset-headerSize:
+			return nil, fmt.Errorf("%w: %q has its header at %d, too close to its data at %d", ErrBadIndex, h.Name, h.HeaderOffset, h.Offset)
+		case known && h.Offset+h.Size > size:
+			return nil, fmt.Errorf("%w: %q ends at %d, beyond end of archive at %d", ErrBadIndex, h.Name, h.Offset+h.Size, size)
+		case i > 0 && h.HeaderOffset < end:
+			return nil, fmt.Errorf("%w: %q overlaps %q", ErrBadIndex, h.Name, sorted[i-1].Name)
+		}
+		end = h.Offset + h.Size
+	}
+	if !known {
+		size = end
+	}
+
+	idx := &index{
+		rawFile:     &arfsReader{ReadSeeker: io.NewSectionReader(r, 0, size)},
+		opts:        newOptions(opts),
+		size:        size,
+		fileHeaders: make(map[string]*fileHeader, len(sorted)),
+	}
+	for _, h := range sorted {
+		// The padding follows the extended filename & data together
+		stored := h.Offset - h.HeaderOffset - headerSize + h.Size
+		fh := &fileHeader{
+			name:         h.Name,
+			rawName:      h.RawName,
+			modification: h.ModTime,
+			owner:        uint32(h.UID),
+			group:        uint32(h.GID),
+			mode:         h.Mode,
+			size:         uint32(h.Size),
+			headerOffset: h.HeaderOffset,
+			offset:       h.Offset,
+			span:         h.Size + stored%2,
+		}
+		if fh.rawName == "" {
+			fh.rawName = fh.name
+		}
+		if err := idx.addMember(fh); err != nil {
+			return nil, err
+		}
+		fh.sectionReader = io.NewSectionReader(idx.readerFor(fh.name), fh.offset, fh.Size())
+	}
+	a := &ARFS{opts: idx.opts}
+	a.idx.Store(idx)
+	return a.watchLeaks(), nil
+}
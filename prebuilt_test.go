@@ -0,0 +1,111 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestNewFromIndex(t *testing.T) {
+	raw, err := os.ReadFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar, err := FromInterface(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := ar.Index()
+
+	prebuilt, err := NewFromIndex(bytes.NewReader(raw), entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prebuilt.Close()
+	if len(prebuilt.List()) != len(entries) {
+		t.Fatalf("expected %d members, got %d", len(entries), len(prebuilt.List()))
+	}
+	for i, hdr := range entries {
+		if prebuilt.List()[i] != hdr {
+			t.Errorf("member %d differs: %#v vs %#v", i, prebuilt.List()[i], hdr)
+		}
+		expected, err := ar.ReadFile(hdr.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := prebuilt.ReadFile(hdr.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, expected) {
+			t.Errorf("%q has the wrong contents", hdr.Name)
+		}
+	}
+
+	overlapping := append([]Header(nil), entries...)
+	overlapping[1].Offset = overlapping[0].Offset + 1
+	if _, err := NewFromIndex(bytes.NewReader(raw), overlapping); !errors.Is(err, ErrBadIndex) {
+		t.Errorf("overlapping entries should fail with ErrBadIndex: %v", err)
+	}
+	beyond := []Header{{Name: "beyond", Offset: int64(len(raw)) - 2, Size: 10}}
+	if _, err := NewFromIndex(bytes.NewReader(raw), beyond); !errors.Is(err, ErrBadIndex) {
+		t.Errorf("entries past the end should fail with ErrBadIndex: %v", err)
+	}
+}
+
+func TestNewFromIndexBSDLongNames(t *testing.T) {
+	// The extended filenames come between the header & the data, and are
+	// padded along with the data
+	for _, filename := range []string{"testdata/darwin.a", "testdata/extended.ar"} {
+		raw, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ar, err := FromInterface(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		prebuilt, err := NewFromIndex(bytes.NewReader(raw), ar.Index())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer prebuilt.Close()
+
+		var padding int64
+		for _, hdr := range ar.Index() {
+			offset, size, padded, err := ar.Locate(hdr.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			padding += padded - size
+			if o, s, p, err := prebuilt.Locate(hdr.Name); err != nil || o != offset || s != size || p != padded {
+				t.Errorf("%s: %q located at %d+%d (%d), should be %d+%d (%d): %v", filename, hdr.Name, o, s, p, offset, size, padded, err)
+			}
+
+			expected, err := ar.RawMember(hdr.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := prebuilt.RawMember(hdr.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectedRaw, err := io.ReadAll(expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotRaw, err := io.ReadAll(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(gotRaw, expectedRaw) {
+				t.Errorf("%s: %q has the wrong raw member: %q", filename, hdr.Name, gotRaw)
+			}
+		}
+		if info := prebuilt.Info(); info.PaddingBytes != padding {
+			t.Errorf("%s: %d padding bytes, should be %d", filename, info.PaddingBytes, padding)
+		}
+	}
+}
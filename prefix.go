@@ -0,0 +1,146 @@
+package goarfs
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// prefixFS is a view of an archive with every member inside a directory
+type prefixFS struct {
+	a      *ARFS
+	prefix string
+}
+
+var _ fs.ReadDirFS = (*prefixFS)(nil)
+var _ fs.ReadFileFS = (*prefixFS)(nil)
+var _ fs.StatFS = (*prefixFS)(nil)
+var _ fs.GlobFS = (*prefixFS)(nil)
+
+// WithPrefix returns a view of the archive with every member inside the
+// directory prefix, so that with a prefix of "icons" the member "foo.png" is
+// found as "icons/foo.png". The prefix may have several levels, such as
+// "assets/icons", and each of them appears as a directory. It must be a valid
+// fs path other than ".", as checked by fs.ValidPath, or fs.ErrInvalid is
+// returned.
+func (a *ARFS) WithPrefix(prefix string) (fs.FS, error) {
+	if !fs.ValidPath(prefix) || prefix == "." {
+		return nil, &fs.PathError{Op: "prefix", Path: prefix, Err: fs.ErrInvalid}
+	}
+	return &prefixFS{a: a, prefix: prefix}, nil
+}
+
+// getHeader finds the member for a name within the view
+func (p *prefixFS) getHeader(idx *index, name string) (*fileHeader, bool) {
+	member, ok := strings.CutPrefix(name, p.prefix+"/")
+	if !ok || !fs.ValidPath(name) {
+		return nil, false
+	}
+	fh, ok := idx.lookup(member)
+	if !ok || fh.special {
+		return nil, false
+	}
+	return fh, true
+}
+
+// dir returns the contents of name if it is one of the directories of the
+// view: the root, the levels of the prefix, or the prefix itself which holds
+// the members
+func (p *prefixFS) dir(name string) ([]fs.DirEntry, bool) {
+	idx := p.a.snapshot()
+	if name == p.prefix {
+		return idx.rootEntries(), true
+	}
+	var rest string
+	switch {
+	case name == ".":
+		rest = p.prefix
+	case strings.HasPrefix(p.prefix, name+"/"):
+		rest = p.prefix[len(name)+1:]
+	default:
+		return nil, false
+	}
+	child, _, _ := strings.Cut(rest, "/")
+	return []fs.DirEntry{idx.dirInfo(child)}, true
+}
+
+// file finds the member for a name within the view, which fails with
+// ErrNotDir if the name has a trailing slash
+func (p *prefixFS) file(idx *index, op, name string) (*fileHeader, error) {
+	clean, mustBeDir := trimDirSlash(name)
+	fh, ok := p.getHeader(idx, clean)
+	switch {
+	case !ok:
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	case mustBeDir:
+		return nil, &fs.PathError{Op: op, Path: name, Err: ErrNotDir}
+	}
+	return fh, nil
+}
+
+func (p *prefixFS) Open(name string) (fs.File, error) {
+	clean, _ := trimDirSlash(name)
+	if entries, ok := p.dir(clean); ok {
+		return &dirFile{info: p.a.snapshot().dirInfo(path.Base(clean)), entries: entries}, nil
+	}
+	idx := p.a.snapshot()
+	fh, err := p.file(idx, "open", name)
+	if err != nil {
+		return nil, err
+	}
+	return idx.track(fh.open()), nil
+}
+
+func (p *prefixFS) Stat(name string) (fs.FileInfo, error) {
+	clean, _ := trimDirSlash(name)
+	if _, ok := p.dir(clean); ok {
+		return p.a.snapshot().dirInfo(path.Base(clean)), nil
+	}
+	fh, err := p.file(p.a.snapshot(), "stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fh, nil
+}
+
+func (p *prefixFS) ReadFile(name string) ([]byte, error) {
+	idx := p.a.snapshot()
+	fh, err := p.file(idx, "read", name)
+	if err != nil {
+		return nil, err
+	}
+	return idx.readFile(name, fh)
+}
+
+func (p *prefixFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	clean, _ := trimDirSlash(name)
+	entries, ok := p.dir(clean)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return entries, nil
+}
+
+// Glob matches pattern against the full path of every directory & member in
+// the view. As '*' doesn't match '/', this gives the same results as fs.Glob.
+func (p *prefixFS) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	candidates := []string{}
+	for dir := p.prefix; dir != "."; dir = path.Dir(dir) {
+		candidates = append(candidates, dir)
+	}
+	for _, entry := range p.a.snapshot().rootEntries() {
+		candidates = append(candidates, p.prefix+"/"+entry.Name())
+	}
+	var matches []string
+	for _, name := range candidates {
+		if match, _ := path.Match(pattern, name); match {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
@@ -0,0 +1,80 @@
+package goarfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestWithPrefix(t *testing.T) {
+	ar, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	view, err := ar.WithPrefix("assets/icons")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(view, "assets/icons/short.o", "assets/icons/notes_with_a_long_name.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := fs.ReadFile(view, "assets/icons/notes_with_a_long_name.txt")
+	if err != nil || string(data) != "hello\n" {
+		t.Fatalf("cannot read through the prefix: %q %v", data, err)
+	}
+	if _, err := view.Open("short.o"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("members should only be found under the prefix: %v", err)
+	}
+	for dir, expected := range map[string]string{
+		".":            "assets",
+		"assets":       "icons",
+		"assets/icons": "a_very_long_object_file_name.o notes_with_a_long_name.txt short.o",
+	} {
+		entries, err := fs.ReadDir(view, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		if strings.Join(names, " ") != expected {
+			t.Errorf("%q: expected %q, got %q", dir, expected, names)
+		}
+	}
+	matches, err := fs.Glob(view, "assets/*/*.o")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(matches, " ") != "assets/icons/a_very_long_object_file_name.o assets/icons/short.o" {
+		t.Fatalf("wrong glob matches: %v", matches)
+	}
+
+	for _, prefix := range []string{"", ".", "/icons", "icons/", "a//b", "../icons"} {
+		if _, err := ar.WithPrefix(prefix); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("prefix %q should be rejected: %v", prefix, err)
+		}
+	}
+}
+
+func TestWithPrefixTruncated(t *testing.T) {
+	ar, err := FromFile("testdata/truncated.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	view, err := ar.WithPrefix("data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := fs.ReadFile(view, "data/cutoff.txt")
+	if !errors.Is(err, io.ErrUnexpectedEOF) || len(data) != 100 {
+		t.Fatalf("reading a truncated member should fail with ErrUnexpectedEOF: %d %v", len(data), err)
+	}
+}
@@ -0,0 +1,67 @@
+package goarfs
+
+import (
+	"io/fs"
+	"os"
+)
+
+// Refresh re-parses the archive to pick up any changes made to it since it was
+// opened. For archives loaded with FromFile the file is re-opened by name, so
+// an archive which has been atomically replaced is handled correctly.
+//
+// The new index is swapped in atomically if parsing succeeds; on failure the
+// existing index remains in use. Files opened from a FromFile archive before
+// the refresh fail with an error wrapping fs.ErrClosed once the refresh
+// completes. Archives from FromInterface are re-parsed from the same reader, so
+// files opened before the refresh are only valid if their contents have not
+// moved. Refreshing a closed archive fails with fs.ErrClosed.
+func (a *ARFS) Refresh() error {
+	a.refreshMu.Lock()
+	defer a.refreshMu.Unlock()
+	if a.closed {
+		return &fs.PathError{Op: "refresh", Path: a.filename, Err: fs.ErrClosed}
+	}
+
+	old := a.snapshot()
+	if a.filename == "" {
+		idx := &index{rawFile: old.rawFile, opts: a.opts}
+		if err := idx.parse(a.opts.context()); err != nil {
+			return err
+		}
+		a.idx.Store(idx)
+		return nil
+	}
+
+	f, err := os.Open(a.filename)
+	if err != nil {
+		return err
+	}
+	idx, err := parseFile(a.opts.context(), f, a.opts)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	a.idx.Store(idx)
+	return old.rawFile.release()
+}
+
+// Modified is a cheap check of whether the archive appears to have changed
+// since it was last parsed, and so needs a Refresh. It compares the size of the
+// archive, and for archives loaded with FromFile, the identity & modification
+// time of the file.
+func (a *ARFS) Modified() (bool, error) {
+	idx := a.snapshot()
+	if a.filename == "" {
+		size, err := idx.rawFile.size()
+		if err != nil {
+			return false, err
+		}
+		return size != idx.size, nil
+	}
+
+	info, err := os.Stat(a.filename)
+	if err != nil {
+		return false, err
+	}
+	return !os.SameFile(info, idx.info) || info.Size() != idx.info.Size() || !info.ModTime().Equal(idx.info.ModTime()), nil
+}
@@ -0,0 +1,196 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRefresh(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.ar")
+	if err := os.WriteFile(filename, buildArchive(t, testMember{name: "a.txt", data: "first"}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ar, err := FromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	if modified, err := ar.Modified(); err != nil || modified {
+		t.Fatalf("archive should not be modified yet: %v %v", modified, err)
+	}
+	old, err := ar.Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Atomically replace the archive with a new one
+	replacement := filepath.Join(dir, "replacement.ar")
+	data := buildArchive(t, testMember{name: "b.txt", data: "bee"}, testMember{name: "a.txt", data: "second"})
+	if err := os.WriteFile(replacement, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(replacement, filename); err != nil {
+		t.Fatal(err)
+	}
+	if modified, err := ar.Modified(); err != nil || !modified {
+		t.Fatalf("archive should be modified: %v %v", modified, err)
+	}
+	if err := ar.Refresh(); err != nil {
+		t.Fatalf("refresh: %s", err)
+	}
+	if modified, err := ar.Modified(); err != nil || modified {
+		t.Fatalf("archive should not be modified after refresh: %v %v", modified, err)
+	}
+
+	contents, err := ar.ReadFile("a.txt")
+	if err != nil || string(contents) != "second" {
+		t.Fatalf("a.txt not refreshed: %q %v", contents, err)
+	}
+	if _, err := ar.Stat("b.txt"); err != nil {
+		t.Fatalf("b.txt missing after refresh: %s", err)
+	}
+	// Handles from before the refresh fail cleanly
+	if _, err := io.ReadAll(old); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("reading a stale handle should fail with ErrClosed: %v", err)
+	}
+}
+
+func TestRefreshInterface(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(buildArchive(t, testMember{name: "a.txt", data: "first"}))
+	raw := bytes.NewReader(buf.Bytes())
+	ar, err := FromInterface(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Append a second member to the archive
+	buf.Write(buildArchive(t, testMember{name: "b.txt", data: "appended"})[len(goodSignature):])
+	raw.Reset(buf.Bytes())
+	if modified, err := ar.Modified(); err != nil || !modified {
+		t.Fatalf("archive should be modified: %v %v", modified, err)
+	}
+	if err := ar.Refresh(); err != nil {
+		t.Fatalf("refresh: %s", err)
+	}
+	contents, err := ar.ReadFile("b.txt")
+	if err != nil || string(contents) != "appended" {
+		t.Fatalf("b.txt not visible after refresh: %q %v", contents, err)
+	}
+
+	// A failed refresh leaves the existing index in place
+	raw.Reset([]byte("garbage"))
+	if err := ar.Refresh(); err == nil {
+		t.Fatalf("refresh of a corrupt archive should fail")
+	}
+	if _, err := ar.Stat("b.txt"); err != nil {
+		t.Fatalf("failed refresh lost the existing index: %s", err)
+	}
+}
+
+func TestRefreshClosed(t *testing.T) {
+	f, err := os.Open("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	raw := &closeCounter{ReadSeeker: f}
+	ar, err := FromInterface(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ar.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ar.Refresh(); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("refreshing a closed archive should fail with ErrClosed: %v", err)
+	}
+	if closes := raw.closes.Load(); closes != 1 {
+		t.Fatalf("archive should only be closed once, closed %d times", closes)
+	}
+
+	fromFile, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fromFile.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fromFile.Refresh(); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("refreshing a closed archive should fail with ErrClosed: %v", err)
+	}
+}
+
+// slowReader pauses before each Read, to widen the gap between the Seek & Read
+// when faking ReadAt
+type slowReader struct {
+	io.ReadSeeker
+}
+
+func (s slowReader) Read(p []byte) (int, error) {
+	time.Sleep(10 * time.Microsecond)
+	return s.ReadSeeker.Read(p)
+}
+
+func TestRefreshConcurrentRead(t *testing.T) {
+	// Without ReadAt, reads are faked with a Seek & Read, which re-parsing
+	// mustn't move in between
+	raw := buildArchive(t,
+		testMember{name: "a.txt", data: "first member"},
+		testMember{name: "b.txt", data: "second member"},
+	)
+	ar, err := FromInterface(slowReader{bytes.NewReader(raw)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	f, err := ar.Open("b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		t.Fatal("member should implement io.ReaderAt")
+	}
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		buf := make([]byte, len("second member"))
+		for {
+			select {
+			case <-stop:
+				done <- nil
+				return
+			default:
+			}
+			if _, err := ra.ReadAt(buf, 0); err != nil {
+				done <- err
+				return
+			}
+			if string(buf) != "second member" {
+				done <- fmt.Errorf("read %q during refresh", buf)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if err := ar.Refresh(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,301 @@
+package goarfs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"time"
+)
+
+// RewriteOption configures the changes made by Rewrite
+type RewriteOption func(*rewriteOptions)
+
+type rewriteOptions struct {
+	writer        []WriterOption
+	remove        []string
+	removeGlobs   []string
+	renames       []rename
+	replacements  []*replacement
+	ignoreMissing bool
+}
+
+// rename is a change of name requested with Rename
+type rename struct {
+	oldName, newName string
+}
+
+// replacement is new contents for a member given with Replace
+type replacement struct {
+	name string
+	r    io.Reader
+	size int64
+	hdr  *Header
+}
+
+// WithWriterOptions passes opts to the Writer used by Rewrite, such as
+// WithFormat to convert the archive to another format
+func WithWriterOptions(opts ...WriterOption) RewriteOption {
+	return func(o *rewriteOptions) {
+		o.writer = append(o.writer, opts...)
+	}
+}
+
+// Remove leaves out every member with one of the given names, like 'ar d'
+func Remove(names ...string) RewriteOption {
+	return func(o *rewriteOptions) {
+		o.remove = append(o.remove, names...)
+	}
+}
+
+// RemoveGlob leaves out every member whose name matches pattern, using the
+// syntax of path.Match
+func RemoveGlob(pattern string) RewriteOption {
+	return func(o *rewriteOptions) {
+		o.removeGlobs = append(o.removeGlobs, pattern)
+	}
+}
+
+// Rename gives every member called oldName the name newName, keeping its
+// position in the archive. Renames are applied in the order given, after any
+// removals. If newName is already taken, the duplicate policy of the archive
+// applies, in the same way as for MutableARFS.Rename.
+func Rename(oldName, newName string) RewriteOption {
+	return func(o *rewriteOptions) {
+		o.renames = append(o.renames, rename{oldName, newName})
+	}
+}
+
+// Replace gives the member called name the contents of r, like 'ar r'. The
+// member keeps its position in the archive, or is added to the end if there
+// isn't one. size is the length of r, or negative if it isn't known, in which
+// case r is measured or buffered as for Writer.WriteFrom. The modification
+// time, mode & ownership are taken from hdr, or kept from the original member
+// if hdr is nil (with the defaults of MutableARFS.WriteFile for a new one).
+// Where the name is duplicated, the member found by the name is replaced.
+// Replacements are applied after any removals & renames, and a later
+// replacement of the same name wins.
+func Replace(name string, r io.Reader, size int64, hdr *Header) RewriteOption {
+	return func(o *rewriteOptions) {
+		o.replacements = append(o.replacements, &replacement{name, r, size, hdr})
+	}
+}
+
+// IgnoreMissing allows Remove, RemoveGlob and Rename to match no members,
+// which otherwise fails Rewrite with an error wrapping fs.ErrNotExist
+func IgnoreMissing() RewriteOption {
+	return func(o *rewriteOptions) {
+		o.ignoreMissing = true
+	}
+}
+
+// WriteTo writes the archive back out to w in the format it was read in (or
+// FormatBSD if that couldn't be determined), as Rewrite does. It implements
+// io.WriterTo, returning the number of bytes written.
+func (a *ARFS) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	err := a.Rewrite(cw)
+	return cw.n, err
+}
+
+// Rewrite writes every file in the archive to w in archive order, including
+// any with duplicated names, applying any changes given by opts: members can be
+// left out with Remove & RemoveGlob, renamed with Rename, and given new
+// contents with Replace. It is written in the format it was read in unless
+// WithWriterOptions selects another. The changes are all checked before
+// anything is written, so a missing member or a bad pattern doesn't leave a
+// partial archive behind.
+//
+// The headers are regenerated from Header, so names are re-encoded as the
+// output format requires, and member data is streamed straight from the
+// archive. An archive without a symbol index which was written by Writer (or
+// GNU ar) in the same format is reproduced byte for byte.
+//
+// Long filename tables are rebuilt as needed. Symbol indexes aren't copied, as
+// the offsets in them may no longer be right, other than for FormatDarwin
+// output, which has its '__.SYMDEF SORTED' index regenerated from the symbols
+// of each member.
+func (a *ARFS) Rewrite(w io.Writer, opts ...RewriteOption) error {
+	var o rewriteOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	idx := a.snapshot()
+	names, err := idx.rewriteNames(&o)
+	if err != nil {
+		return err
+	}
+	replaced, added := idx.rewriteReplacements(&o, names)
+
+	format := idx.format
+	if format == FormatUnknown {
+		format = FormatBSD
+	}
+	aw := NewWriter(w, append([]WriterOption{WithFormat(format)}, o.writer...)...)
+	for _, fh := range idx.visible() {
+		name, ok := names[fh]
+		if !ok {
+			continue
+		}
+		// Members are written with the names stored in the archive, not the
+		// names given by WithNameNormalizer or DuplicateIndexed
+		hdr := fh.header()
+		hdr.Name = fh.rawName
+		if name != fh.name {
+			hdr.Name = name
+		}
+		hdr.RawName = hdr.Name
+		if rep, ok := replaced[fh]; ok {
+			if err := rep.write(aw, hdr); err != nil {
+				return &fs.PathError{Op: "replace", Path: name, Err: err}
+			}
+			continue
+		}
+		if err := aw.WriteHeader(&hdr); err != nil {
+			return &fs.PathError{Op: "rewrite", Path: fh.name, Err: err}
+		}
+		if symbols := idx.symbolIndex().byMember[fh]; aw.format == FormatDarwin && len(symbols) > 0 {
+			if err := aw.AddSymbols(symbols...); err != nil {
+				return err
+			}
+		}
+		if _, err := fh.copyTo(idx.opts.context(), aw); err != nil {
+			return &fs.PathError{Op: "rewrite", Path: fh.name, Err: err}
+		}
+	}
+	for _, rep := range added {
+		hdr := Header{Name: rep.name, RawName: rep.name, Mode: modeRegular | 0o644, ModTime: time.Now()}
+		if err := rep.write(aw, hdr); err != nil {
+			return &fs.PathError{Op: "replace", Path: rep.name, Err: err}
+		}
+	}
+	return aw.Close()
+}
+
+// rewriteReplacements works out which members are replaced by Rewrite, and the
+// replacements which are added to the end as there is no member to replace
+func (idx *index) rewriteReplacements(o *rewriteOptions, names map[*fileHeader]string) (map[*fileHeader]*replacement, []*replacement) {
+	replaced := map[*fileHeader]*replacement{}
+	var added []*replacement
+	for _, rep := range o.replacements {
+		key := idx.opts.key(rep.name)
+		// Find the member with the name, as the duplicate policy would
+		var target *fileHeader
+		for _, fh := range idx.visible() {
+			if name, ok := names[fh]; ok && idx.opts.key(name) == key {
+				target = fh
+				if idx.opts.duplicates == DuplicateFirst {
+					break
+				}
+			}
+		}
+		if target != nil {
+			replaced[target] = rep
+			continue
+		}
+		if i := slices.IndexFunc(added, func(a *replacement) bool { return idx.opts.key(a.name) == key }); i >= 0 {
+			added[i] = rep
+		} else {
+			added = append(added, rep)
+		}
+	}
+	return replaced, added
+}
+
+// write writes the replacement as a member, with the name of hdr, and the
+// rest of its metadata unless the replacement has its own
+func (rep *replacement) write(aw *Writer, hdr Header) error {
+	if rep.hdr != nil {
+		hdr.ModTime = rep.hdr.ModTime
+		hdr.Mode = rep.hdr.Mode
+		hdr.UID = rep.hdr.UID
+		hdr.GID = rep.hdr.GID
+	}
+	if rep.size < 0 {
+		// WriteFrom measures or buffers r when the size isn't given
+		hdr.Size = 0
+		_, err := aw.WriteFrom(&hdr, rep.r)
+		return err
+	}
+	hdr.Size = rep.size
+	if err := aw.WriteHeader(&hdr); err != nil {
+		return err
+	}
+	n, err := aw.ReadFrom(rep.r)
+	if err != nil {
+		return err
+	}
+	if n < rep.size {
+		return fmt.Errorf("%w: %d bytes missing", ErrShortMember, rep.size-n)
+	}
+	return nil
+}
+
+// rewriteNames works out the name each member is written with by Rewrite,
+// leaving out those which are removed
+func (idx *index) rewriteNames(o *rewriteOptions) (map[*fileHeader]string, error) {
+	members := idx.visible()
+	names := make(map[*fileHeader]string, len(members))
+	for _, fh := range members {
+		names[fh] = fh.name
+	}
+	// matching finds the remaining members for which match returns true
+	matching := func(match func(fh *fileHeader) bool) []*fileHeader {
+		var found []*fileHeader
+		for _, fh := range members {
+			if _, ok := names[fh]; ok && match(fh) {
+				found = append(found, fh)
+			}
+		}
+		return found
+	}
+
+	for _, name := range o.remove {
+		found := matching(func(fh *fileHeader) bool {
+			return idx.opts.key(fh.name) == idx.opts.key(name)
+		})
+		if len(found) == 0 && !o.ignoreMissing {
+			return nil, &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+		}
+		for _, fh := range found {
+			delete(names, fh)
+		}
+	}
+	for _, pattern := range o.removeGlobs {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, err
+		}
+		found := matching(func(fh *fileHeader) bool {
+			match, _ := path.Match(pattern, fh.name)
+			return match
+		})
+		if len(found) == 0 && !o.ignoreMissing {
+			return nil, &fs.PathError{Op: "remove", Path: pattern, Err: fs.ErrNotExist}
+		}
+		for _, fh := range found {
+			delete(names, fh)
+		}
+	}
+	for _, r := range o.renames {
+		found := matching(func(fh *fileHeader) bool {
+			return idx.opts.key(names[fh]) == idx.opts.key(r.oldName)
+		})
+		if len(found) == 0 && !o.ignoreMissing {
+			return nil, &os.LinkError{Op: "rename", Old: r.oldName, New: r.newName, Err: fs.ErrNotExist}
+		}
+		if len(found) > 0 && idx.opts.duplicates == DuplicateError {
+			taken := matching(func(fh *fileHeader) bool {
+				return idx.opts.key(names[fh]) == idx.opts.key(r.newName)
+			})
+			if len(taken) > 0 && idx.opts.key(r.oldName) != idx.opts.key(r.newName) {
+				return nil, &os.LinkError{Op: "rename", Old: r.oldName, New: r.newName, Err: ErrDuplicate}
+			}
+		}
+		for _, fh := range found {
+			names[fh] = r.newName
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,293 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRewriteRoundTrip(t *testing.T) {
+	tests := []struct {
+		filename string
+		// identical is set for archives which should be reproduced exactly
+		identical bool
+		opts      []Option
+	}{
+		{"testdata/test1.ar", true, nil},
+		{"testdata/duplicates.a", true, nil},
+		// The names stored in the archive are written, not the mapped ones
+		{"testdata/duplicates.a", true, []Option{WithDuplicates(DuplicateIndexed)}},
+		{"testdata/test1.ar", true, []Option{WithNameNormalizer(func(raw string) (string, bool) {
+			return strings.ToUpper(raw), true
+		})}},
+		{"testdata/gnu_roundtrip.a", true, nil},
+		{"testdata/nopad_final.ar", false, nil},
+		{"testdata/gnu.a", false, nil},
+		{"testdata/darwin.a", false, nil},
+		{"testdata/extended.ar", false, nil},
+		{"testdata/spaces.a", false, nil},
+		{"testdata/sym64.a", false, nil},
+		{"testdata/imports.lib", false, nil},
+		{"testdata/blank_fields.a", false, nil},
+	}
+	for _, test := range tests {
+		filename := test.filename
+		raw, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ar, err := FromInterface(bytes.NewReader(raw), test.opts...)
+		if err != nil {
+			t.Fatalf("%s: %s", filename, err)
+		}
+		var buf bytes.Buffer
+		n, err := ar.WriteTo(&buf)
+		if err != nil {
+			t.Fatalf("%s: %s", filename, err)
+		}
+		if n != int64(buf.Len()) {
+			t.Errorf("%s: WriteTo reported %d bytes, but wrote %d", filename, n, buf.Len())
+		}
+		if test.identical && !bytes.Equal(buf.Bytes(), raw) {
+			t.Errorf("%s: should be reproduced byte for byte:\n%q\n%q", filename, buf.Bytes(), raw)
+		}
+		again, err := FromInterface(bytes.NewReader(buf.Bytes()), test.opts...)
+		if err != nil {
+			t.Fatalf("%s: cannot parse rewritten archive: %s", filename, err)
+		}
+		if got, expected := again.List(), ar.List(); !sameHeaders(got, expected) {
+			t.Errorf("%s: rewritten archive has different members:\n%+v\n%+v", filename, got, expected)
+		}
+		for _, h := range ar.List() {
+			a, _ := ar.ReadFile(h.Name)
+			b, _ := again.ReadFile(h.Name)
+			if !bytes.Equal(a, b) {
+				t.Errorf("%s: %s has different contents", filename, h.Name)
+			}
+		}
+		if again.Format() == FormatDarwin && !maps.Equal(again.Symbols(), ar.Symbols()) {
+			t.Errorf("%s: symbols should be regenerated: %v", filename, again.Symbols())
+		}
+	}
+}
+
+// sameHeaders reports whether a & b describe the same members, other than
+// their offsets
+func sameHeaders(a, b []Header) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Size != b[i].Size || a[i].Mode != b[i].Mode ||
+			a[i].UID != b[i].UID || a[i].GID != b[i].GID || !a[i].ModTime.Equal(b[i].ModTime) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRewriteFormat(t *testing.T) {
+	// Round trip archives made by Writer through the other format
+	names := []string{"short.o", "an_odd_sized_long_name.o", "x", "another_long_name.txt"}
+	for _, format := range []Format{FormatBSD, FormatGNU} {
+		var original bytes.Buffer
+		w := NewWriter(&original, WithFormat(format))
+		for _, name := range names {
+			if err := w.WriteHeader(&Header{Name: name, Size: int64(len(name)), Mode: 0o100644}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.WriteString(w, name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		ar, err := FromInterface(bytes.NewReader(original.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var same bytes.Buffer
+		if _, err := ar.WriteTo(&same); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(same.Bytes(), original.Bytes()) {
+			t.Errorf("%s: should be reproduced byte for byte", format)
+		}
+
+		other := FormatGNU
+		if format == FormatGNU {
+			other = FormatBSD
+		}
+		var converted bytes.Buffer
+		if err := ar.Rewrite(&converted, WithWriterOptions(WithFormat(other))); err != nil {
+			t.Fatal(err)
+		}
+		again, err := FromInterface(bytes.NewReader(converted.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.Format() != other || !sameHeaders(again.List(), ar.List()) {
+			t.Errorf("%s: converting to %s gave a %s archive with %+v", format, other, again.Format(), again.List())
+		}
+	}
+}
+
+func TestRewriteTruncated(t *testing.T) {
+	ar, err := FromFile(filepath.Join("testdata", "truncated.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	if _, err := ar.WriteTo(io.Discard); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("truncated member should fail with ErrUnexpectedEOF: %v", err)
+	}
+}
+
+func TestRewriteRemoveRename(t *testing.T) {
+	members := []testMember{
+		{name: "a.o", data: "first a"},
+		{name: "b.o", data: "b"},
+		{name: "c.txt", data: "c"},
+		{name: "d.txt", data: "d"},
+		{name: "e.o", data: "e"},
+		{name: "a.o", data: "second a"},
+	}
+	raw := buildArchive(t, members...)
+	ar, err := FromInterface(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []Format{FormatBSD, FormatGNU} {
+		var buf bytes.Buffer
+		err := ar.Rewrite(&buf, Remove("b.o"), RemoveGlob("*.txt"), Rename("a.o", "a_much_longer_member_name.o"),
+			WithWriterOptions(WithFormat(format)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, err := FromInterface(bytes.NewReader(buf.Bytes()), WithDuplicates(DuplicateFirst))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, h := range again.List() {
+			data, err := io.ReadAll(io.NewSectionReader(bytes.NewReader(buf.Bytes()), h.Offset, h.Size))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, h.Name+"="+string(data))
+		}
+		expected := "a_much_longer_member_name.o=first a e.o=e a_much_longer_member_name.o=second a"
+		if strings.Join(got, " ") != expected {
+			t.Errorf("%s: rewritten archive holds %v", format, got)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := ar.Rewrite(&buf, Remove("e.o", "missing.o")); !errors.Is(err, fs.ErrNotExist) || buf.Len() > 0 {
+		t.Errorf("removing a missing member should fail with ErrNotExist before writing: %v", err)
+	}
+	if err := ar.Rewrite(&buf, Rename("missing.o", "new.o")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("renaming a missing member should fail with ErrNotExist: %v", err)
+	}
+	if err := ar.Rewrite(&buf, RemoveGlob("*.so")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("a glob matching nothing should fail with ErrNotExist: %v", err)
+	}
+	if err := ar.Rewrite(&buf, RemoveGlob("[")); !errors.Is(err, path.ErrBadPattern) {
+		t.Errorf("bad pattern should fail with ErrBadPattern: %v", err)
+	}
+	if err := ar.Rewrite(io.Discard, Remove("missing.o"), RemoveGlob("*.so"), Rename("missing.o", "new.o"), IgnoreMissing()); err != nil {
+		t.Errorf("IgnoreMissing should allow missing members: %v", err)
+	}
+
+	// Renaming onto an existing name follows the duplicate policy
+	unique, err := FromInterface(bytes.NewReader(buildArchive(t, members[1:5]...)), WithDuplicates(DuplicateError))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unique.Rewrite(io.Discard, Rename("b.o", "e.o")); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("renaming onto an existing member should fail with ErrDuplicate: %v", err)
+	}
+	if err := unique.Rewrite(io.Discard, Remove("e.o"), Rename("b.o", "e.o")); err != nil {
+		t.Errorf("renaming onto a removed member should be allowed: %v", err)
+	}
+	buf.Reset()
+	if err := ar.Rewrite(&buf, Rename("b.o", "e.o")); err != nil {
+		t.Fatal(err)
+	}
+	again, err := FromInterface(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := again.ReadFile("e.o"); err != nil || string(data) != "e" {
+		t.Errorf("both members should be kept, with the last found by name: %q %v", data, err)
+	}
+}
+
+func TestRewriteReplace(t *testing.T) {
+	ar, err := FromInterface(bytes.NewReader(buildArchive(t,
+		testMember{name: "a.txt", data: "aaaa", modtime: 100},
+		testMember{name: "b.txt", data: "bb", modtime: 100},
+		testMember{name: "c.txt", data: "ccccc", modtime: 100},
+		testMember{name: "d.txt", data: "dd", modtime: 100},
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Unix(1700000000, 0)
+	for _, format := range []Format{FormatBSD, FormatGNU} {
+		var buf bytes.Buffer
+		err := ar.Rewrite(&buf,
+			// Odd sized, replacing even sized
+			Replace("a.txt", strings.NewReader("AAA"), 3, nil),
+			// Larger
+			Replace("b.txt", strings.NewReader("BBBBBBBB"), 8, &Header{ModTime: mtime, Mode: 0o100600}),
+			// Smaller, of unknown size, and not seekable
+			Replace("c.txt", io.MultiReader(strings.NewReader("C")), -1, nil),
+			// Not in the archive, so added to the end
+			Replace("a_new_long_member_name.txt", strings.NewReader("new"), -1, nil),
+			WithWriterOptions(WithFormat(format)),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Strict parsing checks that the padding is right
+		again, err := FromInterface(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		var got []string
+		for _, h := range again.List() {
+			data, err := again.ReadFile(h.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, h.Name+"="+string(data))
+		}
+		expected := "a.txt=AAA b.txt=BBBBBBBB c.txt=C d.txt=dd a_new_long_member_name.txt=new"
+		if strings.Join(got, " ") != expected {
+			t.Errorf("%s: rewritten archive holds %v", format, got)
+		}
+		if info, err := again.Stat("a.txt"); err != nil || info.ModTime().Unix() != 100 {
+			t.Errorf("%s: metadata should be kept without a header: %v", format, err)
+		}
+		if info, err := again.Stat("b.txt"); err != nil || !info.ModTime().Equal(mtime) || info.Mode() != 0o100600 {
+			t.Errorf("%s: metadata should come from the header: %v %v", format, info.ModTime(), info.Mode())
+		}
+	}
+
+	if err := ar.Rewrite(io.Discard, Replace("a.txt", strings.NewReader("short"), 10, nil)); !errors.Is(err, ErrShortMember) {
+		t.Errorf("short replacement should fail with ErrShortMember: %v", err)
+	}
+	if err := ar.Rewrite(io.Discard, Replace("a.txt", strings.NewReader("too long"), 3, nil)); !errors.Is(err, ErrWriteTooLong) {
+		t.Errorf("long replacement should fail with ErrWriteTooLong: %v", err)
+	}
+}
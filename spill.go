@@ -0,0 +1,61 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+)
+
+// defaultSpillThreshold is the largest archive FromReader keeps in memory when
+// WithTempDir is used without WithSpillThreshold
+const defaultSpillThreshold = 32 << 20
+
+// tempFile is an archive spilled to disk by FromReader, which is removed when
+// it is closed
+type tempFile struct {
+	*os.File
+}
+
+func (t *tempFile) Close() error {
+	err := t.File.Close()
+	if rerr := os.Remove(t.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// buffer reads all of r so that it can be accessed at random, holding it in
+// memory unless it is larger than the spill threshold
+func (o *options) buffer(r io.Reader) (io.ReadSeeker, error) {
+	if !o.spill {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(data), nil
+	}
+
+	threshold := o.spillThreshold
+	if threshold <= 0 {
+		threshold = defaultSpillThreshold
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, threshold+1); err != nil {
+		if errors.Is(err, io.EOF) {
+			return bytes.NewReader(buf.Bytes()), nil
+		}
+		return nil, err
+	}
+
+	f, err := os.CreateTemp(o.tempDir, "goarfs-*.a")
+	if err != nil {
+		return nil, err
+	}
+	spilled := &tempFile{File: f}
+	if _, err := io.Copy(f, io.MultiReader(&buf, r)); err != nil {
+		spilled.Close()
+		return nil, err
+	}
+	return spilled, nil
+}
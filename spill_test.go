@@ -0,0 +1,68 @@
+package goarfs
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSpillThreshold(t *testing.T) {
+	raw := buildArchive(t,
+		testMember{name: "small.txt", data: "hello"},
+		testMember{name: "big.dat", data: strings.Repeat("x", 1000)},
+	)
+
+	for _, test := range []struct {
+		threshold int64
+		spilled   bool
+	}{
+		{int64(len(raw)), false},
+		{100, true},
+	} {
+		dir := t.TempDir()
+		// Hide the Seek method, so that the archive has to be buffered
+		r := io.MultiReader(bytes.NewReader(raw))
+		ar, err := FromReader(r, WithTempDir(dir), WithSpillThreshold(test.threshold))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ar.ReadFile("big.dat")
+		if err != nil || len(data) != 1000 {
+			t.Errorf("threshold %d: bad contents: %d bytes, %v", test.threshold, len(data), err)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if spilled := len(entries) == 1; spilled != test.spilled {
+			t.Errorf("threshold %d: expected spilled %v, have %v", test.threshold, test.spilled, entries)
+		}
+		if err := ar.Close(); err != nil {
+			t.Fatal(err)
+		}
+		entries, err = os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("threshold %d: temporary file should be removed on close: %v", test.threshold, entries)
+		}
+	}
+}
+
+func TestSpillParseError(t *testing.T) {
+	dir := t.TempDir()
+	r := io.MultiReader(strings.NewReader(strings.Repeat("not an archive", 20)))
+	if _, err := FromReader(r, WithTempDir(dir), WithSpillThreshold(10)); err == nil {
+		t.Fatal("invalid archive should fail to parse")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("temporary file should be removed when parsing fails: %v", entries)
+	}
+}
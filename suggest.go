@@ -0,0 +1,138 @@
+package goarfs
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxSuggestions is the most close matches given by a NotFoundError
+	maxSuggestions = 3
+	// suggestionScanLimit is the most members compared against a missing name,
+	// so that very large archives don't make every failed lookup expensive
+	suggestionScanLimit = 4096
+	// minSuggestionPrefix is the shortest name which is suggested as the start
+	// of a longer one, such as "config" for "config.yaml"
+	minSuggestionPrefix = 3
+)
+
+// NotFoundError is the error inside the fs.PathError returned by Open, Stat and
+// ReadFile for a missing member when using WithSuggestions. It wraps
+// fs.ErrNotExist, and lists the names of up to three members which are close to
+// the one asked for, closest first.
+type NotFoundError struct {
+	Name        string
+	Suggestions []string
+}
+
+func (e *NotFoundError) Error() string {
+	if len(e.Suggestions) == 0 {
+		return fs.ErrNotExist.Error()
+	}
+	quoted := make([]string, len(e.Suggestions))
+	for i, s := range e.Suggestions {
+		quoted[i] = fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%s (did you mean %s?)", fs.ErrNotExist, strings.Join(quoted, ", "))
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return fs.ErrNotExist
+}
+
+// notFound returns the error for a missing member, which is a bare
+// fs.ErrNotExist unless suggestions are enabled
+func (idx *index) notFound(op, name string) error {
+	if !idx.opts.suggestions {
+		return fs.ErrNotExist
+	}
+	return &fs.PathError{Op: op, Path: name, Err: &NotFoundError{Name: name, Suggestions: idx.suggest(name)}}
+}
+
+// suggest returns the names of the members closest to name. A member is close
+// if it is within a small edit distance of name, or if one of them starts with
+// the other.
+func (idx *index) suggest(name string) []string {
+	type candidate struct {
+		name  string
+		score int
+	}
+	key := idx.opts.key(name)
+	limit := max(1, len(key)/3)
+	var candidates []candidate
+	seen := map[string]bool{}
+	scanned := 0
+	for _, fh := range idx.members {
+		if fh.special || seen[fh.name] {
+			continue
+		}
+		if scanned++; scanned > suggestionScanLimit {
+			break
+		}
+		seen[fh.name] = true
+		other := idx.opts.key(fh.name)
+		// Scores are doubled so that prefixes can rank between an exact
+		// match and a single edit
+		switch {
+		case min(len(key), len(other)) >= minSuggestionPrefix &&
+			(strings.HasPrefix(other, key) || strings.HasPrefix(key, other)):
+			candidates = append(candidates, candidate{fh.name, 1})
+		case abs(len(key)-len(other)) <= limit:
+			if d := editDistance(key, other, limit); d <= limit {
+				candidates = append(candidates, candidate{fh.name, 2 * d})
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score < candidates[j].score
+		}
+		return candidates[i].name < candidates[j].name
+	})
+	var suggestions []string
+	for _, c := range candidates[:min(len(candidates), maxSuggestions)] {
+		suggestions = append(suggestions, c.name)
+	}
+	return suggestions
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// editDistance returns the Levenshtein distance between a and b, counting an
+// adjacent transposition as a single edit. Once every path exceeds limit it
+// gives up and returns limit+1.
+func editDistance(a, b string, limit int) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		best := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			best = min(best, cur[j])
+		}
+		if best > limit {
+			return limit + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,98 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSuggestions(t *testing.T) {
+	ar, err := FromInterface(bytes.NewReader(buildArchive(t,
+		testMember{name: "config.yaml", data: "a"},
+		testMember{name: "config.json", data: "b"},
+		testMember{name: "main.o", data: "c"},
+		testMember{name: "unrelated.txt", data: "d"},
+	)), WithSuggestions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	for _, test := range []struct {
+		name     string
+		expected []string
+	}{
+		{"conifg.yaml", []string{"config.yaml"}},
+		{"config", []string{"config.json", "config.yaml"}},
+		{"mian.o", []string{"main.o"}},
+		{"nothing-like-it", nil},
+	} {
+		_, err := ar.Open(test.name)
+		var notFound *NotFoundError
+		if !errors.Is(err, fs.ErrNotExist) || !errors.As(err, &notFound) {
+			t.Fatalf("%s: expected a NotFoundError, got %v", test.name, err)
+		}
+		if !reflect.DeepEqual(notFound.Suggestions, test.expected) {
+			t.Errorf("%s: expected suggestions %v, got %v", test.name, test.expected, notFound.Suggestions)
+		}
+	}
+
+	_, err = ar.ReadFile("mian.o")
+	if err == nil || !strings.Contains(err.Error(), `did you mean "main.o"?`) {
+		t.Errorf("suggestions should be in the message: %v", err)
+	}
+	if _, err := ar.Stat("mian.o"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("stat should fail with ErrNotExist: %v", err)
+	}
+}
+
+func TestSuggestionsSpecial(t *testing.T) {
+	ar, err := FromFile("testdata/gnu.a", WithSuggestions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	var notFound *NotFoundError
+	if _, err := ar.Stat("///"); !errors.As(err, &notFound) {
+		t.Fatalf("expected a NotFoundError, got %v", err)
+	}
+	for _, s := range notFound.Suggestions {
+		if isSpecial(s) {
+			t.Errorf("special member %q should not be suggested", s)
+		}
+	}
+}
+
+func TestSuggestionsDisabled(t *testing.T) {
+	ar, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	var notFound *NotFoundError
+	if _, err := ar.Open("test1.dta"); errors.As(err, &notFound) {
+		t.Errorf("suggestions should only be made with WithSuggestions: %v", err)
+	}
+}
+
+func BenchmarkSuggestions(b *testing.B) {
+	var members []testMember
+	for i := 0; i < 20000; i++ {
+		members = append(members, testMember{name: fmt.Sprintf("member%05d.o", i), data: "x"})
+	}
+	ar, err := FromInterface(bytes.NewReader(buildArchive(b, members...)), WithSuggestions())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer ar.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ar.Stat("membr12345.o"); err == nil {
+			b.Fatal("missing member found")
+		}
+	}
+}
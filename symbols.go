@@ -0,0 +1,244 @@
+package goarfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+var (
+	ErrBadSymbolTable = errors.New("bad AR symbol table")
+)
+
+// symbol is a single entry from an archive symbol index. offset is the
+// position of the header of the member which defines the symbol.
+type symbol struct {
+	name   string
+	offset int64
+}
+
+// decodeSymbolTable decodes the contents of a symbol index member. GNU ('/'
+// and '/SYM64/') and BSD ('__.SYMDEF' and friends) formats are supported.
+func decodeSymbolTable(name string, data []byte) ([]symbol, error) {
+	switch name {
+	case "/":
+		return decodeGNUSymbols(data, 4)
+	case "/SYM64/":
+		return decodeGNUSymbols(data, 8)
+	case "__.SYMDEF", "__.SYMDEF SORTED":
+		return decodeBSDSymbols(data, 4)
+	case "__.SYMDEF_64", "__.SYMDEF_64 SORTED":
+		return decodeBSDSymbols(data, 8)
+	}
+	return nil, nil
+}
+
+// readWord reads a width byte unsigned value from the start of data
+func readWord(order binary.ByteOrder, data []byte, width int) uint64 {
+	if width == 8 {
+		return order.Uint64(data)
+	}
+	return uint64(order.Uint32(data))
+}
+
+// decodeGNUSymbols decodes the System V/GNU format, which is a big-endian count
+// of symbols, followed by that many offsets and then the NUL terminated
+// symbol names
+func decodeGNUSymbols(data []byte, width int) ([]symbol, error) {
+	if len(data) < width {
+		return nil, ErrBadSymbolTable
+	}
+	count := readWord(binary.BigEndian, data, width)
+	data = data[width:]
+	if count > uint64(len(data)/width) {
+		return nil, ErrBadSymbolTable
+	}
+	names := data[int(count)*width:]
+	symbols := make([]symbol, count)
+	for i := range symbols {
+		symbols[i].offset = int64(readWord(binary.BigEndian, data[i*width:], width))
+		end := bytes.IndexByte(names, 0)
+		if end < 0 {
+			return nil, ErrBadSymbolTable
+		}
+		symbols[i].name = string(names[:end])
+		names = names[end+1:]
+	}
+	return symbols, nil
+}
+
+// decodeBSDSymbols decodes the BSD ranlib format, which is the size in bytes of
+// an array of (string index, offset) pairs, followed by the size of the string
+// table and then the table itself. Most archives are little-endian, but older
+// ones were written in the byte order of the host, so try both.
+func decodeBSDSymbols(data []byte, width int) ([]symbol, error) {
+	symbols, err := decodeBSDSymbolsOrder(binary.LittleEndian, data, width)
+	if err != nil {
+		return decodeBSDSymbolsOrder(binary.BigEndian, data, width)
+	}
+	return symbols, nil
+}
+
+func decodeBSDSymbolsOrder(order binary.ByteOrder, data []byte, width int) ([]symbol, error) {
+	if len(data) < width {
+		return nil, ErrBadSymbolTable
+	}
+	ranlibSize := readWord(order, data, width)
+	data = data[width:]
+	if len(data) < width || ranlibSize%uint64(2*width) != 0 || ranlibSize > uint64(len(data)-width) {
+		return nil, ErrBadSymbolTable
+	}
+	ranlibs := data[:ranlibSize]
+	data = data[ranlibSize:]
+	stringsSize := readWord(order, data, width)
+	data = data[width:]
+	if stringsSize > uint64(len(data)) {
+		return nil, ErrBadSymbolTable
+	}
+	stringTable := data[:stringsSize]
+
+	symbols := make([]symbol, len(ranlibs)/(2*width))
+	for i := range symbols {
+		strx := readWord(order, ranlibs[i*2*width:], width)
+		symbols[i].offset = int64(readWord(order, ranlibs[i*2*width+width:], width))
+		if strx >= uint64(len(stringTable)) {
+			return nil, ErrBadSymbolTable
+		}
+		name := stringTable[strx:]
+		if end := bytes.IndexByte(name, 0); end >= 0 {
+			name = name[:end]
+		}
+		symbols[i].name = string(name)
+	}
+	return symbols, nil
+}
+
+// symbolIndex maps between symbols and the members which define them
+type symbolIndex struct {
+	definitions map[string]*fileHeader
+	byMember    map[*fileHeader][]string
+}
+
+// symbolIndex returns the archive symbol index, loading it on first use. If
+// the archive has no symbol index (or it can't be decoded) it will be empty.
+func (idx *index) symbolIndex() *symbolIndex {
+	idx.symbolsOnce.Do(func() {
+		idx.symbols = idx.loadSymbols()
+	})
+	return idx.symbols
+}
+
+func (idx *index) loadSymbols() *symbolIndex {
+	si := &symbolIndex{
+		definitions: map[string]*fileHeader{},
+		byMember:    map[*fileHeader][]string{},
+	}
+	for _, fh := range idx.members {
+		if !fh.special || fh.name == "//" {
+			continue
+		}
+		data, err := io.ReadAll(fh.reader())
+		if err != nil {
+			return si
+		}
+		symbols, err := decodeSymbolTable(fh.name, data)
+		if err != nil {
+			return si
+		}
+
+		// Symbols refer to members by the offset of their header, which
+		// works even if names are duplicated
+		headers := map[int64]*fileHeader{}
+		for _, m := range idx.members {
+			headers[m.headerOffset] = m
+		}
+		for _, s := range symbols {
+			member, ok := headers[idx.base+s.offset]
+			if !ok {
+				continue
+			}
+			if _, ok := si.definitions[s.name]; !ok {
+				si.definitions[s.name] = member
+			}
+			si.byMember[member] = append(si.byMember[member], s.name)
+		}
+		// Only the first symbol index is used (Windows import libraries
+		// have a second one in a different format)
+		return si
+	}
+	return si
+}
+
+// LookupSymbol finds the name of the member which defines sym, according to
+// the archive symbol index. ok is false if the symbol isn't defined, or if the
+// archive has no symbol index.
+func (a *ARFS) LookupSymbol(sym string) (memberName string, ok bool) {
+	fh, ok := a.snapshot().symbolIndex().definitions[sym]
+	if !ok {
+		return "", false
+	}
+	return fh.name, true
+}
+
+// Symbols returns every symbol in the archive symbol index, mapped to the name
+// of the member which defines it. It is empty if the archive has no symbol
+// index. Both 32 and 64 bit GNU indexes are supported, along with BSD
+// __.SYMDEF indexes.
+func (a *ARFS) Symbols() map[string]string {
+	definitions := a.snapshot().symbolIndex().definitions
+	symbols := make(map[string]string, len(definitions))
+	for sym, fh := range definitions {
+		symbols[sym] = fh.name
+	}
+	return symbols
+}
+
+// SymbolsOf returns the symbols which the symbol index lists as being defined
+// by the named member, in the order they appear in the index
+func (a *ARFS) SymbolsOf(member string) []string {
+	fh, ok := a.getHeader(member)
+	if !ok {
+		return nil
+	}
+	return a.snapshot().symbolIndex().byMember[fh]
+}
+
+// SymbolsSorted reports whether the archive symbol index is declared as being
+// sorted by symbol name, as the '__.SYMDEF SORTED' index written by the Darwin
+// tools is. It is false if the archive has no symbol index.
+func (a *ARFS) SymbolsSorted() bool {
+	fh, ok := a.snapshot().symbolMember()
+	return ok && strings.HasSuffix(fh.name, " SORTED")
+}
+
+// symbolMember returns the first symbol index in the archive
+func (idx *index) symbolMember() (*fileHeader, bool) {
+	for _, fh := range idx.members {
+		if fh.special && fh.name != "//" {
+			return fh, true
+		}
+	}
+	return nil, false
+}
+
+// IndexRaw returns the undecoded contents of the archive symbol index, such as
+// the '/' member of a GNU archive or '__.SYMDEF' of a BSD one. If there is
+// more than one, as in Windows import libraries, the first is returned. An
+// error wrapping fs.ErrNotExist is returned if the archive has no symbol index.
+// The symbol index & long filename table can also be opened by name, such as
+// Open("/") or Open("//").
+func (a *ARFS) IndexRaw() ([]byte, error) {
+	fh, ok := a.snapshot().symbolMember()
+	if !ok {
+		return nil, fmt.Errorf("%w: archive has no symbol index", fs.ErrNotExist)
+	}
+	data := make([]byte, fh.Size())
+	if err := fh.readInto(data); err != nil {
+		return nil, &fs.PathError{Op: "read", Path: fh.name, Err: err}
+	}
+	return data, nil
+}
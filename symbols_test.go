@@ -0,0 +1,135 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestLookupSymbol(t *testing.T) {
+	ar, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	for symbol, member := range map[string]string{
+		"short_func":  "short.o",
+		"long_func":   "a_very_long_object_file_name.o",
+		"shared_data": "a_very_long_object_file_name.o",
+	} {
+		found, ok := ar.LookupSymbol(symbol)
+		if !ok || found != member {
+			t.Errorf("%s should be defined in %s, got %q/%v", symbol, member, found, ok)
+		}
+	}
+	if _, ok := ar.LookupSymbol("SSL_read"); ok {
+		t.Errorf("undefined symbol should not be found")
+	}
+
+	symbols := strings.Join(ar.SymbolsOf("a_very_long_object_file_name.o"), " ")
+	if symbols != "long_func shared_data" {
+		t.Errorf("wrong symbols for a_very_long_object_file_name.o: %q", symbols)
+	}
+	if symbols := ar.SymbolsOf("notes_with_a_long_name.txt"); len(symbols) != 0 {
+		t.Errorf("text file should define no symbols: %v", symbols)
+	}
+}
+
+func TestLookupSymbolNoIndex(t *testing.T) {
+	ar, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	if _, ok := ar.LookupSymbol("anything"); ok {
+		t.Fatalf("archive without an index should not find symbols")
+	}
+	if symbols := ar.SymbolsOf("test1.dat"); len(symbols) != 0 {
+		t.Fatalf("archive without an index should have no symbols: %v", symbols)
+	}
+}
+
+func TestSymbols64(t *testing.T) {
+	ar, err := FromFile("testdata/sym64.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	symbols := ar.Symbols()
+	if len(symbols) != 3 || symbols["alpha_init"] != "alpha.o" || symbols["alpha_run"] != "alpha.o" || symbols["beta_main"] != "beta.o" {
+		t.Fatalf("bad 64 bit symbol table: %v", symbols)
+	}
+	names, err := ar.Names("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, " ") != "alpha.o beta.o" {
+		t.Fatalf("/SYM64/ should not be listed: %v", names)
+	}
+	entries, err := ar.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("/SYM64/ should not be in ReadDir: %d entries", len(entries))
+	}
+	if err := ar.Verify(); err != nil {
+		t.Fatalf("64 bit symbol table should verify: %s", err)
+	}
+}
+
+func TestSpecialMembersByName(t *testing.T) {
+	ar, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	entries, err := ar.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if isSpecial(e.Name()) {
+			t.Errorf("ReadDir should not list %q", e.Name())
+		}
+	}
+
+	index, err := ar.IndexRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	symbols, err := ar.ReadFile("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(index, symbols) || len(index) != 50 {
+		t.Errorf("symbol index should be readable by name: %d vs %d bytes", len(symbols), len(index))
+	}
+	names, err := ar.ReadFile("//")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(names, []byte("a_very_long_object_file_name.o/")) {
+		t.Errorf("long filename table should be readable by name: %q", names)
+	}
+	if info, err := ar.Stat("//"); err != nil || info.IsDir() || info.Size() != 60 {
+		t.Errorf("long filename table should be a file: %v %v", info, err)
+	}
+
+	plain, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	if _, err := plain.IndexRaw(); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("archive without a symbol index should fail with ErrNotExist: %v", err)
+	}
+	if info, err := plain.Stat("/"); err != nil || !info.IsDir() {
+		t.Errorf("without a symbol index '/' should be the root: %v %v", info, err)
+	}
+}
@@ -0,0 +1,295 @@
+package goarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// ConvertOption configures the conversion of an archive by ToTar, or of a tar
+// stream by FromTar
+type ConvertOption func(*convertOptions)
+
+type convertOptions struct {
+	prefix         string
+	specialMembers bool
+	zeroTimes      bool
+	symlinks       LinkPolicy
+	hardlinks      LinkPolicy
+	writer         []WriterOption
+}
+
+// LinkPolicy controls what FromTar does with links, which ar can't represent
+type LinkPolicy int
+
+const (
+	// LinkSkip leaves the link out of the archive
+	LinkSkip LinkPolicy = iota
+	// LinkFail returns an error wrapping ErrNotRegular
+	LinkFail
+	// LinkCopy stores a copy of the contents of the link target, which must
+	// be an earlier file in the tar stream
+	LinkCopy
+)
+
+// WithTarPrefix puts every entry of the tar stream inside the directory prefix,
+// so that with a prefix of "lib" the member "foo.o" becomes "lib/foo.o". For
+// FromTar it does the reverse, converting only the files within prefix.
+func WithTarPrefix(prefix string) ConvertOption {
+	return func(o *convertOptions) {
+		o.prefix = prefix
+	}
+}
+
+// WithTarSpecialMembers includes the symbol index & long filename table in the
+// tar stream, which are excluded by default. Their names are escaped with
+// url.PathEscape, as tar can't hold names such as "/" and "//", so these
+// become "%2F" and "%2F%2F".
+func WithTarSpecialMembers() ConvertOption {
+	return func(o *convertOptions) {
+		o.specialMembers = true
+	}
+}
+
+// WithTarZeroTimes sets the modification time of every tar entry (or member,
+// for FromTar) to the Unix epoch, for reproducible output
+func WithTarZeroTimes() ConvertOption {
+	return func(o *convertOptions) {
+		o.zeroTimes = true
+	}
+}
+
+// WithSymlinks sets the policy for symbolic links in FromTar. The default is
+// LinkSkip.
+func WithSymlinks(policy LinkPolicy) ConvertOption {
+	return func(o *convertOptions) {
+		o.symlinks = policy
+	}
+}
+
+// WithHardlinks sets the policy for hard links in FromTar. The default is
+// LinkCopy.
+func WithHardlinks(policy LinkPolicy) ConvertOption {
+	return func(o *convertOptions) {
+		o.hardlinks = policy
+	}
+}
+
+// WithArchiveOptions configures the Writer used by FromTar, such as to choose
+// the format
+func WithArchiveOptions(opts ...WriterOption) ConvertOption {
+	return func(o *convertOptions) {
+		o.writer = append(o.writer, opts...)
+	}
+}
+
+// ToTar converts the archive to a tar stream written to w, with each member as
+// a regular file keeping its name, size, mode, modification time and owner, in
+// archive order. Where names are duplicated only the member found by that name
+// is included. Member data is streamed straight from the archive, so nothing
+// is held in memory.
+func (a *ARFS) ToTar(w io.Writer, opts ...ConvertOption) error {
+	o := convertOptions{hardlinks: LinkCopy}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	idx := a.snapshot()
+	tw := tar.NewWriter(w)
+	for _, fh := range idx.members {
+		if fh.special {
+			if !o.specialMembers {
+				continue
+			}
+		} else if idx.fileHeaders[idx.opts.key(fh.name)] != fh {
+			continue
+		}
+		name := fh.name
+		if fh.special {
+			name = url.PathEscape(name)
+		}
+		if o.prefix != "" {
+			name = o.prefix + "/" + name
+		}
+		modTime := fh.ModTime()
+		if o.zeroTimes {
+			modTime = time.Unix(0, 0)
+		}
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Size:     fh.Size(),
+			Mode:     int64(fh.Mode().Perm()),
+			ModTime:  modTime,
+			Uid:      int(fh.owner),
+			Gid:      int(fh.group),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return &fs.PathError{Op: "tar", Path: fh.name, Err: err}
+		}
+		if _, err := fh.copyTo(idx.opts.context(), tw); err != nil {
+			return &fs.PathError{Op: "tar", Path: fh.name, Err: err}
+		}
+	}
+	return tw.Close()
+}
+
+// WriteTar is the same as ToTar with no options
+func (a *ARFS) WriteTar(w io.Writer) error {
+	return a.ToTar(w)
+}
+
+// WriteTarGz is the same as WriteTar, but the tar stream is compressed with
+// gzip at the given level, such as gzip.BestCompression
+func (a *ARFS) WriteTarGz(w io.Writer, level int) error {
+	zw, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return err
+	}
+	if err := a.WriteTar(zw); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// FromTar converts the tar stream src to an archive written to dst, with each
+// regular file in the tar as a member keeping its name, size, mode,
+// modification time and owner. Directories, devices & FIFOs are left out,
+// while links are handled as set by WithSymlinks and WithHardlinks. Pax
+// headers are used for the entries they describe, but not stored. With
+// WithTarPrefix, only the files within the prefix directory are converted,
+// with the prefix removed from their names.
+//
+// File contents are streamed through to dst. Links copied with LinkCopy are
+// read back from the Writer, which holds every member until Close for
+// FormatGNU and FormatDarwin, or from dst if it is an io.ReaderAt (such as an
+// *os.File). Otherwise, the contents of every file are kept in memory so that
+// they can be copied.
+func FromTar(dst io.Writer, src io.Reader, opts ...ConvertOption) error {
+	o := convertOptions{hardlinks: LinkCopy}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	copyLinks := o.symlinks == LinkCopy || o.hardlinks == LinkCopy
+	files := map[string]*tarFile{}
+
+	cw := &countingWriter{w: dst}
+	tr := tar.NewReader(src)
+	aw := NewWriter(cw, o.writer...)
+	for {
+		th, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name, ok := o.fromTarName(th.Name)
+		if !ok {
+			continue
+		}
+		hdr := &Header{
+			Name:    name,
+			Size:    th.Size,
+			Mode:    modeRegular | uint32(th.Mode&0o7777),
+			ModTime: th.ModTime,
+			UID:     th.Uid,
+			GID:     th.Gid,
+		}
+		if o.zeroTimes {
+			hdr.ModTime = time.Unix(0, 0)
+		}
+
+		var contents io.Reader = tr
+		switch th.Typeflag {
+		case tar.TypeReg, tar.TypeGNUSparse:
+		case tar.TypeSymlink, tar.TypeLink:
+			policy, target := o.hardlinks, path.Clean(th.Linkname)
+			if th.Typeflag == tar.TypeSymlink && !path.IsAbs(th.Linkname) {
+				policy, target = o.symlinks, path.Join(path.Dir(path.Clean(th.Name)), th.Linkname)
+			} else if th.Typeflag == tar.TypeSymlink {
+				policy = o.symlinks
+			}
+			switch policy {
+			case LinkSkip:
+				continue
+			case LinkFail:
+				return &fs.PathError{Op: "fromtar", Path: th.Name, Err: ErrNotRegular}
+			}
+			f, ok := files[target]
+			if !ok {
+				return &fs.PathError{Op: "fromtar", Path: th.Name, Err: fmt.Errorf("link target %s: %w", th.Linkname, fs.ErrNotExist)}
+			}
+			// The copy takes the contents & mode of the target
+			hdr.Size = f.size
+			hdr.Mode = f.mode
+			contents = f.reader(dst)
+		default:
+			continue
+		}
+
+		if err := aw.WriteHeader(hdr); err != nil {
+			return &fs.PathError{Op: "fromtar", Path: th.Name, Err: err}
+		}
+		var f *tarFile
+		if copyLinks {
+			f = &tarFile{size: hdr.Size, mode: hdr.Mode, offset: cw.n}
+			if len(aw.pending) > 0 {
+				f.pending = aw.pending[len(aw.pending)-1]
+			} else if _, ok := dst.(io.ReaderAt); !ok {
+				f.data = &bytes.Buffer{}
+				contents = io.TeeReader(contents, f.data)
+			}
+		}
+		if _, err := aw.ReadFrom(contents); err != nil {
+			return &fs.PathError{Op: "fromtar", Path: th.Name, Err: err}
+		}
+		if f != nil {
+			files[path.Clean(th.Name)] = f
+		}
+	}
+	return aw.Close()
+}
+
+// tarFile records where to find the contents of a member written by FromTar,
+// for links copied with LinkCopy
+type tarFile struct {
+	size int64
+	mode uint32
+	// pending is the member when the Writer holds it until Close
+	pending *pendingMember
+	// offset is the position of the contents in dst, if it is an io.ReaderAt
+	offset int64
+	// data is a copy of the contents when neither of those can be read back
+	data *bytes.Buffer
+}
+
+// reader returns the contents of the member
+func (f *tarFile) reader(dst io.Writer) io.Reader {
+	switch {
+	case f.pending != nil:
+		// The data is followed by any padding once the member is finished
+		return io.NewSectionReader(f.pending.data(), 0, f.size)
+	case f.data != nil:
+		return bytes.NewReader(f.data.Bytes())
+	}
+	ra, _ := dst.(io.ReaderAt)
+	return io.NewSectionReader(ra, f.offset, f.size)
+}
+
+// fromTarName converts the name of a tar entry to the name of a member, which
+// is false if it is outside the WithTarPrefix directory
+func (o *convertOptions) fromTarName(name string) (string, bool) {
+	name = strings.TrimPrefix(path.Clean(name), "/")
+	if o.prefix == "" {
+		return name, true
+	}
+	return strings.CutPrefix(name, o.prefix+"/")
+}
@@ -0,0 +1,263 @@
+package goarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteTarGz(t *testing.T) {
+	ar, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	var buf bytes.Buffer
+	if err := ar.WriteTarGz(&buf, gzip.BestCompression); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(zr)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := ar.ReadFile(hdr.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, expected) {
+			t.Errorf("%s has the wrong contents in the tar", hdr.Name)
+		}
+		if hdr.Mode != 0o644 || hdr.Typeflag != tar.TypeReg {
+			t.Errorf("%s has the wrong mode %o or type %c", hdr.Name, hdr.Mode, hdr.Typeflag)
+		}
+	}
+	if len(names) != 3 || names[0] != "short.o" {
+		t.Errorf("tar should hold the files in archive order: %v", names)
+	}
+	if err := zr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ar.WriteTarGz(io.Discard, 42); err == nil {
+		t.Errorf("an invalid compression level should fail")
+	}
+}
+
+// readTar returns the headers & contents of every entry in a tar stream
+func readTar(t *testing.T, data []byte) ([]*tar.Header, []string) {
+	t.Helper()
+	tr := tar.NewReader(bytes.NewReader(data))
+	var headers []*tar.Header
+	var contents []string
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return headers, contents
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		headers = append(headers, hdr)
+		contents = append(contents, string(body))
+	}
+}
+
+func TestToTar(t *testing.T) {
+	ar, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	var buf bytes.Buffer
+	if err := ar.ToTar(&buf, WithTarPrefix("pkg/lib")); err != nil {
+		t.Fatal(err)
+	}
+	headers, contents := readTar(t, buf.Bytes())
+	members := ar.List()
+	if len(headers) != len(members) {
+		t.Fatalf("tar should have an entry per member: %d vs %d", len(headers), len(members))
+	}
+	for i, m := range members {
+		h := headers[i]
+		expected, err := ar.ReadFile(m.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Name != "pkg/lib/"+m.Name || h.Size != m.Size || contents[i] != string(expected) {
+			t.Errorf("%s: tar entry %q has size %d and contents %q", m.Name, h.Name, h.Size, contents[i])
+		}
+		if h.Mode != int64(m.Mode&0o777) || !h.ModTime.Equal(m.ModTime) || h.Uid != m.UID || h.Gid != m.GID {
+			t.Errorf("%s: tar entry has mode %o, time %s and owner %d/%d", m.Name, h.Mode, h.ModTime, h.Uid, h.Gid)
+		}
+	}
+
+	if tarTool, err := exec.LookPath("tar"); err == nil {
+		cmd := exec.Command(tarTool, "-tf", "-")
+		cmd.Stdin = bytes.NewReader(buf.Bytes())
+		out, err := cmd.Output()
+		if err != nil || string(out) != "pkg/lib/test1.dat\npkg/lib/test2.dat\n" {
+			t.Errorf("tar cannot list the stream: %q %v", out, err)
+		}
+	}
+
+	gnu, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gnu.Close()
+	buf.Reset()
+	if err := gnu.ToTar(&buf, WithTarSpecialMembers(), WithTarZeroTimes()); err != nil {
+		t.Fatal(err)
+	}
+	headers, _ = readTar(t, buf.Bytes())
+	var names []string
+	for _, h := range headers {
+		names = append(names, h.Name)
+		if h.ModTime.Unix() != 0 {
+			t.Errorf("%s should have a zero timestamp: %s", h.Name, h.ModTime)
+		}
+	}
+	if strings.Join(names, " ") != "%2F %2F%2F short.o a_very_long_object_file_name.o notes_with_a_long_name.txt" {
+		t.Errorf("special members should be included in archive order: %v", names)
+	}
+}
+
+func TestFromTar(t *testing.T) {
+	// Written by GNU tar in pax format, with a hard link & symlink to
+	// top.txt, and a path too long for a plain tar header
+	raw, err := os.ReadFile("testdata/gnutar.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	long := "a_directory_with_a_fairly_long_name/and_another_level_that_is_also_rather_long/with_a_long_file_name_at_the_bottom.txt"
+	for _, test := range []struct {
+		opts     []ConvertOption
+		expected []string
+	}{
+		{nil, []string{"top.txt", "hard.txt", long}},
+		{[]ConvertOption{WithSymlinks(LinkCopy), WithHardlinks(LinkSkip)}, []string{"top.txt", "link.txt", long}},
+		{[]ConvertOption{WithTarPrefix("a_directory_with_a_fairly_long_name"), WithArchiveOptions(WithFormat(FormatBSD))}, []string{long[36:]}},
+	} {
+		var buf bytes.Buffer
+		if err := FromTar(&buf, bytes.NewReader(raw), test.opts...); err != nil {
+			t.Fatal(err)
+		}
+		ar, err := FromInterface(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, h := range ar.List() {
+			names = append(names, h.Name)
+			if h.Mode != modeRegular|0o644 || h.ModTime.Unix() != 1700000000 {
+				t.Errorf("%s has the wrong mode %o or time %s", h.Name, h.Mode, h.ModTime)
+			}
+		}
+		if strings.Join(names, " ") != strings.Join(test.expected, " ") {
+			t.Errorf("members should be %v, not %v", test.expected, names)
+		}
+		for _, name := range names {
+			data, err := ar.ReadFile(name)
+			expected := "top file\n"
+			if strings.HasSuffix(name, "bottom.txt") {
+				expected = "deep\n"
+			}
+			if err != nil || string(data) != expected {
+				t.Errorf("%s reads back as %q: %v", name, data, err)
+			}
+		}
+	}
+
+	// Copied links are read back from the Writer for GNU archives, and from
+	// the output for BSD ones if it can be read, or else kept in memory
+	out, err := os.Create(filepath.Join(t.TempDir(), "links.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	var gnuBuf, bsdBuf bytes.Buffer
+	for _, test := range []struct {
+		format Format
+		dst    io.Writer
+		output func() []byte
+	}{
+		{FormatGNU, &gnuBuf, gnuBuf.Bytes},
+		{FormatBSD, &bsdBuf, bsdBuf.Bytes},
+		{FormatBSD, out, func() []byte {
+			data, err := os.ReadFile(out.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return data
+		}},
+	} {
+		opts := []ConvertOption{WithSymlinks(LinkCopy), WithArchiveOptions(WithFormat(test.format))}
+		if err := FromTar(test.dst, bytes.NewReader(raw), opts...); err != nil {
+			t.Fatal(err)
+		}
+		ar, err := FromInterface(bytes.NewReader(test.output()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"top.txt", "hard.txt", "link.txt"} {
+			if data, err := ar.ReadFile(name); err != nil || string(data) != "top file\n" {
+				t.Errorf("%s: %s should be a copy of top.txt: %q %v", test.format, name, data, err)
+			}
+		}
+	}
+
+	for _, opt := range []ConvertOption{WithSymlinks(LinkFail), WithHardlinks(LinkFail)} {
+		if err := FromTar(io.Discard, bytes.NewReader(raw), opt); !errors.Is(err, ErrNotRegular) {
+			t.Errorf("links should fail with ErrNotRegular: %v", err)
+		}
+	}
+
+	// Converting back to a tar keeps the files
+	ar, err := FromFile("testdata/test1.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	var tarred, converted bytes.Buffer
+	if err := ar.ToTar(&tarred); err != nil {
+		t.Fatal(err)
+	}
+	if err := FromTar(&converted, &tarred, WithArchiveOptions(WithFormat(FormatBSD))); err != nil {
+		t.Fatal(err)
+	}
+	back, err := FromInterface(bytes.NewReader(converted.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameHeaders(ar.List(), back.List()) {
+		t.Errorf("headers should survive a round trip through tar:\n%v\n%v", ar.List(), back.List())
+	}
+}
@@ -0,0 +1,144 @@
+package goarfs
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// unionFS overlays several archives, with later layers taking priority
+type unionFS struct {
+	layers []*ARFS
+}
+
+var _ fs.ReadDirFS = (*unionFS)(nil)
+var _ fs.ReadFileFS = (*unionFS)(nil)
+var _ fs.StatFS = (*unionFS)(nil)
+var _ fs.GlobFS = (*unionFS)(nil)
+
+// Union combines several archives into a single fs.FS, such as a base archive
+// followed by patch archives. When more than one archive has a member with the
+// same name, the one from the last archive wins. The archives still belong to
+// the caller, who is responsible for closing them once the union is no longer
+// needed.
+func Union(layers ...*ARFS) fs.FS {
+	return &unionFS{layers: layers}
+}
+
+// lookup finds the highest priority member with the given name, along with
+// the snapshot of the layer which has it
+func (u *unionFS) lookup(name string) (*index, *fileHeader, bool) {
+	for i := len(u.layers) - 1; i >= 0; i-- {
+		idx := u.layers[i].snapshot()
+		if !idx.validPath(name) || isRoot(name) {
+			continue
+		}
+		if fh, ok := idx.lookup(name); ok && !fh.special {
+			return idx, fh, true
+		}
+	}
+	return nil, nil, false
+}
+
+// dirInfo describes a directory of the union, which has the modification
+// time of the highest priority layer
+func (u *unionFS) dirInfo(name string) dirInfo {
+	if len(u.layers) == 0 {
+		return dirInfo{name: path.Base(cleanName(name))}
+	}
+	return u.layers[len(u.layers)-1].snapshot().dirInfo(path.Base(cleanName(name)))
+}
+
+func (u *unionFS) Open(name string) (fs.File, error) {
+	clean, mustBeDir := trimDirSlash(name)
+	if idx, fh, ok := u.lookup(clean); ok {
+		if mustBeDir {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: ErrNotDir}
+		}
+		f := fh.open()
+		f.info = idx.memberInfo(fh)
+		return idx.track(f), nil
+	}
+	entries, err := u.ReadDir(clean)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &dirFile{info: u.dirInfo(clean), entries: entries}, nil
+}
+
+func (u *unionFS) Stat(name string) (fs.FileInfo, error) {
+	clean, mustBeDir := trimDirSlash(name)
+	if idx, fh, ok := u.lookup(clean); ok {
+		if mustBeDir {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: ErrNotDir}
+		}
+		return idx.memberInfo(fh), nil
+	}
+	if _, err := u.ReadDir(clean); err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return u.dirInfo(clean), nil
+}
+
+func (u *unionFS) ReadFile(name string) ([]byte, error) {
+	clean, mustBeDir := trimDirSlash(name)
+	idx, fh, ok := u.lookup(clean)
+	switch {
+	case !ok:
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	case mustBeDir:
+		return nil, &fs.PathError{Op: "read", Path: name, Err: ErrNotDir}
+	}
+	return idx.readFile(name, fh)
+}
+
+// ReadDir returns the merged contents of the layers which have the directory,
+// sorted by name. The root always exists, even with no layers.
+func (u *unionFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	merged := map[string]fs.DirEntry{}
+	found := isRoot(name)
+	for _, layer := range u.layers {
+		entries, err := layer.ReadDir(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		for _, entry := range entries {
+			merged[entry.Name()] = entry
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	ret := make([]fs.DirEntry, 0, len(merged))
+	for _, entry := range merged {
+		ret = append(ret, entry)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name() < ret[j].Name()
+	})
+	return ret, nil
+}
+
+func (u *unionFS) Glob(pattern string) ([]string, error) {
+	merged := map[string]bool{}
+	for _, layer := range u.layers {
+		names, err := layer.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			merged[name] = true
+		}
+	}
+	ret := make([]string, 0, len(merged))
+	for name := range merged {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
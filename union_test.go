@@ -0,0 +1,129 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestUnion(t *testing.T) {
+	layer := func(members ...testMember) *ARFS {
+		t.Helper()
+		ar, err := FromInterface(bytes.NewReader(buildArchive(t, members...)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ar
+	}
+	base := layer(
+		testMember{name: "config.json", data: "base"},
+		testMember{name: "base.txt", data: "only in base"},
+	)
+	empty := layer()
+	patch1 := layer(testMember{name: "config.json", data: "patch1", modtime: 100})
+	patch2 := layer(
+		testMember{name: "config.json", data: "patch2!", modtime: 200},
+		testMember{name: "new.txt", data: "added"},
+	)
+	u := Union(base, empty, patch1, patch2)
+
+	for name, expected := range map[string]string{
+		"config.json": "patch2!",
+		"base.txt":    "only in base",
+		"new.txt":     "added",
+	} {
+		data, err := fs.ReadFile(u, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != expected {
+			t.Errorf("%s: expected %q, got %q", name, expected, data)
+		}
+	}
+	info, err := fs.Stat(u, "config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 7 || info.ModTime().Unix() != 200 {
+		t.Errorf("stat should come from the winning layer: %d %s", info.Size(), info.ModTime())
+	}
+	if _, err := fs.Stat(u, "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing file should fail with ErrNotExist: %v", err)
+	}
+
+	entries, err := fs.ReadDir(u, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if strings.Join(names, " ") != "base.txt config.json new.txt" {
+		t.Fatalf("bad merged listing: %v", names)
+	}
+	if info, err := entries[1].Info(); err != nil || info.Size() != 7 {
+		t.Fatalf("listing should show the winning layer: %v %v", info, err)
+	}
+
+	matches, err := fs.Glob(u, "*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(matches, " ") != "base.txt new.txt" {
+		t.Fatalf("bad merged glob: %v", matches)
+	}
+
+	// Only the empty layer
+	entries, err = fs.ReadDir(Union(empty), ".")
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("empty union should have no entries: %v %v", entries, err)
+	}
+}
+
+func TestUnionDirs(t *testing.T) {
+	base, err := FromInterface(bytes.NewReader(buildArchive(t,
+		testMember{name: "top.txt", data: "top"},
+		testMember{name: "sub/a.txt", data: "a"},
+	)), WithVirtualDirs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch, err := FromInterface(bytes.NewReader(buildArchive(t,
+		testMember{name: "other/b.txt", data: "b"},
+	)), WithVirtualDirs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := Union(base, patch)
+
+	// Each directory is only in one of the layers
+	var walked []string
+	err = fs.WalkDir(u, ".", func(name string, d fs.DirEntry, err error) error {
+		walked = append(walked, name)
+		return err
+	})
+	if err != nil || strings.Join(walked, " ") != ". other other/b.txt sub sub/a.txt top.txt" {
+		t.Fatalf("walking the union should merge the layers: %v %v", walked, err)
+	}
+	if err := fstest.TestFS(u, "top.txt", "sub/a.txt", "other/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.ReadDir(u, "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("directory in none of the layers should fail with ErrNotExist: %v", err)
+	}
+
+	truncated, err := FromFile("testdata/truncated.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer truncated.Close()
+	data, err := fs.ReadFile(Union(base, truncated), "cutoff.txt")
+	if !errors.Is(err, io.ErrUnexpectedEOF) || len(data) != 100 {
+		t.Fatalf("reading a truncated member should fail with ErrUnexpectedEOF: %d %v", len(data), err)
+	}
+}
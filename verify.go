@@ -0,0 +1,134 @@
+package goarfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	ErrOverlap = errors.New("overlapping AR members")
+)
+
+// Verify walks the entire structure of the archive, checking that it is
+// internally consistent: the signature and every header are valid, member data
+// and padding lie within the file, long filenames can all be resolved, the
+// symbol index only refers to real members and no members overlap. Member
+// contents are not read. Rather than stopping at the first problem, all of
+// them are returned combined with errors.Join.
+func (a *ARFS) Verify() error {
+	return a.snapshot().verify(context.Background())
+}
+
+// VerifyContext is the same as Verify, but is abandoned if ctx is cancelled,
+// returning an error wrapping ctx.Err() which says how many members had been
+// checked
+func (a *ARFS) VerifyContext(ctx context.Context) error {
+	return a.snapshot().verify(ctx)
+}
+
+func (idx *index) verify(ctx context.Context) error {
+	var problems []error
+	problem := func(offset int64, err error) {
+		problems = append(problems, fmt.Errorf("offset %d: %w", offset, err))
+	}
+
+	base, err := idx.findSignature()
+	if errors.Is(err, ErrBadSignature) {
+		problem(0, err)
+	} else if err != nil {
+		return errors.Join(ErrTooShort, err)
+	}
+
+	var stringTable []byte
+	var symbols []symbol
+	symbolTable := ""
+	headers := map[int64]bool{}
+
+	pos := base + int64(len(goodSignature))
+	for pos < idx.size {
+		if ctx.Err() != nil {
+			return cancelled(ctx, len(headers), len(idx.members))
+		}
+		var header [headerSize]byte
+		if err := idx.readFull(header[:], pos); err != nil {
+			problem(pos, errors.Join(ErrTooShort, err))
+			break
+		}
+		h, err := decodeHeader(header[:])
+		if err != nil {
+			// We have no idea where the next header is, so give up
+			problem(pos, err)
+			break
+		}
+		headers[pos] = true
+		offset := pos + headerSize
+		dataEnd := offset + h.size
+		if dataEnd > idx.size {
+			problem(pos, fmt.Errorf("%w: %q data ends at %d, beyond end of archive at %d", ErrTooShort, h.name, dataEnd, idx.size))
+			break
+		}
+		if h.size&1 != 0 && dataEnd+1 > idx.size {
+			problem(pos, fmt.Errorf("%w: %q", ErrMissingPadding, h.name))
+		}
+
+		// Only the tables themselves are loaded, never member contents
+		switch {
+		case h.name == "//":
+			stringTable = make([]byte, h.size)
+			if err := idx.readFull(stringTable, offset); err != nil {
+				problem(pos, errors.Join(ErrBadStringTable, err))
+			}
+		case symbolTable == "" && (h.name == "/" || h.name == "/SYM64/"):
+			symbolTable = h.name
+			symbols = idx.verifySymbols(h.name, offset, h.size, func(err error) { problem(pos, err) })
+		}
+
+		name, nameLength, err := idx.memberName(h, offset, stringTable)
+		if err != nil {
+			problem(pos, err)
+		} else if symbolTable == "" && nameLength > 0 && isSpecial(name) {
+			symbolTable = name
+			symbols = idx.verifySymbols(name, offset+nameLength, h.size-nameLength, func(err error) { problem(pos, err) })
+		}
+
+		pos = dataEnd + h.size&1
+	}
+
+	for _, s := range symbols {
+		// Symbol offsets are relative to the signature
+		if !headers[base+s.offset] {
+			problems = append(problems, fmt.Errorf("%w: symbol %q refers to offset %d, which is not a member", ErrBadSymbolTable, s.name, s.offset))
+		}
+	}
+
+	// Check the parsed index as well, in case it has come from somewhere
+	// other than a straight walk of the archive
+	members := append([]*fileHeader(nil), idx.members...)
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].headerOffset < members[j].headerOffset
+	})
+	for i := 1; i < len(members); i++ {
+		prev, next := members[i-1], members[i]
+		if prev.offset+prev.span > next.headerOffset {
+			problems = append(problems, fmt.Errorf("%w: %q and %q", ErrOverlap, prev.name, next.name))
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// verifySymbols loads & decodes a symbol table, reporting any problems
+func (idx *index) verifySymbols(name string, offset, size int64, problem func(error)) []symbol {
+	data := make([]byte, size)
+	if err := idx.readFull(data, offset); err != nil {
+		problem(errors.Join(ErrBadSymbolTable, err))
+		return nil
+	}
+	symbols, err := decodeSymbolTable(name, data)
+	if err != nil {
+		problem(err)
+	}
+	return symbols
+}
@@ -0,0 +1,66 @@
+package goarfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	for _, filename := range []string{"testdata/test1.ar", "testdata/extended.ar", "testdata/gnu.a"} {
+		ar, err := FromFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ar.Verify(); err != nil {
+			t.Errorf("%s should verify cleanly: %s", filename, err)
+		}
+		ar.Close()
+	}
+
+	ar, err := FromFile("testdata/nopad_final.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	if err := ar.Verify(); !errors.Is(err, ErrMissingPadding) {
+		t.Fatalf("missing final padding should fail verification: %v", err)
+	}
+}
+
+func TestVerifyCorrupt(t *testing.T) {
+	raw, err := os.ReadFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar, err := FromInterface(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Point the first symbol somewhere that isn't a member header, and
+	// break the long name reference of the last member. Both problems
+	// should be reported
+	corrupt := bytes.Clone(raw)
+	symbolOffsets := len(goodSignature) + headerSize + 4
+	binary.BigEndian.PutUint32(corrupt[symbolOffsets:], 12345)
+	members := ar.snapshot().members
+	last := members[len(members)-1]
+	copy(corrupt[last.headerOffset:], "/999")
+
+	ar, err = FromInterface(bytes.NewReader(corrupt))
+	if err == nil {
+		t.Fatalf("bad long name reference should fail parsing")
+	}
+	// Verification doesn't rely on the parse succeeding
+	idx := &index{rawFile: &arfsReader{ReadSeeker: bytes.NewReader(corrupt)}, size: int64(len(corrupt))}
+	err = idx.verify(context.Background())
+	if !errors.Is(err, ErrBadSymbolTable) {
+		t.Errorf("bad symbol offset not detected: %v", err)
+	}
+	if !errors.Is(err, ErrBadStringTable) {
+		t.Errorf("bad long name not detected: %v", err)
+	}
+}
@@ -0,0 +1,782 @@
+package goarfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrWriteTooLong      = errors.New("AR write exceeds member size")
+	ErrShortMember       = errors.New("AR member shorter than its declared size")
+	ErrWriteAfterClose   = errors.New("AR write after close")
+	ErrFieldOverflow     = errors.New("AR header field out of range")
+	ErrBadName           = errors.New("invalid AR member name")
+	ErrNotRegular        = errors.New("not a regular file")
+	ErrNoSymbolIndex     = errors.New("AR format has no symbol index")
+	ErrAppendUnsupported = errors.New("AR archive can't be appended to")
+)
+
+// Writer creates AR archives. Each member is started with WriteHeader,
+// followed by exactly Header.Size bytes of data via Write. Close must be called
+// to finish the archive, but does not close the underlying io.Writer.
+//
+// BSD format archives are streamed straight to the output. GNU format
+// archives need their long filename table to come before any of the members,
+// and Darwin format archives (or any with WithIndex) their symbol index, so
+// they are buffered until Close: in memory, or in a temporary file with
+// WithIndex.
+type Writer struct {
+	w             io.Writer
+	format        Format
+	deterministic bool
+	padding       NumericPadding
+	// index is set to write a symbol index, for WithIndex
+	index bool
+
+	// started is set once the signature has been written
+	started bool
+	// remaining is the number of bytes still to be written for the member
+	remaining int64
+	// pad is set if the current member needs a padding byte at the end
+	pad    bool
+	closed bool
+	// appending is set when adding to an existing archive, so that every
+	// member is streamed straight after the existing ones
+	appending bool
+	// closer is closed by Close, for a file opened by OpenAppend
+	closer io.Closer
+
+	// pending holds the GNU & Darwin format members until Close, with their
+	// data in spool
+	pending []*pendingMember
+	spool   spool
+}
+
+// spool holds the data of the pending members, one after another
+type spool interface {
+	io.Writer
+	io.ReaderAt
+}
+
+// memSpool is a spool held in memory
+type memSpool struct {
+	data []byte
+}
+
+func (m *memSpool) Write(p []byte) (int, error) {
+	m.data = append(m.data, p...)
+	return len(p), nil
+}
+
+func (m *memSpool) ReadAt(p []byte, off int64) (int, error) {
+	return bytes.NewReader(m.data).ReadAt(p, off)
+}
+
+// pendingMember is a member which is buffered until Close
+type pendingMember struct {
+	hdr     Header
+	symbols []string
+	// spool holds the data of the member, which is length bytes long
+	// (including any padding) starting at offset
+	spool  spool
+	offset int64
+	length int64
+}
+
+func (m *pendingMember) Write(p []byte) (int, error) {
+	n, err := m.spool.Write(p)
+	m.length += int64(n)
+	return n, err
+}
+
+// data returns a reader over everything written for the member
+func (m *pendingMember) data() *io.SectionReader {
+	return io.NewSectionReader(m.spool, m.offset, m.length)
+}
+
+// WriterOption configures a Writer
+type WriterOption func(*Writer)
+
+// WithFormat selects the archive format to write. The default is FormatBSD.
+// FormatGNU stores long names in a '//' table, which has to come first, so the
+// members are held in memory until Close rather than streamed.
+func WithFormat(format Format) WriterOption {
+	return func(w *Writer) {
+		w.format = format
+	}
+}
+
+// Deterministic zeroes the modification time, owner & group of every member,
+// and normalises the mode to 0644, whatever the Header says, so that the
+// archive contents depend only on the member names, data & the order they were
+// written in. The headers are byte for byte the same as those written by
+// 'ar rD'. AddFS already walks in sorted order, so archives made by Create are
+// reproducible.
+func Deterministic() WriterOption {
+	return func(w *Writer) {
+		w.deterministic = true
+	}
+}
+
+// WithIndex writes a symbol index at the start of the archive, as 'ar s' does,
+// so that linkers can find which member defines each symbol. On Close, the
+// external symbols defined by each ELF, Mach-O or COFF object file are found
+// (unless given with AddSymbols), while other members define none. GNU format
+// archives get a '/' index, while BSD format archives are written as
+// FormatDarwin, with a '__.SYMDEF SORTED' index. Every member is buffered in a
+// temporary file until Close, which removes it. It has no effect on AppendTo.
+func WithIndex() WriterOption {
+	return func(w *Writer) {
+		w.index = true
+	}
+}
+
+// WithSymbolTable is the same as WithIndex, named after the symbol table which
+// GNU ar writes with 'ar s'.
+func WithSymbolTable() WriterOption {
+	return WithIndex()
+}
+
+// NumericPadding selects how the numeric header fields are padded out to their
+// full width
+type NumericPadding int
+
+const (
+	// PaddingSpace left aligns the numbers, followed by spaces, as GNU ar does
+	PaddingSpace NumericPadding = iota
+	// PaddingZero right aligns the numbers, preceded by zeros
+	PaddingZero
+)
+
+// WithNumericPadding selects how the modification time, owner, group & size
+// header fields are padded. The default is PaddingSpace. The mode is always
+// space padded. The choice has no effect on reading the archive.
+func WithNumericPadding(style NumericPadding) WriterOption {
+	return func(w *Writer) {
+		w.padding = style
+	}
+}
+
+// NewWriter creates a Writer which writes an archive to w
+func NewWriter(w io.Writer, opts ...WriterOption) *Writer {
+	aw := &Writer{w: w, format: FormatBSD}
+	for _, o := range opts {
+		o(aw)
+	}
+	if aw.index && aw.format == FormatBSD {
+		aw.format = FormatDarwin
+	}
+	return aw
+}
+
+// formatHeader encodes the fixed size header for a member. By default the
+// layout matches GNU ar byte for byte: every field is left aligned and space
+// padded, with the mode in octal, so re-writing a GNU archive reproduces it
+// exactly.
+func (w *Writer) formatHeader(name string, modTime int64, uid, gid int, mode uint32, size int64) ([]byte, error) {
+	if modTime < 0 || uid < 0 || gid < 0 || size < 0 {
+		return nil, ErrFieldOverflow
+	}
+	layout := "%-16s%-12d%-6d%-6d%-8o%-10d`\n"
+	if w.padding == PaddingZero {
+		layout = "%-16s%012d%06d%06d%-8o%010d`\n"
+	}
+	header := fmt.Sprintf(layout, name, modTime, uid, gid, mode, size)
+	// Anything which didn't fit in its field will have pushed the length out
+	if len(header) != headerSize {
+		return nil, ErrFieldOverflow
+	}
+	return []byte(header), nil
+}
+
+// unixTime converts a modification time for the header, treating the zero
+// time as the epoch
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// needsLongName reports whether name can't be stored directly in the 16 byte
+// name field of the header
+func (w *Writer) needsLongName(name string) bool {
+	if w.format == FormatGNU {
+		// Leave room for the '/' terminator, which binutils takes to be the
+		// first '/' in the field, so any name holding one goes in the table
+		return len(name) > 15 || strings.Contains(name, "/") || strings.TrimSpace(name) != name
+	}
+	return len(name) > 16 || strings.Contains(name, " ") || strings.HasPrefix(name, "#1/") || strings.HasPrefix(name, "/")
+}
+
+// bsdNameLength returns the size of the NUL padded name stored at the start of
+// the member data for a BSD long filename
+func bsdNameLength(name string) int64 {
+	return int64(len(name)+4) &^ 3
+}
+
+func (w *Writer) writeSignature() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	_, err := w.w.Write(goodSignature)
+	return err
+}
+
+// finishMember checks that the current member is complete, and writes any
+// padding it requires
+func (w *Writer) finishMember() error {
+	if w.remaining > 0 {
+		return fmt.Errorf("%w: %d bytes missing", ErrShortMember, w.remaining)
+	}
+	if w.pad {
+		w.pad = false
+		if _, err := w.memberWriter().Write([]byte{'\n'}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// memberWriter is where member data should currently be written
+func (w *Writer) memberWriter() io.Writer {
+	if len(w.pending) > 0 {
+		return w.pending[len(w.pending)-1]
+	}
+	return w.w
+}
+
+// addPending starts a member which is buffered until Close, following the
+// data of the previous one in the spool
+func (w *Writer) addPending(h Header) error {
+	if w.spool == nil {
+		w.spool = &memSpool{}
+		if w.index {
+			f, err := os.CreateTemp("", "goarfs-*")
+			if err != nil {
+				return err
+			}
+			w.spool = &tempFile{File: f}
+		}
+	}
+	m := &pendingMember{hdr: h, spool: w.spool}
+	if len(w.pending) > 0 {
+		last := w.pending[len(w.pending)-1]
+		m.offset = last.offset + last.length
+	}
+	w.pending = append(w.pending, m)
+	return nil
+}
+
+// closeSpool removes the temporary file holding the pending members, if any
+func (w *Writer) closeSpool() error {
+	var err error
+	if c, ok := w.spool.(io.Closer); ok {
+		err = c.Close()
+	}
+	w.spool = nil
+	return err
+}
+
+// WriteHeader starts a new member. The previous member must have had all of
+// its data written.
+func (w *Writer) WriteHeader(hdr *Header) error {
+	if w.closed {
+		return ErrWriteAfterClose
+	}
+	if err := w.finishMember(); err != nil {
+		return err
+	}
+	if hdr.Name == "" || strings.ContainsAny(hdr.Name, "\n\x00") {
+		return fmt.Errorf("%w: %q", ErrBadName, hdr.Name)
+	}
+	h := *hdr
+	if w.deterministic {
+		h.ModTime = time.Unix(0, 0)
+		h.UID = 0
+		h.GID = 0
+		h.Mode = 0o644
+	}
+
+	if (w.format == FormatGNU || w.format == FormatDarwin) && !w.appending {
+		// Check the header can be encoded now, rather than failing on Close
+		if _, err := w.formatHeader("", unixTime(h.ModTime), h.UID, h.GID, h.Mode, h.Size); err != nil {
+			return err
+		}
+		if err := w.addPending(h); err != nil {
+			return err
+		}
+		w.remaining = h.Size
+		w.pad = h.Size%2 != 0
+		return nil
+	}
+
+	var header, extended []byte
+	var err error
+	if w.format == FormatGNU {
+		header, err = w.gnuAppendHeader(h)
+	} else {
+		header, extended, err = w.bsdHeader(h)
+	}
+	if err != nil {
+		return err
+	}
+	if err := w.writeSignature(); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(extended); err != nil {
+		return err
+	}
+	w.remaining = h.Size
+	w.pad = (int64(len(extended))+h.Size)%2 != 0
+	return nil
+}
+
+// bsdHeader encodes the header of a BSD format member, along with the
+// extended filename which follows it if the name doesn't fit in the header
+func (w *Writer) bsdHeader(h Header) (header, extended []byte, err error) {
+	name := h.Name
+	size := h.Size
+	if w.needsLongName(h.Name) {
+		nameLength := bsdNameLength(h.Name)
+		extended = make([]byte, nameLength)
+		copy(extended, h.Name)
+		name = fmt.Sprintf("#1/%d", nameLength)
+		size += nameLength
+	}
+	header, err = w.formatHeader(name, unixTime(h.ModTime), h.UID, h.GID, h.Mode, size)
+	return header, extended, err
+}
+
+// AddSymbols records that the current member defines the given symbols, for the
+// '__.SYMDEF SORTED' symbol index written at the start of FormatDarwin
+// archives, or the index written by WithIndex. Other archives return
+// ErrNoSymbolIndex.
+func (w *Writer) AddSymbols(symbols ...string) error {
+	if w.closed {
+		return ErrWriteAfterClose
+	}
+	if (w.format != FormatDarwin && !w.index) || len(w.pending) == 0 {
+		return fmt.Errorf("%w: symbols must follow WriteHeader for a %s archive", ErrNoSymbolIndex, FormatDarwin)
+	}
+	m := w.pending[len(w.pending)-1]
+	m.symbols = append(m.symbols, symbols...)
+	return nil
+}
+
+// Write writes data for the current member. It returns ErrWriteTooLong if more
+// than Header.Size bytes are written.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrWriteAfterClose
+	}
+	var err error
+	if int64(len(p)) > w.remaining {
+		p = p[:w.remaining]
+		err = ErrWriteTooLong
+	}
+	n, werr := w.memberWriter().Write(p)
+	w.remaining -= int64(n)
+	if werr != nil {
+		return n, werr
+	}
+	return n, err
+}
+
+// ReadFrom implements io.ReaderFrom, copying the data for the current member
+// from r until EOF. The copy goes straight to the underlying io.Writer for BSD
+// archives, so it can use any fast path it has (such as sendfile). It returns
+// ErrWriteTooLong if r holds more than Header.Size bytes.
+func (w *Writer) ReadFrom(r io.Reader) (int64, error) {
+	if w.closed {
+		return 0, ErrWriteAfterClose
+	}
+	n, err := io.Copy(w.memberWriter(), io.LimitReader(r, w.remaining))
+	w.remaining -= n
+	if err != nil {
+		return n, err
+	}
+	if w.remaining == 0 {
+		// Check there isn't anything left over
+		var extra [1]byte
+		if m, _ := r.Read(extra[:]); m > 0 {
+			return n, ErrWriteTooLong
+		}
+	}
+	return n, nil
+}
+
+// sizeOf determines how much data is left in r without reading it, if possible
+func sizeOf(r io.Reader) (int64, bool) {
+	switch sized := r.(type) {
+	case interface{ Len() int }:
+		return int64(sized.Len()), true
+	case io.Seeker:
+		current, err := sized.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		end, err := sized.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, false
+		}
+		if _, err := sized.Seek(current, io.SeekStart); err != nil {
+			return 0, false
+		}
+		return end - current, true
+	}
+	return 0, false
+}
+
+// WriteFrom writes a complete member, with the header followed by the contents
+// of r. If hdr.Size is zero, the size is determined from r: readers with a Len
+// method (such as bytes.Reader) or which can Seek (such as os.File) are
+// measured directly, while anything else is first copied to a temporary file.
+// Setting hdr.Size avoids the temporary file. It returns the number of bytes of
+// data written, and ErrShortMember if r held less than hdr.Size bytes.
+func (w *Writer) WriteFrom(hdr *Header, r io.Reader) (int64, error) {
+	h := *hdr
+	if h.Size == 0 {
+		size, ok := sizeOf(r)
+		if !ok {
+			tmp, err := os.CreateTemp("", "goarfs-*")
+			if err != nil {
+				return 0, err
+			}
+			defer os.Remove(tmp.Name())
+			defer tmp.Close()
+			if size, err = io.Copy(tmp, r); err != nil {
+				return 0, err
+			}
+			if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+				return 0, err
+			}
+			r = tmp
+		}
+		h.Size = size
+	}
+
+	if err := w.WriteHeader(&h); err != nil {
+		return 0, err
+	}
+	n, err := w.ReadFrom(r)
+	if err != nil {
+		return n, err
+	}
+	if n < h.Size {
+		return n, fmt.Errorf("%w: %d bytes missing", ErrShortMember, h.Size-n)
+	}
+	return n, nil
+}
+
+// AddFS adds every regular file in fsys to the archive, walking it in lexical
+// order as fs.WalkDir does. Each member is named by its path within fsys,
+// keeping any slashes, and takes its size, mode & modification time from the
+// fs.FileInfo. Directories are skipped, while anything else which isn't a
+// regular file fails with ErrNotRegular. The contents are streamed from fsys
+// rather than read into memory. Errors are reported as an fs.PathError naming
+// the file which failed.
+func (w *Writer) AddFS(fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if err := w.addFile(fsys, name, d); err != nil {
+			return &fs.PathError{Op: "add", Path: name, Err: err}
+		}
+		return nil
+	})
+}
+
+// addFile writes a single file from fsys as a member
+func (w *Writer) addFile(fsys fs.FS, name string, d fs.DirEntry) error {
+	info, err := d.Info()
+	if err != nil {
+		return err
+	}
+	hdr, err := FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := w.WriteHeader(hdr); err != nil {
+		return err
+	}
+	n, err := w.ReadFrom(f)
+	if err != nil {
+		return err
+	}
+	if n < hdr.Size {
+		return fmt.Errorf("%w: %d bytes missing", ErrShortMember, hdr.Size-n)
+	}
+	return nil
+}
+
+// Create writes an archive holding every regular file in fsys to w, as added
+// by AddFS. Files in subdirectories keep their full paths as member names, so
+// opening the archive WithVirtualDirs gives back the same tree.
+func Create(w io.Writer, fsys fs.FS, opts ...WriterOption) error {
+	aw := NewWriter(w, opts...)
+	if err := aw.AddFS(fsys); err != nil {
+		return err
+	}
+	return aw.Close()
+}
+
+// Close finishes the archive. It does not close the underlying io.Writer,
+// other than the file opened by OpenAppend.
+func (w *Writer) Close() error {
+	err := w.finish()
+	err = errors.Join(err, w.closeSpool())
+	if w.closer != nil {
+		err = errors.Join(err, w.closer.Close())
+		w.closer = nil
+	}
+	return err
+}
+
+// finish writes out anything which is still buffered
+func (w *Writer) finish() error {
+	if w.closed {
+		return nil
+	}
+	if err := w.finishMember(); err != nil {
+		return err
+	}
+	w.closed = true
+	if err := w.writeSignature(); err != nil {
+		return err
+	}
+	if w.index {
+		for _, m := range w.pending {
+			if len(m.symbols) == 0 {
+				m.symbols = objectSymbols(m.data())
+			}
+		}
+	}
+	switch w.format {
+	case FormatGNU:
+		return w.flushGNU()
+	case FormatDarwin:
+		return w.flushDarwin()
+	}
+	return nil
+}
+
+// flushGNU writes out the buffered GNU members, preceded by a '//' table
+// holding all of the long filenames
+func (w *Writer) flushGNU() error {
+	var table bytes.Buffer
+	names := make([]string, len(w.pending))
+	for i, m := range w.pending {
+		if w.needsLongName(m.hdr.Name) {
+			names[i] = fmt.Sprintf("/%d", table.Len())
+			table.WriteString(m.hdr.Name + "/\n")
+		} else {
+			names[i] = m.hdr.Name + "/"
+		}
+	}
+
+	if table.Len()%2 != 0 {
+		table.WriteByte('\n')
+	}
+	if err := w.writeGNUSymbols(table.Len()); err != nil {
+		return err
+	}
+	if table.Len() > 0 {
+		layout := "%-48s%-10d`\n"
+		if w.padding == PaddingZero {
+			layout = "%-48s%010d`\n"
+		}
+		header := fmt.Sprintf(layout, "//", table.Len())
+		if _, err := io.WriteString(w.w, header); err != nil {
+			return err
+		}
+		if _, err := table.WriteTo(w.w); err != nil {
+			return err
+		}
+	}
+
+	for i, m := range w.pending {
+		header, err := w.formatHeader(names[i], unixTime(m.hdr.ModTime), m.hdr.UID, m.hdr.GID, m.hdr.Mode, m.hdr.Size)
+		if err != nil {
+			return err
+		}
+		if _, err := w.w.Write(header); err != nil {
+			return err
+		}
+		if _, err := io.Copy(w.w, m.data()); err != nil {
+			return err
+		}
+	}
+	w.pending = nil
+	return nil
+}
+
+// writeGNUSymbols writes the '/' symbol index of a GNU archive, if it has one,
+// which lists the symbols defined by the members along with the offset of the
+// header of each one. The members follow the index & the long filename table,
+// which is tableSize bytes long.
+func (w *Writer) writeGNUSymbols(tableSize int) error {
+	var count int
+	var names bytes.Buffer
+	for _, m := range w.pending {
+		for _, sym := range m.symbols {
+			names.WriteString(sym)
+			names.WriteByte(0)
+			count++
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+	size := int64(4 + 4*count + names.Len())
+	// Like GNU ar, the index is padded to an even length within the member
+	if size%2 != 0 {
+		names.WriteByte(0)
+		size++
+	}
+
+	offset := int64(len(goodSignature)) + headerSize + size
+	if tableSize > 0 {
+		offset += headerSize + int64(tableSize)
+	}
+	index := binary.BigEndian.AppendUint32(nil, uint32(count))
+	for _, m := range w.pending {
+		if offset > math.MaxUint32 {
+			return fmt.Errorf("%w: symbol index offset %d", ErrFieldOverflow, offset)
+		}
+		for range m.symbols {
+			index = binary.BigEndian.AppendUint32(index, uint32(offset))
+		}
+		offset += headerSize + m.hdr.Size + m.hdr.Size%2
+	}
+	header, err := w.formatHeader("/", 0, 0, 0, 0, size)
+	if err != nil {
+		return err
+	}
+	for _, b := range [][]byte{header, index, names.Bytes()} {
+		if _, err := w.w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// symdefName is the name of the symbol index written for FormatDarwin
+const symdefName = "__.SYMDEF SORTED"
+
+// flushDarwin writes out the buffered Darwin members, preceded by a sorted
+// '__.SYMDEF SORTED' symbol index if any symbols were added. The index refers
+// to members by the offset of their header, so the layout of every member is
+// worked out before anything is written.
+func (w *Writer) flushDarwin() error {
+	headers := make([][]byte, len(w.pending))
+	extended := make([][]byte, len(w.pending))
+	type definition struct {
+		name   string
+		member int
+	}
+	var definitions []definition
+	for i, m := range w.pending {
+		var err error
+		if headers[i], extended[i], err = w.bsdHeader(m.hdr); err != nil {
+			return err
+		}
+		for _, sym := range m.symbols {
+			definitions = append(definitions, definition{sym, i})
+		}
+	}
+	if len(definitions) == 0 {
+		return w.writeBSDMembers(headers, extended)
+	}
+	sort.SliceStable(definitions, func(i, j int) bool {
+		return definitions[i].name < definitions[j].name
+	})
+
+	var stringTable bytes.Buffer
+	strx := make([]uint32, len(definitions))
+	for i, d := range definitions {
+		strx[i] = uint32(stringTable.Len())
+		stringTable.WriteString(d.name)
+		stringTable.WriteByte(0)
+	}
+	nameLength := bsdNameLength(symdefName)
+	ranlibSize := 8 * len(definitions)
+	// Pad the string table so that the members which follow are 8 byte
+	// aligned, as the Darwin tools do
+	for (nameLength+int64(4+ranlibSize+4+stringTable.Len()))%8 != 0 {
+		stringTable.WriteByte(0)
+	}
+	symdefSize := nameLength + int64(4+ranlibSize+4+stringTable.Len())
+
+	// Each member is found by the offset of its header
+	offsets := make([]uint32, len(w.pending))
+	offset := int64(len(goodSignature)) + headerSize + symdefSize
+	for i, m := range w.pending {
+		if offset > math.MaxUint32 {
+			return fmt.Errorf("%w: symbol index offset %d", ErrFieldOverflow, offset)
+		}
+		offsets[i] = uint32(offset)
+		size := int64(len(extended[i])) + m.hdr.Size
+		offset += headerSize + size + size%2
+	}
+
+	header, err := w.formatHeader(fmt.Sprintf("#1/%d", nameLength), 0, 0, 0, 0o100644, symdefSize)
+	if err != nil {
+		return err
+	}
+	symdef := bytes.NewBuffer(header)
+	name := make([]byte, nameLength)
+	copy(name, symdefName)
+	symdef.Write(name)
+	symdef.Write(binary.LittleEndian.AppendUint32(nil, uint32(ranlibSize)))
+	for i, d := range definitions {
+		symdef.Write(binary.LittleEndian.AppendUint32(nil, strx[i]))
+		symdef.Write(binary.LittleEndian.AppendUint32(nil, offsets[d.member]))
+	}
+	symdef.Write(binary.LittleEndian.AppendUint32(nil, uint32(stringTable.Len())))
+	symdef.Write(stringTable.Bytes())
+	if _, err := symdef.WriteTo(w.w); err != nil {
+		return err
+	}
+	return w.writeBSDMembers(headers, extended)
+}
+
+// writeBSDMembers writes out the buffered members in BSD format, using the
+// already encoded headers & extended filenames
+func (w *Writer) writeBSDMembers(headers, extended [][]byte) error {
+	for i, m := range w.pending {
+		for _, b := range [][]byte{headers[i], extended[i]} {
+			if _, err := w.w.Write(b); err != nil {
+				return err
+			}
+		}
+		if _, err := io.Copy(w.w, m.data()); err != nil {
+			return err
+		}
+	}
+	w.pending = nil
+	return nil
+}
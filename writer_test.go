@@ -0,0 +1,801 @@
+package goarfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// rewrite copies every member of an archive into a new one
+func rewrite(t *testing.T, ar *ARFS, opts ...WriterOption) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf, opts...)
+	for i := 0; ; i++ {
+		hdr, err := ar.HeaderIndex(i)
+		if err != nil {
+			break
+		}
+		f, err := ar.OpenIndex(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteHeader(&hdr); err != nil {
+			t.Fatalf("write header %q: %s", hdr.Name, err)
+		}
+		if _, err := io.Copy(w, f); err != nil {
+			t.Fatalf("write %q: %s", hdr.Name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGNURoundTrip(t *testing.T) {
+	original, err := os.ReadFile("testdata/gnu_roundtrip.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar, err := FromInterface(bytes.NewReader(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewritten := rewrite(t, ar, WithFormat(FormatGNU))
+	if !bytes.Equal(original, rewritten) {
+		t.Fatalf("GNU round trip is not byte identical:\n%q\n%q", original, rewritten)
+	}
+}
+
+func TestWriteBSDLongName(t *testing.T) {
+	long := "this_is_a_forty_character_member_name.o"
+	long += strings.Repeat("x", 40-len(long))
+	exact := "exactly16bytes.o"
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithFormat(FormatBSD))
+	for _, name := range []string{long, exact} {
+		if err := w.WriteHeader(&Header{Name: name, Size: 5, Mode: 0o100644}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	raw := buf.Bytes()
+
+	// The long name is stored at the start of the data, NUL padded to a
+	// multiple of 4 bytes, and included in the size
+	header := raw[len(goodSignature) : len(goodSignature)+headerSize]
+	if name := string(header[:16]); name != "#1/44           " {
+		t.Fatalf("long name has wrong header name %q", name)
+	}
+	if size := strings.TrimSpace(string(header[48:58])); size != "49" {
+		t.Fatalf("long name has wrong header size %q", size)
+	}
+	if !bytes.Contains(raw, []byte(exact+"0           ")) {
+		t.Fatalf("16 byte name should be stored inline")
+	}
+
+	ar, err := FromInterface(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{long, exact} {
+		data, err := ar.ReadFile(name)
+		if err != nil {
+			t.Fatalf("cannot read %q back: %s", name, err)
+		}
+		if string(data) != "hello" {
+			t.Fatalf("%q has wrong contents: %q", name, data)
+		}
+	}
+}
+
+func TestWriteGNULongNames(t *testing.T) {
+	names := []string{"short.o", "first_long_member_name.o", "tiny", "second_long_member_name.txt", "pkg/a.o"}
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithFormat(FormatGNU))
+	for _, name := range names {
+		if err := w.WriteHeader(&Header{Name: name, Size: int64(len(name)), Mode: 0o100644}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	raw := buf.Bytes()
+	if !bytes.Contains(raw, []byte("short.o/        ")) || !bytes.Contains(raw, []byte("tiny/           ")) {
+		t.Fatalf("short names should be stored inline with a trailing '/'")
+	}
+
+	ar, err := FromInterface(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		data, err := ar.ReadFile(name)
+		if err != nil {
+			t.Fatalf("cannot read %q back: %s", name, err)
+		}
+		if string(data) != name {
+			t.Fatalf("%q has wrong contents: %q", name, data)
+		}
+	}
+
+	// The name table comes first, and only holds the long names & those with
+	// a '/'. Like GNU ar, it is padded to an even length within the member
+	// itself
+	table := ar.snapshot().members[0]
+	if table.name != "//" {
+		t.Fatalf("first member should be the '//' table, not %q", table.name)
+	}
+	contents, err := io.ReadAll(table.reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "first_long_member_name.o/\nsecond_long_member_name.txt/\npkg/a.o/\n" {
+		t.Fatalf("'//' table has wrong contents: %q", contents)
+	}
+
+	// binutils should list the same names, in the same order
+	arTool, err := exec.LookPath("ar")
+	if err != nil {
+		return
+	}
+	filename := filepath.Join(t.TempDir(), "gnu.a")
+	if err := os.WriteFile(filename, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(arTool, "t", filename).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listed := strings.Fields(string(out)); strings.Join(listed, " ") != strings.Join(names, " ") {
+		t.Fatalf("ar t lists %v, expected %v", listed, names)
+	}
+}
+
+func TestWriteFrom(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 10*1024*1024/16)
+	filename := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(filename, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, format := range []Format{FormatBSD, FormatGNU} {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		w := NewWriter(&buf, WithFormat(format))
+		for name, r := range map[string]io.Reader{
+			"sized":    bytes.NewReader(data),
+			"unsized":  io.MultiReader(bytes.NewReader(data)),
+			"file.bin": f,
+		} {
+			n, err := w.WriteFrom(&Header{Name: name, Mode: 0o100644}, r)
+			if err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+			if n != int64(len(data)) {
+				t.Fatalf("%s: wrote %d bytes, expected %d", name, n, len(data))
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		ar, err := FromInterface(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"sized", "unsized", "file.bin"} {
+			contents, err := ar.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(contents, data) {
+				t.Errorf("%s: %s has the wrong contents", format, name)
+			}
+		}
+	}
+}
+
+func TestWriteFromSize(t *testing.T) {
+	w := NewWriter(io.Discard)
+	if _, err := w.WriteFrom(&Header{Name: "short", Size: 10}, strings.NewReader("abc")); !errors.Is(err, ErrShortMember) {
+		t.Fatalf("short reader should fail with ErrShortMember: %v", err)
+	}
+
+	w = NewWriter(io.Discard)
+	if err := w.WriteHeader(&Header{Name: "a", Size: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := w.ReadFrom(strings.NewReader("abcd")); !errors.Is(err, ErrWriteTooLong) || n != 3 {
+		t.Fatalf("extra data should fail with ErrWriteTooLong: %d %v", n, err)
+	}
+}
+
+func TestWriteNumericPadding(t *testing.T) {
+	for style, expected := range map[NumericPadding]string{
+		PaddingSpace: "data.txt        1700000000  1000  100   100644  5         `\n",
+		PaddingZero:  "data.txt        001700000000001000000100100644  0000000005`\n",
+	} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, WithNumericPadding(style))
+		hdr := &Header{Name: "data.txt", Size: 5, Mode: 0o100644, ModTime: time.Unix(1700000000, 0), UID: 1000, GID: 100}
+		if err := w.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		header := buf.String()[len(goodSignature) : len(goodSignature)+headerSize]
+		if header != expected {
+			t.Errorf("style %d: wrong header:\n%q\n%q", style, header, expected)
+		}
+
+		ar, err := FromInterface(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		read, err := ar.HeaderIndex(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if read.Size != hdr.Size || read.UID != hdr.UID || read.GID != hdr.GID || !read.ModTime.Equal(hdr.ModTime) {
+			t.Errorf("style %d: header read back wrongly: %#v", style, read)
+		}
+	}
+}
+
+func TestWriteHeaderSequencing(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.WriteHeader(&Header{Name: "odd.txt", Size: 3, Mode: 0o644}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("ab")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader(&Header{Name: "next.txt", Size: 1}); !errors.Is(err, ErrShortMember) {
+		t.Fatalf("WriteHeader before the previous member is complete should fail with ErrShortMember: %v", err)
+	}
+	if _, err := w.Write([]byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader(&Header{Name: "next.txt", Size: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); !errors.Is(err, ErrShortMember) {
+		t.Fatalf("Close before the last member is complete should fail with ErrShortMember: %v", err)
+	}
+
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("!<arch>\n")) {
+		t.Fatalf("archive should start with the signature: %q", data)
+	}
+	if pad := data[8+headerSize+3]; pad != '\n' {
+		t.Fatalf("odd sized member should be padded with a newline, not %q", pad)
+	}
+}
+
+func TestWriteReadByGNUAr(t *testing.T) {
+	arTool, err := exec.LookPath("ar")
+	if err != nil {
+		t.Skip("ar is not installed")
+	}
+	members := map[string]string{
+		"odd.txt":                      "abc",
+		"even.txt":                     "abcd",
+		"a_rather_long_member_name.o":  strings.Repeat("x", 101),
+		"another_rather_long_name.dat": "",
+	}
+	for _, format := range []Format{FormatGNU, FormatBSD} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, WithFormat(format))
+		for _, name := range []string{"odd.txt", "even.txt", "a_rather_long_member_name.o", "another_rather_long_name.dat"} {
+			data := members[name]
+			if err := w.WriteHeader(&Header{Name: name, Size: int64(len(data)), Mode: 0o100644, ModTime: time.Unix(1700000000, 0)}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.WriteString(w, data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		filename := filepath.Join(t.TempDir(), "written.a")
+		if err := os.WriteFile(filename, buf.Bytes(), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		ar, err := FromFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ar.Close()
+		for name, expected := range members {
+			data, err := ar.ReadFile(name)
+			if err != nil || string(data) != expected {
+				t.Errorf("%s: %s reads back wrongly: %q %v", format, name, data, err)
+			}
+			out, err := exec.Command(arTool, "p", filename, name).Output()
+			if err != nil || string(out) != expected {
+				t.Errorf("%s: ar p %s gave %q %v", format, name, out, err)
+			}
+		}
+	}
+}
+
+func TestAddFS(t *testing.T) {
+	mtime := time.Unix(1700000000, 0)
+	fsys := fstest.MapFS{
+		"top.txt":                  {Data: []byte("top"), Mode: 0o644, ModTime: mtime},
+		"dir/nested.txt":           {Data: []byte("nested"), Mode: 0o600, ModTime: mtime},
+		"dir/deeper/a_long_name.o": {Data: []byte(strings.Repeat("o", 99)), Mode: 0o755, ModTime: mtime},
+		"empty":                    {Mode: fs.ModeDir | 0o755},
+		"zero.txt":                 {Mode: 0o644, ModTime: mtime},
+	}
+	for _, format := range []Format{FormatGNU, FormatBSD} {
+		var buf bytes.Buffer
+		if err := Create(&buf, fsys, WithFormat(format)); err != nil {
+			t.Fatal(err)
+		}
+		ar, err := FromInterface(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, h := range ar.List() {
+			names = append(names, h.Name)
+			file := fsys[h.Name]
+			if h.Mode != modeRegular|uint32(file.Mode.Perm()) || !h.ModTime.Equal(file.ModTime) {
+				t.Errorf("%s: %s has the wrong mode %o or time %s", format, h.Name, h.Mode, h.ModTime)
+			}
+			data, err := ar.ReadFile(h.Name)
+			if err != nil || !bytes.Equal(data, file.Data) {
+				t.Errorf("%s: %s has the wrong contents: %v", format, h.Name, err)
+			}
+		}
+		expected := "dir/deeper/a_long_name.o dir/nested.txt top.txt zero.txt"
+		if strings.Join(names, " ") != expected {
+			t.Errorf("%s: members should be the files in walk order: %v", format, names)
+		}
+	}
+
+	fsys["link"] = &fstest.MapFile{Data: []byte("top.txt"), Mode: fs.ModeSymlink}
+	err := Create(io.Discard, fsys)
+	var pathErr *fs.PathError
+	if !errors.Is(err, ErrNotRegular) || !errors.As(err, &pathErr) || pathErr.Path != "link" {
+		t.Fatalf("symlink should fail with ErrNotRegular naming the path: %v", err)
+	}
+}
+
+func TestWriteBSDLongNameEdges(t *testing.T) {
+	members := []struct {
+		name string
+		data string
+	}{
+		// Every alignment of the NUL padded name
+		{"seventeen_bytes.o", "a"},
+		{"eighteen_bytes_.o", "ab"},
+		{"nineteen_bytes__.o", "abc"},
+		{"twenty_bytes_long.o", "abcd"},
+		{"has spaces.txt", "spaced"},
+		{"an_empty_member_with_a_long_name", ""},
+		{"exactly16bytes.o", ""},
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithFormat(FormatBSD))
+	for _, m := range members {
+		if _, err := w.WriteFrom(&Header{Name: m.name, Size: int64(len(m.data)), Mode: 0o100644}, strings.NewReader(m.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "bsd.a")
+	if err := os.WriteFile(filename, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ar, err := FromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	if ar.Format() != FormatBSD {
+		t.Errorf("archive should be detected as BSD, not %s", ar.Format())
+	}
+	// llvm-ar reads BSD archives in the same way as the macOS ar
+	llvmAr, lookErr := exec.LookPath("llvm-ar")
+	for _, m := range members {
+		data, err := ar.ReadFile(m.name)
+		if err != nil || string(data) != m.data {
+			t.Errorf("%q reads back wrongly: %q %v", m.name, data, err)
+		}
+		if lookErr != nil {
+			continue
+		}
+		out, err := exec.Command(llvmAr, "p", filename, m.name).Output()
+		if err != nil || string(out) != m.data {
+			t.Errorf("llvm-ar p %q gave %q %v", m.name, out, err)
+		}
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	build := func(mtime time.Time, mode fs.FileMode) []byte {
+		t.Helper()
+		fsys := fstest.MapFS{
+			"b.txt":                    {Data: []byte("bee"), Mode: mode, ModTime: mtime},
+			"a.txt":                    {Data: []byte("a"), Mode: mode, ModTime: mtime},
+			"dir/a_long_member_name.o": {Data: []byte("object"), Mode: mode, ModTime: mtime},
+		}
+		var buf bytes.Buffer
+		if err := Create(&buf, fsys, WithFormat(FormatGNU), Deterministic()); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	first := build(time.Unix(1700000000, 0), 0o755)
+	second := build(time.Unix(1800000000, 0), 0o600)
+	if !bytes.Equal(first, second) {
+		t.Fatalf("deterministic archives should be identical:\n%q\n%q", first, second)
+	}
+
+	ar, err := FromInterface(bytes.NewReader(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, h := range ar.List() {
+		names = append(names, h.Name)
+		if h.Mode != 0o644 || h.UID != 0 || h.GID != 0 || h.ModTime.Unix() != 0 {
+			t.Errorf("%s should have normalised metadata: %+v", h.Name, h)
+		}
+	}
+	if strings.Join(names, " ") != "a.txt b.txt dir/a_long_member_name.o" {
+		t.Fatalf("members should be added in sorted order: %v", names)
+	}
+
+	// The output should match 'ar rcD' byte for byte
+	arTool, err := exec.LookPath("ar")
+	if err != nil {
+		t.Skip("ar is not installed")
+	}
+	dir := t.TempDir()
+	fsys := os.DirFS(dir)
+	for name, data := range map[string]string{"one.o": "1", "a_long_member_name.o": "long"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(arTool, "rcD", "gnu.a", "a_long_member_name.o", "one.o")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("ar failed: %s: %s", err, out)
+	}
+	expected, err := os.ReadFile(filepath.Join(dir, "gnu.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithFormat(FormatGNU), Deterministic())
+	for _, name := range []string{"a_long_member_name.o", "one.o"} {
+		info, err := fs.Stat(fsys, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := fs.FileInfoToDirEntry(info)
+		if err := w.addFile(fsys, name, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("output differs from ar rcD:\n%q\n%q", buf.Bytes(), expected)
+	}
+}
+
+func TestCreateVirtualDirs(t *testing.T) {
+	mtime := time.Unix(1700000000, 0)
+	fsys := fstest.MapFS{
+		"top.txt":                    {Data: []byte("top"), Mode: 0o644, ModTime: mtime},
+		"pkg/a.o":                    {Data: []byte("a"), Mode: 0o644, ModTime: mtime},
+		"pkg/internal/b.o":           {Data: []byte("b"), Mode: 0o644, ModTime: mtime},
+		"pkg/internal/long_name_c.o": {Data: []byte("c"), Mode: 0o644, ModTime: mtime},
+		"docs/readme.md":             {Data: []byte("docs"), Mode: 0o644, ModTime: mtime},
+	}
+	walk := func(fsys fs.FS) []string {
+		t.Helper()
+		var paths []string
+		err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			paths = append(paths, fmt.Sprintf("%s dir=%v", name, d.IsDir()))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return paths
+	}
+	expected := walk(fsys)
+
+	for _, format := range []Format{FormatGNU, FormatBSD} {
+		var buf bytes.Buffer
+		if err := Create(&buf, fsys, WithFormat(format)); err != nil {
+			t.Fatal(err)
+		}
+		// Every nested name should be stored in full, using the long name
+		// mechanism wherever it doesn't fit in the header
+		if format == FormatBSD && !bytes.Contains(buf.Bytes(), []byte("#1/28")) {
+			t.Errorf("%s: long nested names should use '#1/'", format)
+		}
+		if format == FormatGNU && !bytes.Contains(buf.Bytes(), []byte("pkg/a.o/\n")) {
+			t.Errorf("%s: nested names should be in the '//' table", format)
+		}
+		ar, err := FromInterface(bytes.NewReader(buf.Bytes()), WithVirtualDirs())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := walk(ar); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("%s: walking the archive gave\n%s\nexpected\n%s", format, strings.Join(got, "\n"), strings.Join(expected, "\n"))
+		}
+		if err := fstest.TestFS(ar, "top.txt", "pkg/a.o", "pkg/internal/b.o", "pkg/internal/long_name_c.o", "docs/readme.md"); err != nil {
+			t.Errorf("%s: %s", format, err)
+		}
+		if matches, err := fs.Glob(ar, "pkg/*"); err != nil || strings.Join(matches, " ") != "pkg/a.o pkg/internal" {
+			t.Errorf("%s: glob should match directories too: %v %v", format, matches, err)
+		}
+	}
+}
+
+func TestWriteIndex(t *testing.T) {
+	// The members are buffered in a temporary file, which Close removes
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	for _, test := range []struct {
+		filename string
+		format   Format
+	}{
+		{"testdata/gnu.a", FormatGNU},
+		{"testdata/darwin.a", FormatBSD},
+	} {
+		original, err := FromFile(test.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer original.Close()
+		var buf bytes.Buffer
+		w := NewWriter(&buf, WithFormat(test.format), WithIndex())
+		for _, h := range original.List() {
+			data, err := original.ReadFile(h.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.WriteFrom(&h, bytes.NewReader(data)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if spooled, _ := os.ReadDir(tmp); len(spooled) != 1 {
+			t.Errorf("%s: members should be buffered in a temporary file: %v", test.filename, spooled)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if spooled, _ := os.ReadDir(tmp); len(spooled) != 0 {
+			t.Errorf("%s: Close should remove the temporary file: %v", test.filename, spooled)
+		}
+		expected, err := os.ReadFile(test.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.format == FormatGNU && !bytes.Equal(buf.Bytes(), expected) {
+			t.Errorf("%s: should match the archive written by ar rcsD", test.filename)
+		}
+
+		written, err := FromInterface(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if written.Format() != original.Format() {
+			t.Errorf("%s: written as %s, should be %s", test.filename, written.Format(), original.Format())
+		}
+		if got, expected := written.Symbols(), original.Symbols(); len(expected) == 0 || !maps.Equal(got, expected) {
+			t.Errorf("%s: symbols are %v, should be %v", test.filename, got, expected)
+		}
+	}
+}
+
+func TestSymbolTableLinks(t *testing.T) {
+	ld, err := exec.LookPath("ld")
+	if err != nil {
+		t.Skip("ld is not installed")
+	}
+	original, err := FromFile("testdata/gnu.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer original.Close()
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithSymbolTable())
+	for _, h := range original.List() {
+		f, err := original.Open(h.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = w.WriteFrom(&h, f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "lib.a")
+	if err := os.WriteFile(filename, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The linker only pulls in members which the index says define an
+	// undefined symbol, and refuses archives without an index
+	linked := filepath.Join(dir, "linked.o")
+	if out, err := exec.Command(ld, "-r", "-u", "long_func", "-o", linked, filename).CombinedOutput(); err != nil {
+		t.Fatalf("ld rejected the archive: %v\n%s", err, out)
+	}
+	ar, err := FromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	if member, ok := ar.LookupSymbol("long_func"); !ok || member != "a_very_long_object_file_name.o" {
+		t.Errorf("long_func should be defined by a_very_long_object_file_name.o: %q %v", member, ok)
+	}
+}
+
+func TestWritePadding(t *testing.T) {
+	// Odd sized members are followed by a '\n' which isn't counted in their
+	// size. The order puts odd sizes next to each other and one at the end.
+	sizes := []int{1, 3, 59, 0, 2, 60, 61}
+	for _, test := range []struct {
+		format Format
+		golden string
+	}{
+		// Written by GNU ar rcD
+		{FormatGNU, "testdata/padding_gnu.a"},
+		// GNU & LLVM ar read this back, though llvm-ar writes every name as
+		// '#1/' so can't produce it
+		{FormatBSD, "testdata/padding_bsd.a"},
+	} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, WithFormat(test.format), Deterministic())
+		for _, size := range sizes {
+			name := fmt.Sprintf("size%d.dat", size)
+			if err := w.WriteHeader(&Header{Name: name, Size: int64(size)}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.WriteString(w, strings.Repeat("x", size)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		golden, err := os.ReadFile(test.golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), golden) {
+			t.Errorf("%s: output doesn't match %s:\n%q", test.format, test.golden, buf.Bytes())
+		}
+
+		ar, err := FromInterface(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var offset int64 = 8
+		for i, h := range ar.List() {
+			if h.Name != fmt.Sprintf("size%d.dat", sizes[i]) || h.Size != int64(sizes[i]) {
+				t.Errorf("%s: member %d is %s with size %d", test.format, i, h.Name, h.Size)
+			}
+			data, err := ar.ReadFile(h.Name)
+			if err != nil || string(data) != strings.Repeat("x", sizes[i]) {
+				t.Errorf("%s: %s reads back as %q: %v", test.format, h.Name, data, err)
+			}
+			// The data is followed by the pad byte, if any, then the next header
+			end := offset + headerSize + h.Size
+			if h.Size%2 != 0 {
+				if golden[end] != '\n' {
+					t.Errorf("%s: %s should be padded with '\\n', not %q", test.format, h.Name, golden[end])
+				}
+				end++
+			}
+			if end < int64(len(golden)) && golden[end] != 's' {
+				t.Errorf("%s: %s should be followed by the next header, not %q", test.format, h.Name, golden[end])
+			}
+			offset = end
+		}
+		if offset != int64(len(golden)) {
+			t.Errorf("%s: archive should end after the last pad byte at %d, not %d", test.format, offset, len(golden))
+		}
+	}
+}
+
+func TestWriteEmptyMember(t *testing.T) {
+	for _, format := range []Format{FormatGNU, FormatBSD} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, WithFormat(format))
+		if _, err := w.WriteFrom(&Header{Name: "before.txt"}, strings.NewReader("ab")); err != nil {
+			t.Fatal(err)
+		}
+		// The empty member has no data, so the next header follows its own
+		if err := w.WriteHeader(&Header{Name: "empty"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteHeader(&Header{Name: "after.txt", Size: 2}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, "cd"); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+		empty := len(goodSignature) + headerSize + 2
+		if len(data) != empty+2*headerSize+2 || !bytes.HasPrefix(data[empty+headerSize:], []byte("after.txt")) {
+			t.Fatalf("%s: empty member should be just a header:\n%q", format, data)
+		}
+		if size := strings.TrimSpace(string(data[empty+48 : empty+58])); size != "0" {
+			t.Errorf("%s: empty member should have a size of 0, not %q", format, size)
+		}
+
+		ar, err := FromInterface(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, err := ar.ReadFile("empty")
+		if err != nil || contents == nil || len(contents) != 0 {
+			t.Errorf("%s: empty member should read as an empty slice: %q %v", format, contents, err)
+		}
+		if contents, err := ar.ReadFile("after.txt"); err != nil || string(contents) != "cd" {
+			t.Errorf("%s: member after the empty one reads back as %q: %v", format, contents, err)
+		}
+	}
+}